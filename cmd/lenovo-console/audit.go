@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole"
+)
+
+// auditFlags registers the audit log flags on fs and returns a function that
+// opens the configured sinks once the flags are parsed
+func auditFlags(fs *flag.FlagSet) func() (lenovoconsole.AuditConfig, error) {
+	file := fs.String("audit-log", "", "append a JSON-lines audit log to this file")
+	useSyslog := fs.Bool("audit-syslog", false, "also send audit records to the local syslog")
+	webhook := fs.String("audit-webhook", "", "also POST each audit record to this URL")
+	userHeader := fs.String("audit-user-header", "", "request header with the user authenticated by a reverse proxy (e.g. X-Forwarded-User)")
+	trustedProxies := fs.String("audit-trusted-proxy", "", "comma-separated IPs or CIDR ranges of the reverse proxy setting -audit-user-header (default: loopback only)")
+
+	return func() (lenovoconsole.AuditConfig, error) {
+		config := lenovoconsole.AuditConfig{UserHeader: *userHeader}
+		for _, proxy := range strings.Split(*trustedProxies, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+					return config, fmt.Errorf("invalid -audit-trusted-proxy %q: not an IP or CIDR range", proxy)
+				}
+				config.TrustedProxies = append(config.TrustedProxies, proxy)
+			}
+		}
+
+		var sinks []lenovoconsole.AuditSink
+		if *file != "" {
+			sink, err := lenovoconsole.NewFileAuditSink(*file)
+			if err != nil {
+				return config, err
+			}
+			sinks = append(sinks, sink)
+		}
+		if *useSyslog {
+			sink, err := lenovoconsole.NewSyslogAuditSink("lenovo-console")
+			if err != nil {
+				for _, s := range sinks {
+					s.Close()
+				}
+				return config, err
+			}
+			sinks = append(sinks, sink)
+		}
+		if *webhook != "" {
+			sinks = append(sinks, lenovoconsole.NewWebhookAuditSink(*webhook))
+		}
+
+		if len(sinks) > 0 {
+			config.Log = lenovoconsole.NewAuditLog(sinks...)
+		}
+		return config, nil
+	}
+}
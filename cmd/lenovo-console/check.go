@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole"
+)
+
+// runCheck implements the "check" subcommand and returns the process exit code
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "print the report as JSON")
+	timeout := fs.Duration("timeout", lenovoconsole.DefaultCheckTimeout, "timeout for each check step")
+	rpPort := fs.Int("rp-port", 0, "remote presence port to test (default: query the BMC)")
+	providerName := fs.String("provider", lenovoconsole.ProviderXCC, providerUsage)
+	proxy := fs.String("proxy", "", proxyUsage)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lenovo-console check [-json] [-timeout 10s] [-rp-port N] [-provider xcc|imm2] [-proxy URL] <BMC_IP> <USERNAME> <PASSWORD>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return 2
+	}
+
+	provider, err := lenovoconsole.ProviderByName(*providerName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	report := lenovoconsole.Check(lenovoconsole.ConsoleConfig{
+		BMCIP:    fs.Arg(0),
+		Username: fs.Arg(1),
+		Password: fs.Arg(2),
+		RPPort:   *rpPort,
+		Provider: provider,
+		Proxy:    *proxy,
+	}, *timeout)
+
+	if *jsonOutput {
+		report.WriteJSON(os.Stdout)
+	} else {
+		report.WriteText(os.Stdout)
+	}
+
+	if !report.Passed {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole"
+)
+
+// runDiscover implements the "discover" subcommand, which scans address ranges for
+// Lenovo BMCs, and returns the process exit code: 0 if the scan completed, 1 if it
+// was interrupted
+func runDiscover(args []string) int {
+	fs := flag.NewFlagSet("discover", flag.ExitOnError)
+	ports := fs.String("ports", "443", "comma separated HTTPS ports to probe on every address")
+	concurrency := fs.Int("concurrency", 64, "addresses probed at once")
+	timeout := fs.Duration("timeout", 3*time.Second, "time limit for probing one address and port")
+	username := fs.String("user", "", "BMC username, to read firmware versions and serial numbers")
+	password := fs.String("password", "", "BMC password")
+	passwordFile := fs.String("password-file", "", "read the BMC password from a file")
+	proxy := fs.String("proxy", "", proxyUsage)
+	format := fs.String("format", "json", "output format: json, csv, or list (one address per line, usable as fleet -targets)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lenovo-console discover [options] <CIDR|IP>...")
+		fmt.Fprintln(fs.Output(), "Example: lenovo-console discover -format csv 10.145.120.0/22 > xccs.csv")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return 2
+	}
+	fail := func(err error) int {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	opts := lenovoconsole.DiscoverOptions{
+		Concurrency: *concurrency,
+		Timeout:     *timeout,
+		Username:    *username,
+		Password:    *password,
+		Proxy:       *proxy,
+		Progress: func(b lenovoconsole.DiscoveredBMC) {
+			fmt.Fprintf(os.Stderr, "Found %s %s %s\n", b.Address, b.Provider, b.Model)
+		},
+	}
+	for _, p := range strings.Split(*ports, ",") {
+		port, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return fail(fmt.Errorf("invalid port %q", p))
+		}
+		opts.Ports = append(opts.Ports, port)
+	}
+	if *passwordFile != "" {
+		var err error
+		if opts.Password, err = readPasswordFile(*passwordFile); err != nil {
+			return fail(err)
+		}
+	}
+	switch *format {
+	case "json", "csv", "list":
+	default:
+		return fail(fmt.Errorf("unknown format %q", *format))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, err := lenovoconsole.Discover(ctx, fs.Args(), opts)
+	if report == nil {
+		return fail(err)
+	}
+	fmt.Fprintf(os.Stderr, "Probed %d endpoints, found %d BMCs\n", report.Scanned, len(report.Found))
+
+	switch *format {
+	case "json":
+		report.WriteJSON(os.Stdout)
+	case "csv":
+		report.WriteCSV(os.Stdout)
+	case "list":
+		for _, b := range report.Found {
+			fmt.Println(b.Address)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Scan interrupted: %v\n", err)
+		return 1
+	}
+	return 0
+}
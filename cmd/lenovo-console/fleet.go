@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole"
+)
+
+// runFleet implements the "fleet" subcommand, which runs one operation against many BMCs,
+// and returns the process exit code: 0 if it succeeded everywhere, 1 if any BMC failed
+func runFleet(args []string) int {
+	fs := flag.NewFlagSet("fleet", flag.ExitOnError)
+	targetsFile := fs.String("targets", "", "file with one BMC per line: BMC [USERNAME [PASSWORD [PROVIDER]]] (- for stdin)")
+	username := fs.String("user", "USERID", "username for targets that do not name one")
+	password := fs.String("password", "", "password for targets that do not name one")
+	passwordFile := fs.String("password-file", "", "read the default password from a file")
+	providerName := fs.String("provider", lenovoconsole.ProviderXCC, providerUsage)
+	proxy := fs.String("proxy", "", proxyUsage)
+	concurrency := fs.Int("concurrency", 10, "BMCs worked on at once")
+	timeout := fs.Duration("timeout", time.Minute, "time limit for each attempt on a BMC")
+	retries := fs.Int("retries", 1, "further attempts after a failure (power actions are never retried)")
+	retryDelay := fs.Duration("retry-delay", 2*time.Second, "pause between attempts")
+	checkTimeout := fs.Duration("check-timeout", lenovoconsole.DefaultCheckTimeout, "timeout for each check step")
+	action := fs.String("action", "", "power action: on, off, shutdown, restart, reset or cycle (default: report the power state)")
+	screenshotDir := fs.String("screenshot-dir", ".", "directory for screenshots, saved as <BMC>.png")
+	var browser lenovoconsole.BrowserConfig
+	fs.StringVar(&browser.Name, "browser", "", "browser for screenshots: chrome, chromium, firefox, edge or brave")
+	fs.StringVar(&browser.Command, "browser-cmd", "", "custom browser command for screenshots, e.g. a headless Chromium; {url} and {profile} are substituted")
+	jsonOutput := fs.Bool("json", false, "print the results as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lenovo-console fleet [options] <rp-port|info|check|power|screenshot> [BMC...]")
+		fmt.Fprintln(fs.Output(), "Targets are given as arguments, with -targets, or both.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return 2
+	}
+	fail := func(err error) int {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	provider, err := lenovoconsole.ProviderByName(*providerName)
+	if err != nil {
+		return fail(err)
+	}
+	if *passwordFile != "" {
+		if *password, err = readPasswordFile(*passwordFile); err != nil {
+			return fail(err)
+		}
+	}
+	defaults := lenovoconsole.FleetTarget{Username: *username, Password: *password, Provider: provider, Proxy: *proxy}
+	targets, err := fleetTargets(fs.Args()[1:], *targetsFile, defaults)
+	if err != nil {
+		return fail(err)
+	}
+	if len(targets) == 0 {
+		return fail(fmt.Errorf("no targets given"))
+	}
+
+	var op lenovoconsole.FleetOperation
+	switch fs.Arg(0) {
+	case "rp-port":
+		op = lenovoconsole.RPPortOperation()
+	case "info":
+		op = lenovoconsole.InfoOperation()
+	case "check":
+		op = lenovoconsole.CheckOperation(*checkTimeout)
+	case "power":
+		var power lenovoconsole.PowerAction
+		if *action != "" {
+			if power, err = lenovoconsole.ParsePowerAction(*action); err != nil {
+				return fail(err)
+			}
+		}
+		op = lenovoconsole.PowerOperation(power)
+	case "screenshot":
+		if err := os.MkdirAll(*screenshotDir, 0755); err != nil {
+			return fail(err)
+		}
+		browser.IsolatedProfile = true
+		op = lenovoconsole.ScreenshotOperation(lenovoconsole.ConsoleConfig{
+			Browser:    browser,
+			UseFirefox: browser.Name == lenovoconsole.BrowserFirefox,
+		}, *screenshotDir)
+	default:
+		return fail(fmt.Errorf("unknown operation %q", fs.Arg(0)))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	done := 0
+	report := lenovoconsole.RunFleet(ctx, targets, op, lenovoconsole.FleetOptions{
+		Concurrency: *concurrency,
+		Timeout:     *timeout,
+		Retries:     *retries,
+		RetryDelay:  *retryDelay,
+		Progress: func(r lenovoconsole.FleetResult) {
+			done++
+			status := "ok"
+			if !r.Passed {
+				status = "failed"
+			}
+			fmt.Fprintf(os.Stderr, "[%d/%d] %s %s\n", done, len(targets), r.BMCIP, status)
+		},
+	})
+
+	if *jsonOutput {
+		report.WriteJSON(os.Stdout)
+	} else {
+		report.WriteText(os.Stdout)
+	}
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// fleetTargets combines targets given as arguments with those listed in a file
+func fleetTargets(args []string, file string, defaults lenovoconsole.FleetTarget) ([]lenovoconsole.FleetTarget, error) {
+	var targets []lenovoconsole.FleetTarget
+	if file != "" {
+		f := os.Stdin
+		if file != "-" {
+			var err error
+			if f, err = os.Open(file); err != nil {
+				return nil, err
+			}
+			defer f.Close()
+		}
+		listed, err := lenovoconsole.ParseTargets(f, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		targets = append(targets, listed...)
+	}
+	for _, bmc := range args {
+		target := defaults
+		target.BMCIP = bmc
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
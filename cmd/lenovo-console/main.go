@@ -0,0 +1,425 @@
+// Command lenovo-console launches Lenovo XCC remote consoles, serves them on demand
+// from a persistent registry, runs preflight checks against BMCs, drives consoles with
+// scripts and discovers BMCs on a network
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run runs the subcommand named by the first argument, or a console, and returns the
+// exit code. The subcommands return instead of exiting so that their deferred cleanup,
+// such as flushing the audit log, runs.
+func run(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "check":
+			return runCheck(args[1:])
+		case "serve":
+			return runServe(args[1:])
+		case "power":
+			return runPower(args[1:])
+		case "run-script":
+			return runScript(args[1:])
+		case "fleet":
+			return runFleet(args[1:])
+		case "discover":
+			return runDiscover(args[1:])
+		}
+	}
+	return runConsole(args)
+}
+
+// providerUsage is the help text of the -provider flag shared by the subcommands
+var providerUsage = "BMC family: " + strings.Join(lenovoconsole.ProviderNames(), " or ") + " (IMM2 for older System x servers)"
+
+// proxyUsage is the help text of the -proxy flag shared by the subcommands
+const proxyUsage = "reach BMCs through socks5://[user:pass@]host[:port], http://[user:pass@]host[:port] (CONNECT) or ssh://[user@]jumphost[:port]"
+
+// stringList is a flag that may be given several times
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ", ") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func usage(fs *flag.FlagSet) {
+	fmt.Println("Usage: lenovo-console [options] <BMC_IP> <USERNAME> <PASSWORD> [browser]")
+	fmt.Println("       lenovo-console [options] -config <settings.json>")
+	fmt.Println("       lenovo-console check [-json] [-timeout 10s] <BMC_IP> <USERNAME> <PASSWORD>")
+	fmt.Println("       lenovo-console serve [-listen 127.0.0.1:8080] [-registry consoles.json] [-idle-timeout 30m]")
+	fmt.Println("       lenovo-console power [-provider xcc|imm2] <BMC_IP> <USERNAME> <PASSWORD> [on|off|shutdown|restart|reset|cycle]")
+	fmt.Println("       lenovo-console run-script [-report dir] <script.yaml> <BMC_IP> <USERNAME> <PASSWORD>")
+	fmt.Println("       lenovo-console fleet [-targets file] [-concurrency 10] [-json] <rp-port|info|check|power|screenshot> [BMC...]")
+	fmt.Println("       lenovo-console discover [-ports 443] [-proxy socks5://bastion:1080] [-format json|csv|list] <CIDR|IP>...")
+	fmt.Println("Example: lenovo-console 10.145.127.12 USERID PASSW0RD")
+	fmt.Println("         lenovo-console 10.145.127.12 USERID PASSW0RD firefox")
+	fmt.Println("         lenovo-console -browser chromium -app -isolated-profile 10.145.127.12 USERID PASSW0RD")
+	fmt.Println("         lenovo-console check 10.145.127.12 USERID PASSW0RD")
+	fmt.Println("         lenovo-console -provider imm2 10.145.120.7 USERID PASSW0RD")
+	fmt.Println("         lenovo-console -proxy socks5://bastion:1080 10.145.127.12 USERID PASSW0RD")
+	fmt.Println("\nOptions:")
+	fs.SetOutput(os.Stdout)
+	fs.PrintDefaults()
+	fmt.Println("\nNote: Firefox handles BMC connections better than Chrome for this use case")
+}
+
+// runConsole launches a console and runs until SIGINT or SIGTERM, reloading its settings on SIGHUP
+func runConsole(args []string) int {
+	fs := flag.NewFlagSet("lenovo-console", flag.ExitOnError)
+	var browser lenovoconsole.BrowserConfig
+	fs.StringVar(&browser.Name, "browser", "", "browser to launch: chrome, chromium, firefox, edge, brave or default")
+	fs.StringVar(&browser.Command, "browser-cmd", "", "custom browser command; {url} and {profile} are substituted")
+	fs.BoolVar(&browser.IsolatedProfile, "isolated-profile", false, "use a temporary browser profile removed when the console stops")
+	acceptCert := fs.Bool("accept-cert", false, "show the BMC's RP-port certificate fingerprint and trust it in the launched browser after confirmation (implies -isolated-profile)")
+	certFingerprint := fs.String("cert-fingerprint", "", "trust the RP-port certificate without asking if its SHA-256 fingerprint matches (implies -isolated-profile)")
+	fs.BoolVar(&browser.AppMode, "app", false, "open the console in an app window (Chromium-based browsers)")
+	fs.BoolVar(&browser.Kiosk, "kiosk", false, "open the console in kiosk mode")
+	var page lenovoconsole.TemplateConfig
+	fs.StringVar(&page.Layout, "layout", lenovoconsole.LayoutToolbar, "console page layout: toolbar or embed")
+	fs.StringVar(&page.File, "template", "", "path to a custom console page template")
+	viewer := lenovoconsole.DefaultViewerOptions()
+	fs.BoolVar(&viewer.ExclusiveLogin, "exclusive", viewer.ExclusiveLogin, "request exclusive access to the remote console")
+	fs.BoolVar(&viewer.AllowSharingRequests, "allow-sharing", viewer.AllowSharingRequests, "let other users request to share the session")
+	fs.StringVar(&viewer.KeyboardLanguage, "keyboard", viewer.KeyboardLanguage, "keyboard layout of the remote host (e.g. en, de, ja)")
+	fs.IntVar(&viewer.WebSocketTimeout, "ws-timeout", viewer.WebSocketTimeout, "viewer WebSocket timeout in seconds")
+	fs.BoolVar(&viewer.DebugMode, "viewer-debug", viewer.DebugMode, "enable RPViewer debug logging in the browser console")
+	fs.IntVar(&viewer.DebugLevel, "viewer-debug-level", viewer.DebugLevel, "RPViewer debug verbosity")
+	autoReconnect := fs.Bool("auto-reconnect", true, "reconnect automatically after BMC reboots and firmware updates")
+	noBrowser := fs.Bool("no-browser", false, "print the console URL and an SSH forwarding hint instead of opening a browser")
+	showLAN := fs.Bool("lan-url", false, "with -no-browser, also print the console URL on this machine's network address (plain HTTP, the page contains the BMC password)")
+	showQR := fs.Bool("qr", false, "with -lan-url, also print a QR code of that URL")
+	stopOnClose := fs.Bool("exit-on-close", false, "stop the console and exit when the browser window is closed (implies -isolated-profile)")
+	idleTimeout := fs.Duration("idle-timeout", 0, "stop the console after this long without page loads or input (0 to disable)")
+	maxSession := fs.Duration("max-session", 0, "stop the console this long after it starts (0 to disable)")
+	pasteRate := fs.Int("paste-rate", 0, "key presses per second when pasting text into the console (default 20)")
+	var watchPatterns stringList
+	fs.Var(&watchPatterns, "watch-screen", "report when text matching this regular expression appears on screen; repeatable, needs tesseract")
+	watchInterval := fs.Duration("watch-interval", 10*time.Second, "time between screen captures for -watch-screen")
+	providerName := fs.String("provider", lenovoconsole.ProviderXCC, providerUsage)
+	proxy := fs.String("proxy", "", proxyUsage)
+	serverPort := fs.Int("port", 0, "local server port (0 for auto-assign)")
+	settingsFile := fs.String("config", "", "JSON settings file, re-read on SIGHUP")
+	passwordFile := fs.String("password-file", "", "read the BMC password from a file, re-read on SIGHUP")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 10*time.Second, "how long to wait for a clean shutdown")
+	openAudit := auditFlags(fs)
+	fs.Usage = func() { usage(fs) }
+	fs.Parse(args)
+
+	if fs.NArg() < 3 && *settingsFile == "" {
+		fs.Usage()
+		return 1
+	}
+
+	// An optional fourth argument names the browser, e.g. "firefox"
+	if fs.NArg() > 3 && browser.Name == "" {
+		browser.Name = strings.ToLower(fs.Arg(3))
+	}
+
+	screenWatch := lenovoconsole.ScreenWatch{Interval: *watchInterval}
+	for _, p := range watchPatterns {
+		pattern, err := regexp.Compile(p)
+		if err != nil {
+			fmt.Printf("Error: invalid -watch-screen pattern: %v\n", err)
+			return 1
+		}
+		screenWatch.Patterns = append(screenWatch.Patterns, pattern)
+	}
+
+	audit, err := openAudit()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	if audit.Log != nil {
+		defer audit.Log.Close()
+	}
+
+	// loadConfig builds the console configuration from flags, arguments and settings files.
+	// It is called again on SIGHUP so that edited credentials and settings take effect.
+	loadConfig := func() (lenovoconsole.ConsoleConfig, error) {
+		v := viewer
+		config := lenovoconsole.ConsoleConfig{
+			BMCIP:      fs.Arg(0),
+			Username:   fs.Arg(1),
+			Password:   fs.Arg(2),
+			ServerPort: *serverPort,
+			Proxy:      *proxy,
+			Browser:    browser,
+
+			StopOnBrowserClose: *stopOnClose,
+			NoBrowser:          *noBrowser,
+			ShowLANURL:         *showLAN,
+			ShowQRCode:         *showQR,
+			Template:           page,
+			Viewer:             &v,
+			Reconnect:          lenovoconsole.ReconnectPolicy{Enabled: *autoReconnect},
+			IdleTimeout:        *idleTimeout,
+			MaxSessionDuration: *maxSession,
+			PasteRate:          *pasteRate,
+			ScreenWatch:        screenWatch,
+			Audit:              audit,
+
+			CertificateFingerprint: *certFingerprint,
+		}
+		name := *providerName
+		if *settingsFile != "" {
+			if err := applySettingsFile(*settingsFile, &config, &name); err != nil {
+				return config, err
+			}
+		}
+		provider, err := lenovoconsole.ProviderByName(name)
+		if err != nil {
+			return config, err
+		}
+		config.Provider = provider
+		if *passwordFile != "" {
+			password, err := readPasswordFile(*passwordFile)
+			if err != nil {
+				return config, err
+			}
+			config.Password = password
+		}
+		config.UseFirefox = config.Browser.Name == lenovoconsole.BrowserFirefox
+		if *acceptCert || config.CertificateFingerprint != "" {
+			// Certificate exceptions are installed into a profile owned by the console
+			config.Browser.IsolatedProfile = true
+		}
+		if config.StopOnBrowserClose {
+			// Only browsers on the console's own profile are watched
+			config.Browser.IsolatedProfile = true
+		}
+		if config.BMCIP == "" || config.Username == "" {
+			return config, fmt.Errorf("BMC address and username are required")
+		}
+		if _, err := lenovoconsole.NewDialer(config.Proxy); err != nil {
+			return config, err
+		}
+		return config, nil
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	// Catch signals before launching, so that Ctrl+C during the launch shuts down the
+	// console and its browser once they are up instead of killing the process between
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	console, err := launchConsole(config, *acceptCert, signals)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Println("\nThis console window will remain active. You can:")
+	fmt.Println("  - Launch another instance for a different BMC in a new terminal")
+	fmt.Println("  - Press Ctrl+C in this terminal to close this specific console")
+	fmt.Printf("  - Send SIGHUP (kill -HUP %d) to reload credentials and settings\n", os.Getpid())
+
+	for {
+		var browserClosed <-chan struct{}
+		if *stopOnClose && !console.Headless() {
+			browserClosed = console.BrowserClosed()
+		}
+
+		select {
+		case <-browserClosed:
+			fmt.Println("Browser closed, console stopped")
+			return 0
+
+		case <-console.Stopped():
+			fmt.Println("✓ Console stopped")
+			return 0
+
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				fmt.Printf("\nReceived %s, shutting down...\n", sig)
+				if err := shutdownConsole(console, *shutdownTimeout); err != nil {
+					fmt.Printf("Error during shutdown: %v\n", err)
+					return 1
+				}
+				fmt.Println("✓ Console stopped")
+				return 0
+			}
+
+			fmt.Println("\nReceived SIGHUP, reloading settings...")
+			newConfig, err := loadConfig()
+			if err != nil {
+				fmt.Printf("Reload failed, keeping current console: %v\n", err)
+				continue
+			}
+			if reflect.DeepEqual(newConfig, config) {
+				fmt.Println("Settings unchanged, console left running")
+				continue
+			}
+
+			// Reuse the local port so bookmarks and SSH forwards keep working
+			if newConfig.ServerPort == 0 && newConfig.BMCIP == config.BMCIP {
+				newConfig.ServerPort = console.GetPort()
+			}
+			if err := shutdownConsole(console, *shutdownTimeout); err != nil {
+				fmt.Printf("Warning: old console did not stop cleanly: %v\n", err)
+			}
+			restarted, err := launchConsole(newConfig, *acceptCert, signals)
+			if err != nil {
+				fmt.Printf("Error: restart failed: %v\n", err)
+				return 1
+			}
+			console, config = restarted, newConfig
+			fmt.Println("✓ Console restarted with new settings")
+		}
+	}
+}
+
+// launchConsole discovers the RP port, launches the console and reports how it was opened.
+// With confirmCert, the RP-port certificate is shown and trusted in the browser if the
+// operator confirms it; a signal on signals declines it and is left for the caller.
+func launchConsole(config lenovoconsole.ConsoleConfig, confirmCert bool, signals chan os.Signal) (*lenovoconsole.Console, error) {
+	fmt.Printf("Connecting to %s at %s...\n", config.Provider.Title(), config.BMCIP)
+
+	if config.Proxy != "" {
+		fmt.Printf("Using proxy %s\n", config.Proxy)
+	}
+	if confirmCert && config.CertificateFingerprint == "" {
+		config.ConfirmCertificate = func(cert lenovoconsole.CertificateInfo) bool {
+			return confirmCertificate(cert, signals)
+		}
+	}
+	// The console reaches the BMC with its settings, including the proxy, before it starts
+	console := lenovoconsole.NewConsole(config)
+	go logEvents(console)
+
+	rpPort := config.RPPort
+	if rpPort == 0 {
+		fmt.Println("Getting remote presence port...")
+		var err error
+		rpPort, err = console.DiscoverRPPort()
+		if err != nil {
+			fmt.Printf("Warning: Could not get RP port, using default 3900: %v\n", err)
+			rpPort = 3900
+		}
+		console.SetRPPort(rpPort)
+	}
+	fmt.Printf("✓ RP Port: %d\n", rpPort)
+
+	if info, err := console.Info(); err != nil {
+		fmt.Printf("Warning: Could not identify the server: %v\n", err)
+	} else {
+		fmt.Printf("✓ Server: %s\n", info.Summary())
+	}
+
+	// Show who else is on the console before connecting
+	if sessions, err := console.ActiveSessions(); err != nil {
+		fmt.Printf("Warning: Could not query active sessions: %v\n", err)
+	} else if len(sessions) > 0 {
+		fmt.Printf("Active remote console sessions on %s:\n", config.BMCIP)
+		for _, s := range sessions {
+			fmt.Printf("  - %s from %s\n", s.UserName, s.ClientAddress)
+		}
+	}
+
+	if err := console.LaunchAndOpen(); err != nil {
+		return nil, err
+	}
+
+	// Print browser-specific instructions
+	switch {
+	case console.Headless():
+	case config.UseFirefox:
+		fmt.Println("\n✓ Firefox launched")
+		fmt.Println("  Firefox handles BMC connections well")
+	case config.Browser.Command != "":
+		fmt.Println("\n✓ Custom browser command launched")
+	case config.Browser.Name != "":
+		fmt.Printf("\n✓ %s launched\n", config.Browser.Name)
+	default:
+		fmt.Println("\n✓ Browser launched")
+	}
+
+	fmt.Println("\nNote: The browser must be able to reach the XCC at:", config.BMCIP)
+	fmt.Printf("Screenshots: curl -H 'X-Console-Token: %s' -o screen.png %s/api/screenshot\n",
+		console.Token(), console.GetURL())
+	return console, nil
+}
+
+// confirmCertificate shows the RP-port certificate and asks on the terminal whether to trust
+// it. A signal while waiting for the answer declines and is sent back on signals.
+func confirmCertificate(cert lenovoconsole.CertificateInfo, signals chan os.Signal) bool {
+	fmt.Printf("\nBMC certificate on %s:%d\n", cert.Host, cert.Port)
+	fmt.Printf("  Subject:  %s\n", cert.Subject)
+	fmt.Printf("  Issuer:   %s\n", cert.Issuer)
+	fmt.Printf("  Valid:    %s to %s\n", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+	fmt.Printf("  SHA-256:  %s\n", cert.Fingerprint)
+	fmt.Print("Trust this certificate in the console browser? [y/N] ")
+
+	answers := make(chan string, 1)
+	go func() {
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answers <- answer
+	}()
+	var answer string
+	select {
+	case answer = <-answers:
+	case sig := <-signals:
+		fmt.Println()
+		signals <- sig
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		fmt.Println("✓ Certificate trusted")
+		return true
+	}
+	fmt.Println("Certificate not trusted; accept it in the browser instead")
+	return false
+}
+
+// shutdownConsole stops a console, giving it at most timeout to finish
+func shutdownConsole(console *lenovoconsole.Console, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return console.Shutdown(ctx)
+}
+
+// logEvents prints session and reconnect events as they happen
+func logEvents(console *lenovoconsole.Console) {
+	events, _ := console.Subscribe()
+	for e := range events {
+		switch e.Type {
+		case lenovoconsole.EventLoginResult:
+			fmt.Printf("[%s] Login: %s\n", e.Time.Format("15:04:05"), e.Message)
+		case lenovoconsole.EventSessionTerminated:
+			fmt.Printf("[%s] Session terminated: %s\n", e.Time.Format("15:04:05"), e.Message)
+		case lenovoconsole.EventReconnectAttempt:
+			fmt.Printf("[%s] Reconnect attempt %d: %s\n", e.Time.Format("15:04:05"), e.Attempt, e.Message)
+		case lenovoconsole.EventReconnectRetryFailed:
+			fmt.Printf("[%s] Reconnect attempt %d failed: %s\n", e.Time.Format("15:04:05"), e.Attempt, e.Message)
+		case lenovoconsole.EventReconnected, lenovoconsole.EventReconnectFailed,
+			lenovoconsole.EventLimitWarning, lenovoconsole.EventLimitReached:
+			fmt.Printf("[%s] %s\n", e.Time.Format("15:04:05"), e.Message)
+		case lenovoconsole.EventScreenMatch:
+			fmt.Printf("[%s] Screen matched /%s/: %s\n", e.Time.Format("15:04:05"), e.Pattern, e.Message)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole"
+)
+
+// runPower implements the "power" subcommand and returns the process exit code.
+// Without an action it prints the server's power state.
+func runPower(args []string) int {
+	fs := flag.NewFlagSet("power", flag.ExitOnError)
+	providerName := fs.String("provider", lenovoconsole.ProviderXCC, providerUsage)
+	proxy := fs.String("proxy", "", proxyUsage)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lenovo-console power [-provider xcc|imm2] [-proxy URL] <BMC_IP> <USERNAME> <PASSWORD> [on|off|shutdown|restart|reset|cycle]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 3 && fs.NArg() != 4 {
+		fs.Usage()
+		return 2
+	}
+	provider, err := lenovoconsole.ProviderByName(*providerName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	console := lenovoconsole.NewConsole(lenovoconsole.ConsoleConfig{
+		BMCIP:    fs.Arg(0),
+		Username: fs.Arg(1),
+		Password: fs.Arg(2),
+		Provider: provider,
+		Proxy:    *proxy,
+	})
+
+	if fs.NArg() == 4 {
+		action, err := lenovoconsole.ParsePowerAction(fs.Arg(3))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+		if err := console.Power(action); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Printf("✓ %s sent to %s\n", action, fs.Arg(0))
+		return 0
+	}
+
+	state, err := console.PowerState()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%s: %s\n", fs.Arg(0), state)
+	return 0
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole"
+)
+
+// runScript implements the "run-script" subcommand: it opens a console, runs a YAML
+// script through it and writes a report with a screenshot per step. It returns the
+// process exit code: 0 if every step passed, 1 if a step failed.
+func runScript(args []string) int {
+	fs := flag.NewFlagSet("run-script", flag.ExitOnError)
+	providerName := fs.String("provider", lenovoconsole.ProviderXCC, providerUsage)
+	proxy := fs.String("proxy", "", proxyUsage)
+	browserName := fs.String("browser", "", "browser to launch: chrome, chromium, firefox, edge, brave or default")
+	noBrowser := fs.Bool("no-browser", false, "print the console URL and wait for it to be opened instead of launching a browser")
+	keyboard := fs.String("keyboard", lenovoconsole.DefaultViewerOptions().KeyboardLanguage, "keyboard layout of the remote host (e.g. en, de, fr)")
+	certFingerprint := fs.String("cert-fingerprint", "", "trust the RP-port certificate if its SHA-256 fingerprint matches")
+	reportDir := fs.String("report", "", "directory for report.json, report.html and screenshots (default: <script>-<time>)")
+	pageTimeout := fs.Duration("page-timeout", 2*time.Minute, "how long to wait for the console page to open")
+	poll := fs.Duration("poll", 2*time.Second, "time between screen captures while waiting for text")
+	pasteRate := fs.Int("paste-rate", 0, "key presses per second for send-keys (default 20)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lenovo-console run-script [options] <script.yaml> <BMC_IP> <USERNAME> <PASSWORD>")
+		fmt.Fprintln(fs.Output(), "Steps: wait-for-text, send-keys, sleep, power, power-cycle, screenshot; text is read with tesseract")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 4 {
+		fs.Usage()
+		return 2
+	}
+	script, err := lenovoconsole.LoadScript(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	provider, err := lenovoconsole.ProviderByName(*providerName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	if *reportDir == "" {
+		*reportDir = fmt.Sprintf("%s-%s", script.Name, time.Now().Format("20060102-150405"))
+	}
+
+	viewer := lenovoconsole.DefaultViewerOptions()
+	viewer.KeyboardLanguage = *keyboard
+	config := lenovoconsole.ConsoleConfig{
+		BMCIP:    fs.Arg(1),
+		Username: fs.Arg(2),
+		Password: fs.Arg(3),
+		Provider: provider,
+		Proxy:    *proxy,
+		// The script's browser is owned by the console and closed with it
+		Browser:   lenovoconsole.BrowserConfig{Name: *browserName, IsolatedProfile: true},
+		NoBrowser: *noBrowser,
+		Viewer:    &viewer,
+		PasteRate: *pasteRate,
+
+		CertificateFingerprint: *certFingerprint,
+	}
+	config.UseFirefox = config.Browser.Name == lenovoconsole.BrowserFirefox
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	console, err := launchConsole(config, false, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer shutdownConsole(console, 10*time.Second)
+
+	if err := waitForPage(ctx, console, *pageTimeout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\nRunning %s (%d steps)\n", script.Name, len(script.Steps))
+	report, err := console.RunScript(ctx, script, lenovoconsole.ScriptOptions{
+		PollInterval: *poll,
+		Progress: func(r lenovoconsole.ScriptStepResult) {
+			mark := "✓"
+			if !r.Passed {
+				mark = "✗"
+			}
+			fmt.Printf("%s %d. %s %s (%d ms)", mark, r.Step, r.Action, r.Argument, r.DurationMS)
+			if r.Detail != "" {
+				fmt.Printf(": %s", r.Detail)
+			}
+			fmt.Println()
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Script interrupted: %v\n", err)
+	}
+	if err := report.WriteDir(*reportDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot write report: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Report: %s\n", *reportDir)
+	if !report.Passed {
+		return 1
+	}
+	return 0
+}
+
+// waitForPage waits until a console page is open to type into and capture
+func waitForPage(ctx context.Context, console *lenovoconsole.Console, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for console.ConnectedPages() == 0 {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no console page opened within %s", timeout)
+		}
+		select {
+		case <-time.After(500 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-console.Stopped():
+			return fmt.Errorf("console stopped")
+		}
+	}
+	return nil
+}
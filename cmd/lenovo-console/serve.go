@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole"
+)
+
+// runServe implements the "serve" subcommand and returns the process exit code
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8080", "address of the console index and management API")
+	registry := fs.String("registry", "consoles.json", "file the console registry is persisted to")
+	idleTimeout := fs.Duration("idle-timeout", 30*time.Minute, "stop consoles with no open pages for this long (0 to disable)")
+	maxSession := fs.Duration("max-session", 0, "stop each console this long after it starts (0 to disable)")
+	portMin := fs.Int("port-min", 0, "first local port for console servers (0 for auto-assign)")
+	portMax := fs.Int("port-max", 0, "last local port for console servers")
+	wallTiles := fs.Int("wall-max-tiles", 12, "most thumbnails on the console wall; each holds a viewer session on its BMC")
+	tokenFile := fs.String("api-token-file", "", "read the management API token from a file (default: generate one and print it)")
+	var page lenovoconsole.TemplateConfig
+	fs.StringVar(&page.Layout, "layout", lenovoconsole.LayoutToolbar, "console page layout: toolbar or embed")
+	fs.StringVar(&page.File, "template", "", "path to a custom console page template")
+	viewer := lenovoconsole.DefaultViewerOptions()
+	fs.StringVar(&viewer.KeyboardLanguage, "keyboard", viewer.KeyboardLanguage, "keyboard layout of the remote hosts (e.g. en, de, ja)")
+	proxy := fs.String("proxy", "", proxyUsage)
+	shutdownTimeout := fs.Duration("shutdown-timeout", 10*time.Second, "how long to wait for a clean shutdown")
+	openAudit := auditFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lenovo-console serve [-listen 127.0.0.1:8080] [-registry consoles.json] [-idle-timeout 30m]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if _, err := lenovoconsole.NewDialer(*proxy); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	if *portMin > 0 && *portMax == 0 {
+		*portMax = *portMin + 99
+	}
+	// Console pages embed BMC passwords, so they are only served where the index is
+	listenHost, _, err := net.SplitHostPort(*listen)
+	if err != nil {
+		fmt.Printf("Error: invalid -listen address: %v\n", err)
+		return 1
+	}
+	var apiToken string
+	if *tokenFile != "" {
+		if apiToken, err = readPasswordFile(*tokenFile); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+	}
+
+	audit, err := openAudit()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	if audit.Log != nil {
+		defer audit.Log.Close()
+	}
+
+	manager, err := lenovoconsole.NewManager(lenovoconsole.ManagerConfig{
+		RegistryPath: *registry,
+		IdleTimeout:  *idleTimeout,
+		PortMin:      *portMin,
+		PortMax:      *portMax,
+		ListenHost:   listenHost,
+		APIToken:     apiToken,
+		WallMaxTiles: *wallTiles,
+		Console: lenovoconsole.ConsoleConfig{
+			Template:  page,
+			Viewer:    &viewer,
+			Reconnect: lenovoconsole.ReconnectPolicy{Enabled: true},
+			Proxy:     *proxy,
+
+			MaxSessionDuration: *maxSession,
+			Audit:              audit,
+		},
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	server := &http.Server{Addr: *listen, Handler: manager.Handler()}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.ListenAndServe() }()
+
+	fmt.Printf("✓ Serving %d registered console(s) at http://%s/\n", len(manager.Entries()), *listen)
+	fmt.Printf("  Registry: %s\n", *registry)
+	fmt.Printf("  Console wall: http://%s/wall\n", *listen)
+	if *tokenFile == "" {
+		fmt.Printf("  API token: %s (send it in the X-Console-Token header to add or remove consoles)\n", manager.APIToken())
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+	case sig := <-signals:
+		fmt.Printf("\nReceived %s, shutting down...\n", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	server.Shutdown(ctx)
+	if err := manager.Shutdown(ctx); err != nil {
+		fmt.Printf("Error during shutdown: %v\n", err)
+		return 1
+	}
+	fmt.Println("✓ All consoles stopped")
+	return 0
+}
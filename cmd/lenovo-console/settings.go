@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole"
+)
+
+// settingsFile is the JSON settings file accepted by -config.
+// Fields that are set override the command line; the file is re-read on SIGHUP.
+type settingsFile struct {
+	BMCIP           string `json:"bmc_ip"`
+	Provider        string `json:"provider"`
+	Proxy           string `json:"proxy"`
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	PasswordFile    string `json:"password_file"`
+	ServerPort      int    `json:"server_port"`
+	Browser         string `json:"browser"`
+	Layout          string `json:"layout"`
+	Template        string `json:"template"`
+	Keyboard        string `json:"keyboard"`
+	Exclusive       *bool  `json:"exclusive"`
+	IdleTimeout     string `json:"idle_timeout"`
+	MaxSession      string `json:"max_session"`
+	CertFingerprint string `json:"cert_fingerprint"`
+}
+
+// applySettingsFile reads a settings file and applies it on top of config and the
+// provider name
+func applySettingsFile(path string, config *lenovoconsole.ConsoleConfig, provider *string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read settings: %v", err)
+	}
+	var s settingsFile
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid settings file %s: %v", path, err)
+	}
+
+	setString(&config.BMCIP, s.BMCIP)
+	setString(provider, s.Provider)
+	setString(&config.Proxy, s.Proxy)
+	setString(&config.Username, s.Username)
+	setString(&config.Password, s.Password)
+	setString(&config.Browser.Name, s.Browser)
+	setString(&config.Template.Layout, s.Layout)
+	setString(&config.Template.File, s.Template)
+	setString(&config.CertificateFingerprint, s.CertFingerprint)
+	if s.ServerPort != 0 {
+		config.ServerPort = s.ServerPort
+	}
+	if config.Viewer != nil {
+		setString(&config.Viewer.KeyboardLanguage, s.Keyboard)
+		if s.Exclusive != nil {
+			config.Viewer.ExclusiveLogin = *s.Exclusive
+		}
+	}
+	if err := setDuration(&config.IdleTimeout, s.IdleTimeout); err != nil {
+		return fmt.Errorf("invalid idle_timeout in %s: %v", path, err)
+	}
+	if err := setDuration(&config.MaxSessionDuration, s.MaxSession); err != nil {
+		return fmt.Errorf("invalid max_session in %s: %v", path, err)
+	}
+	if s.PasswordFile != "" {
+		password, err := readPasswordFile(s.PasswordFile)
+		if err != nil {
+			return err
+		}
+		config.Password = password
+	}
+	return nil
+}
+
+// readPasswordFile returns the first line of a password file
+func readPasswordFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file: %v", err)
+	}
+	password, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimRight(password, "\r"), nil
+}
+
+// setString overwrites dst when value is not empty
+func setString(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+// setDuration overwrites dst with a parsed duration when value is not empty
+func setDuration(dst *time.Duration, value string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*dst = d
+	return nil
+}
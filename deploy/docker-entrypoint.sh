@@ -0,0 +1,25 @@
+#!/bin/sh
+# Container entrypoint for lenovo-console.
+#
+# With no arguments, or with arguments starting with "-", the console server is
+# started with its registry in /data. Any other arguments run lenovo-console
+# directly, e.g. "check 10.0.0.10 USERID PASSW0RD".
+#
+# The console list and console pages have no authentication and hand out BMC
+# sessions, so like the systemd unit the server only listens on the loopback
+# interface. Changes through the management API need the token printed at startup. To publish
+# it, opt in with LISTEN=0.0.0.0:8080 behind a firewall or an authenticating
+# reverse proxy.
+set -e
+
+if [ "$#" -eq 0 ] || [ "${1#-}" != "$1" ]; then
+    set -- serve \
+        -listen "${LISTEN:-127.0.0.1:8080}" \
+        -registry "${REGISTRY:-/data/consoles.json}" \
+        -idle-timeout "${IDLE_TIMEOUT:-30m}" \
+        -port-min "${PORT_MIN:-9000}" \
+        -port-max "${PORT_MAX:-9099}" \
+        "$@"
+fi
+
+exec lenovo-console "$@"
@@ -2,8 +2,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
 	"time"
 
 	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole"
@@ -18,6 +21,12 @@ func main() {
 
 	// Example 3: Programmatic control
 	// programmaticExample()
+
+	// Example 4: Many consoles on one wall page
+	// consoleWallExample()
+
+	// Example 5: Preflight checks across an inventory
+	// fleetCheckExample()
 }
 
 // simpleConsoleExample demonstrates basic console usage
@@ -96,6 +105,50 @@ func multipleConsolesExample() {
 	select {}
 }
 
+// consoleWallExample serves many consoles from one local server. The wall page shows a
+// thumbnail of each console; clicking a tile opens the full interactive console.
+func consoleWallExample() {
+	manager, err := lenovoconsole.NewManager(lenovoconsole.ManagerConfig{IdleTimeout: 30 * time.Minute})
+	if err != nil {
+		log.Fatalf("Failed to create manager: %v", err)
+	}
+
+	for i := 12; i <= 35; i++ {
+		entry := lenovoconsole.RegistryEntry{
+			Name:     fmt.Sprintf("node%02d", i),
+			BMCIP:    fmt.Sprintf("10.145.127.%d", i),
+			Username: "admin",
+			Password: "password",
+			Tags:     []string{fmt.Sprintf("rack%d", i/12)},
+		}
+		if err := manager.Add(entry); err != nil {
+			log.Fatalf("Failed to register %s: %v", entry.Name, err)
+		}
+	}
+
+	fmt.Println("Console wall: http://127.0.0.1:8080/wall")
+	fmt.Println("Rack 2 only:  http://127.0.0.1:8080/wall?tags=rack2&refresh=2")
+	log.Fatal(http.ListenAndServe("127.0.0.1:8080", manager.Handler()))
+}
+
+// fleetCheckExample runs preflight checks against many BMCs in parallel, retrying
+// flaky ones, and prints a summary table instead of stopping at the first error
+func fleetCheckExample() {
+	var targets []lenovoconsole.FleetTarget
+	for i := 12; i <= 35; i++ {
+		targets = append(targets, lenovoconsole.FleetTarget{
+			BMCIP:    fmt.Sprintf("10.145.127.%d", i),
+			Username: "admin",
+			Password: "password",
+		})
+	}
+
+	report := lenovoconsole.RunFleet(context.Background(), targets,
+		lenovoconsole.CheckOperation(lenovoconsole.DefaultCheckTimeout),
+		lenovoconsole.FleetOptions{Concurrency: 8, Timeout: 2 * time.Minute, Retries: 1})
+	report.WriteText(os.Stdout)
+}
+
 // programmaticExample demonstrates more fine-grained control
 func programmaticExample() {
 	config := lenovoconsole.ConsoleConfig{
@@ -1,3 +1,8 @@
 module github.com/huyanhvn/lenovo-remote-console
 
 go 1.21
+
+require (
+	gopkg.in/yaml.v3 v3.0.1
+	rsc.io/qr v0.2.0
+)
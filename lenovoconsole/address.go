@@ -0,0 +1,159 @@
+package lenovoconsole
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultHTTPSPort is the port of the BMC web interface, Redfish and the SDK
+const defaultHTTPSPort = 443
+
+// BMCAddress is a parsed BMC address: a host name, IPv4 address or IPv6 address and the
+// port of its HTTPS interface
+type BMCAddress struct {
+	Host string // Host name or IP address without brackets; IPv6 may carry a zone, e.g. "fe80::1%eth0"
+	Port int    // HTTPS port (443 unless given)
+}
+
+// validHostName matches DNS host names
+var validHostName = regexp.MustCompile(`^[a-z0-9_]([a-z0-9_-]*[a-z0-9_])?(\.[a-z0-9_]([a-z0-9_-]*[a-z0-9_])?)*$`)
+
+// ParseBMCAddress parses and normalizes a BMC address as accepted by ConsoleConfig.BMCIP:
+// "10.0.0.1", "xcc01.example.com", "xcc01:8443", "fd00::1", "[fd00::1]:8443",
+// "fe80::1%eth0" or "https://[fd00::1]/". Host names are lowercased and IPv6 addresses
+// written in their shortest form.
+func ParseBMCAddress(s string) (BMCAddress, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return BMCAddress{}, fmt.Errorf("invalid BMC address %q: %v", s, err)
+		}
+		if u.Scheme != "https" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+			return BMCAddress{}, fmt.Errorf("invalid BMC address %q: want an https:// URL without a path", s)
+		}
+		s = u.Host
+	}
+	if s == "" {
+		return BMCAddress{}, fmt.Errorf("BMC address is empty")
+	}
+
+	host, port := s, ""
+	switch {
+	case strings.HasPrefix(s, "["):
+		if strings.HasSuffix(s, "]") {
+			host = s[1 : len(s)-1]
+		} else {
+			var err error
+			if host, port, err = net.SplitHostPort(s); err != nil {
+				return BMCAddress{}, fmt.Errorf("invalid BMC address %q: %v", s, err)
+			}
+		}
+	case strings.Count(s, ":") == 1:
+		host, port, _ = net.SplitHostPort(s)
+	}
+
+	addr := BMCAddress{Port: defaultHTTPSPort}
+	if port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			return BMCAddress{}, fmt.Errorf("invalid BMC address %q: bad port %q", s, port)
+		}
+		addr.Port = n
+	}
+
+	ipPart, zone := host, ""
+	i := strings.LastIndex(host, "%")
+	if i >= 0 {
+		ipPart, zone = host[:i], host[i+1:]
+	}
+	if ip := net.ParseIP(ipPart); ip != nil {
+		if i >= 0 && (zone == "" || ip.To4() != nil) {
+			return BMCAddress{}, fmt.Errorf("invalid BMC address %q: zones are only valid for IPv6", s)
+		}
+		addr.Host = ip.String()
+		if zone != "" {
+			addr.Host += "%" + zone
+		}
+		return addr, nil
+	}
+	if i >= 0 || strings.Contains(host, ":") {
+		return BMCAddress{}, fmt.Errorf("invalid BMC address %q", s)
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if len(host) > 253 || !validHostName.MatchString(host) {
+		return BMCAddress{}, fmt.Errorf("invalid BMC address %q: not a host name or IP address", s)
+	}
+	addr.Host = host
+	return addr, nil
+}
+
+// parseBMCAddressLenient parses s, or uses it unchanged as the host if it is invalid so
+// that the error surfaces from the request made with it
+func parseBMCAddressLenient(s string) BMCAddress {
+	if addr, err := ParseBMCAddress(s); err == nil {
+		return addr
+	}
+	return BMCAddress{Host: s, Port: defaultHTTPSPort}
+}
+
+// String returns the normalized address: the host alone for the default HTTPS port and
+// host:port otherwise, with IPv6 addresses in brackets
+func (a BMCAddress) String() string {
+	if a.Port == defaultHTTPSPort {
+		return a.Host
+	}
+	return net.JoinHostPort(a.Host, strconv.Itoa(a.Port))
+}
+
+// IsIPv6 reports whether the host is an IPv6 address
+func (a BMCAddress) IsIPv6() bool {
+	return strings.Contains(a.Host, ":")
+}
+
+// HostPort returns the address of the HTTPS interface for dialing
+func (a BMCAddress) HostPort() string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(a.Port))
+}
+
+// Dial returns the address of another port on the BMC, such as the RP port, for dialing
+func (a BMCAddress) Dial(port int) string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(port))
+}
+
+// URLHostname returns the host as written in a URL: IPv6 addresses in brackets with the
+// zone escaped
+func (a BMCAddress) URLHostname() string {
+	if !a.IsIPv6() {
+		return a.Host
+	}
+	return "[" + strings.Replace(a.Host, "%", "%25", 1) + "]"
+}
+
+// Origin returns the HTTPS origin of the BMC, e.g. "https://[fd00::1]:8443"
+func (a BMCAddress) Origin() string {
+	if a.Port == defaultHTTPSPort {
+		return "https://" + a.URLHostname()
+	}
+	return "https://" + a.URLHostname() + ":" + strconv.Itoa(a.Port)
+}
+
+// URL returns the HTTPS URL of path on the BMC
+func (a BMCAddress) URL(path string) string {
+	return a.Origin() + path
+}
+
+// bmc returns the console's BMC address
+func (c *Console) bmc() BMCAddress {
+	return parseBMCAddressLenient(c.config.BMCIP)
+}
+
+// bmcURL returns the HTTPS URL of path on the BMC at address
+func bmcURL(address, path string) string {
+	return parseBMCAddressLenient(address).URL(path)
+}
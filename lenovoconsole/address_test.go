@@ -0,0 +1,116 @@
+package lenovoconsole
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+func TestParseBMCAddress(t *testing.T) {
+	tests := []struct {
+		in                    string
+		host                  string
+		port                  int
+		str, origin, hostPort string
+	}{
+		{"10.0.0.1", "10.0.0.1", 443, "10.0.0.1", "https://10.0.0.1", "10.0.0.1:443"},
+		{" 10.0.0.1:8443 ", "10.0.0.1", 8443, "10.0.0.1:8443", "https://10.0.0.1:8443", "10.0.0.1:8443"},
+		{"XCC01.Example.com.", "xcc01.example.com", 443, "xcc01.example.com", "https://xcc01.example.com", "xcc01.example.com:443"},
+		{"xcc01:443", "xcc01", 443, "xcc01", "https://xcc01", "xcc01:443"},
+		{"fd00:0::1", "fd00::1", 443, "fd00::1", "https://[fd00::1]", "[fd00::1]:443"},
+		{"[fd00::1]", "fd00::1", 443, "fd00::1", "https://[fd00::1]", "[fd00::1]:443"},
+		{"[fd00::1]:8443", "fd00::1", 8443, "[fd00::1]:8443", "https://[fd00::1]:8443", "[fd00::1]:8443"},
+		{"fe80::1%eth0", "fe80::1%eth0", 443, "fe80::1%eth0", "https://[fe80::1%25eth0]", "[fe80::1%eth0]:443"},
+		{"[fe80::1%eth0]:8443", "fe80::1%eth0", 8443, "[fe80::1%eth0]:8443", "https://[fe80::1%25eth0]:8443", "[fe80::1%eth0]:8443"},
+		{"https://[fe80::1%25eth0]/", "fe80::1%eth0", 443, "fe80::1%eth0", "https://[fe80::1%25eth0]", "[fe80::1%eth0]:443"},
+		{"https://xcc01:8443", "xcc01", 8443, "xcc01:8443", "https://xcc01:8443", "xcc01:8443"},
+	}
+	for _, tt := range tests {
+		addr, err := ParseBMCAddress(tt.in)
+		if err != nil {
+			t.Errorf("ParseBMCAddress(%q): %v", tt.in, err)
+			continue
+		}
+		if addr.Host != tt.host || addr.Port != tt.port || addr.String() != tt.str || addr.Origin() != tt.origin || addr.HostPort() != tt.hostPort {
+			t.Errorf("ParseBMCAddress(%q) = %+v (%s, %s, %s)", tt.in, addr, addr, addr.Origin(), addr.HostPort())
+		}
+		if again, err := ParseBMCAddress(addr.String()); err != nil || again != addr {
+			t.Errorf("%q does not parse back: %+v, %v", addr.String(), again, err)
+		}
+	}
+
+	for _, in := range []string{"", "10.0.0.1:0", "10.0.0.1:https", "xcc01:70000", "10.0.0.1%eth0", "fe80::1%",
+		"[fd00::1", "bad host", "http://10.0.0.1", "https://10.0.0.1/ui", "xcc_01..example", "gg::1"} {
+		if addr, err := ParseBMCAddress(in); err == nil {
+			t.Errorf("ParseBMCAddress(%q) = %+v, want an error", in, addr)
+		}
+	}
+}
+
+func TestIPv6BMC(t *testing.T) {
+	if l, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	} else {
+		l.Close()
+	}
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{IPv6: true, RPPort: 3912})
+	defer xcc.Close()
+	if !strings.HasPrefix(xcc.Addr, "[::1]:") {
+		t.Fatalf("fake listens on %s", xcc.Addr)
+	}
+
+	if port, _ := GetRPPort(xcc.Addr, lenovoconsoletest.DefaultUsername, lenovoconsoletest.DefaultPassword); port != 3912 {
+		t.Errorf("GetRPPort = %d, want 3912", port)
+	}
+	if _, err := GetBMCInfo(xcc.Addr, lenovoconsoletest.DefaultUsername, lenovoconsoletest.DefaultPassword); err != nil {
+		t.Errorf("GetBMCInfo: %v", err)
+	}
+	report := Check(ConsoleConfig{
+		BMCIP:    xcc.Addr,
+		Username: lenovoconsoletest.DefaultUsername,
+		Password: lenovoconsoletest.DefaultPassword,
+		RPPort:   xcc.Port(),
+	}, 5*time.Second)
+	if !report.Passed {
+		var b strings.Builder
+		report.WriteText(&b)
+		t.Errorf("Check failed:\n%s", b.String())
+	}
+
+	c := NewConsole(ConsoleConfig{
+		BMCIP:    xcc.Addr,
+		Username: lenovoconsoletest.DefaultUsername,
+		Password: lenovoconsoletest.DefaultPassword,
+		RPPort:   3912,
+	})
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	for _, want := range []string{
+		"bmcHost: '[::1]'",
+		"bmcOrigin: 'https:\\/\\/[::1]:",
+	} {
+		if !strings.Contains(c.consoleHTML, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+	rec := httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/mouseworker.js", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("proxied worker: HTTP %d %s", rec.Code, rec.Body.String())
+	}
+	// The fake's HTTPS port stands in for the RP port
+	rp := NewConsole(ConsoleConfig{BMCIP: xcc.Addr, RPPort: xcc.Port()})
+	if cert, err := rp.RPCertificate(); err != nil || cert.Host != "::1" {
+		t.Errorf("RPCertificate = %+v, %v", cert, err)
+	}
+
+	if err := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1:https"}).Initialize(); err == nil {
+		t.Error("Initialize accepted an invalid address")
+	}
+}
@@ -0,0 +1,268 @@
+package lenovoconsole
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AuditAction identifies what an audit record describes
+type AuditAction string
+
+// Audit actions recorded by a Console
+const (
+	AuditConsoleCreated AuditAction = "console-created" // The console server started
+	AuditPageLoad       AuditAction = "page-load"       // A browser loaded the console page
+	AuditLogin          AuditAction = "login"           // The viewer reported a login result
+	AuditSessionEnd     AuditAction = "session-end"     // The viewer session was terminated or closed
+	AuditConsoleStopped AuditAction = "console-stopped" // The console server stopped
+
+	AuditCertificateTrusted AuditAction = "certificate-trusted" // The RP-port certificate was trusted for the launched browser
+
+	AuditPower      AuditAction = "power"      // A power action was requested; Detail is the action
+	AuditScreenshot AuditAction = "screenshot" // The console screen was captured
+	AuditScript     AuditAction = "script"     // A script started or finished; Detail is its name
+	AuditPaste      AuditAction = "paste"      // Text was typed on the host; Length is its character count
+	AuditSendKeys   AuditAction = "send-keys"  // A key sequence was typed; Length is its key press count
+)
+
+// AuditRecord is one line of the audit log
+type AuditRecord struct {
+	Time    time.Time   `json:"time"`
+	Action  AuditAction `json:"action"`
+	BMCIP   string      `json:"bmc_ip"`
+	BMCUser string      `json:"bmc_user,omitempty"` // XCC account used by the viewer
+	Client  string      `json:"client,omitempty"`   // Address of the browser
+	User    string      `json:"user,omitempty"`     // User authenticated by a fronting proxy, see AuditConfig.UserHeader
+	Code    *int        `json:"code,omitempty"`     // Login result or termination reason
+	Detail  string      `json:"detail,omitempty"`   // What the action applied to, e.g. the power action
+	Length  int         `json:"length,omitempty"`   // Size of typed input; its content is never recorded
+	Result  string      `json:"result,omitempty"`   // Human readable outcome
+}
+
+// AuditSink receives audit records as JSON lines
+type AuditSink interface {
+	// WriteAudit stores one record; line is a JSON object terminated by a newline
+	WriteAudit(line []byte) error
+	Close() error
+}
+
+// AuditConfig enables the audit log of a console
+type AuditConfig struct {
+	Log        *AuditLog // Destination of the records (nil disables auditing)
+	UserHeader string    // Request header carrying the user authenticated by a reverse proxy, e.g. X-Forwarded-User
+	// TrustedProxies lists the addresses (IPs or CIDR ranges) of the reverse proxy.
+	// UserHeader is only recorded from requests coming from them; if empty, only from
+	// loopback addresses, for a proxy on the same host with the console bound to localhost.
+	TrustedProxies []string
+}
+
+// trustsProxy reports whether UserHeader may be taken from a request from remoteAddr
+func (a AuditConfig) trustsProxy(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if len(a.TrustedProxies) == 0 {
+		return ip.IsLoopback()
+	}
+	for _, proxy := range a.TrustedProxies {
+		if strings.Contains(proxy, "/") {
+			if _, network, err := net.ParseCIDR(proxy); err == nil && network.Contains(ip) {
+				return true
+			}
+		} else if trusted := net.ParseIP(proxy); trusted != nil && trusted.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditQueueSize is the number of records queued before Record drops them
+const auditQueueSize = 256
+
+// AuditLog is an append-only log of console activity written to one or more sinks.
+// Records are delivered in order by a background goroutine, so slow sinks such as
+// webhooks do not delay console requests: when the queue is full, records are dropped
+// and counted instead. An AuditLog can be shared by many consoles.
+type AuditLog struct {
+	sinks   []AuditSink
+	queue   chan []byte
+	dropped atomic.Int64
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	closeMu sync.Once
+}
+
+// NewAuditLog creates an audit log writing to the given sinks
+func NewAuditLog(sinks ...AuditSink) *AuditLog {
+	l := &AuditLog{
+		sinks: sinks,
+		queue: make(chan []byte, auditQueueSize),
+		done:  make(chan struct{}),
+	}
+	go l.deliver()
+	return l
+}
+
+// Record appends a record to the log without waiting for the sinks. Records after
+// Close are discarded; records that do not fit in the queue are dropped and counted
+// in Dropped.
+func (l *AuditLog) Record(rec AuditRecord) {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return
+	}
+	select {
+	case l.queue <- line:
+	default:
+		if l.dropped.Add(1) == 1 {
+			fmt.Println("Warning: audit sinks are not keeping up, dropping audit records")
+		}
+	}
+}
+
+// Dropped returns the number of records dropped because the queue was full
+func (l *AuditLog) Dropped() int64 {
+	return l.dropped.Load()
+}
+
+// deliver writes queued records to every sink
+func (l *AuditLog) deliver() {
+	defer close(l.done)
+	for line := range l.queue {
+		for _, sink := range l.sinks {
+			if err := sink.WriteAudit(line); err != nil {
+				fmt.Printf("Warning: audit record not written: %v\n", err)
+			}
+		}
+	}
+}
+
+// Close writes the remaining records and closes every sink. It reports how many
+// records were dropped, if any.
+func (l *AuditLog) Close() error {
+	var err error
+	l.closeMu.Do(func() {
+		l.mu.Lock()
+		l.closed = true
+		close(l.queue)
+		l.mu.Unlock()
+		<-l.done
+		if n := l.Dropped(); n > 0 {
+			fmt.Printf("Warning: %d audit records were dropped\n", n)
+		}
+
+		for _, sink := range l.sinks {
+			if closeErr := sink.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	})
+	return err
+}
+
+// fileAuditSink appends records to a file
+type fileAuditSink struct {
+	f *os.File
+}
+
+// NewFileAuditSink opens path for appending, creating it readable only by the owner
+func NewFileAuditSink(path string) (AuditSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	return &fileAuditSink{f: f}, nil
+}
+
+func (s *fileAuditSink) WriteAudit(line []byte) error {
+	_, err := s.f.Write(line)
+	return err
+}
+
+func (s *fileAuditSink) Close() error {
+	return s.f.Close()
+}
+
+// webhookAuditSink posts each record to an HTTP endpoint
+type webhookAuditSink struct {
+	url    string
+	client *http.Client
+}
+
+// webhookTimeout bounds each audit webhook request
+const webhookTimeout = 10 * time.Second
+
+// NewWebhookAuditSink posts every record as a JSON body to url
+func NewWebhookAuditSink(url string) AuditSink {
+	return &webhookAuditSink{url: url, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+func (s *webhookAuditSink) WriteAudit(line []byte) error {
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(line))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("audit webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *webhookAuditSink) Close() error {
+	return nil
+}
+
+// audit records console activity if auditing is enabled
+func (c *Console) audit(rec AuditRecord) {
+	if c.config.Audit.Log == nil {
+		return
+	}
+	rec.BMCIP = c.config.BMCIP
+	c.config.Audit.Log.Record(rec)
+}
+
+// auditResult describes the outcome of an audited action
+func auditResult(err error) string {
+	if err != nil {
+		return "failed: " + err.Error()
+	}
+	return "ok"
+}
+
+// auditRequest records activity caused by a request from the console page. The user
+// header is ignored unless the request comes from a trusted proxy, since any client
+// could set it.
+func (c *Console) auditRequest(r *http.Request, rec AuditRecord) {
+	rec.Client = r.RemoteAddr
+	if c.config.Audit.UserHeader != "" && c.config.Audit.trustsProxy(r.RemoteAddr) {
+		rec.User = r.Header.Get(c.config.Audit.UserHeader)
+	}
+	c.audit(rec)
+}
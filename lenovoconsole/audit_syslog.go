@@ -0,0 +1,30 @@
+//go:build !windows
+
+package lenovoconsole
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// syslogAuditSink writes records to the local syslog daemon
+type syslogAuditSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogAuditSink writes records to the local syslog daemon under tag, with the auth facility
+func NewSyslogAuditSink(tag string) (AuditSink, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %v", err)
+	}
+	return &syslogAuditSink{w: w}, nil
+}
+
+func (s *syslogAuditSink) WriteAudit(line []byte) error {
+	return s.w.Info(string(line))
+}
+
+func (s *syslogAuditSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package lenovoconsole
+
+import "fmt"
+
+// NewSyslogAuditSink is not available on Windows, which has no syslog daemon
+func NewSyslogAuditSink(tag string) (AuditSink, error) {
+	return nil, fmt.Errorf("syslog audit sink is not supported on Windows")
+}
@@ -0,0 +1,229 @@
+package lenovoconsole
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+// memoryAuditSink keeps audit records in memory
+type memoryAuditSink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (s *memoryAuditSink) WriteAudit(line []byte) error {
+	var rec AuditRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.records = append(s.records, rec)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryAuditSink) Close() error { return nil }
+
+// auditedConsole returns a console auditing to a memory sink, and a function that
+// flushes the log and returns its records
+func auditedConsole(config ConsoleConfig) (*Console, func() []AuditRecord) {
+	sink := &memoryAuditSink{}
+	log := NewAuditLog(sink)
+	config.Audit.Log = log
+	return NewConsole(config), func() []AuditRecord {
+		log.Close()
+		return sink.records
+	}
+}
+
+func TestAuditActions(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer xcc.Close()
+	c, records := auditedConsole(ConsoleConfig{
+		BMCIP:    xcc.Addr,
+		Username: lenovoconsoletest.DefaultUsername,
+		Password: lenovoconsoletest.DefaultPassword,
+	})
+
+	if err := c.Power(PowerRestart); err != nil {
+		t.Fatalf("Power: %v", err)
+	}
+	page := newFakeScreenPage(c, readScreen(t, "post-f1.png"))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.Screenshot(ctx); err != nil {
+		t.Fatalf("Screenshot: %v", err)
+	}
+	script := &Script{Name: "reboot", Steps: []ScriptStep{{Screenshot: "setup"}}}
+	if _, err := c.RunScript(ctx, script, ScriptOptions{}); err != nil {
+		t.Fatalf("RunScript: %v", err)
+	}
+	page.close()
+
+	want := []AuditRecord{
+		{Action: AuditPower, Detail: string(PowerRestart), Result: "ok"},
+		{Action: AuditScreenshot, Result: "ok"},
+		{Action: AuditScript, Detail: "reboot", Result: "started"},
+		{Action: AuditScreenshot, Result: "ok"},
+		{Action: AuditScript, Detail: "reboot", Result: "passed"},
+	}
+	got := records()
+	if len(got) != len(want) {
+		t.Fatalf("audit records = %+v", got)
+	}
+	for i, rec := range got {
+		if rec.Action != want[i].Action || rec.Detail != want[i].Detail || rec.Result != want[i].Result || rec.BMCIP != xcc.Addr {
+			t.Errorf("record %d = %+v, want %+v", i, rec, want[i])
+		}
+	}
+}
+
+func TestFileAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := os.WriteFile(path, []byte(`{"action":"earlier"}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewAuditLog(sink)
+	c := NewConsole(ConsoleConfig{
+		BMCIP:    "10.0.0.1",
+		RPPort:   3900,
+		Username: "USERID",
+		Audit:    AuditConfig{Log: log, UserHeader: "X-Forwarded-User", TrustedProxies: []string{"192.0.2.0/24"}},
+	})
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	page := httptest.NewRequest("GET", "/", nil)
+	page.Header.Set("X-Forwarded-User", "alice")
+	c.mux.ServeHTTP(httptest.NewRecorder(), page)
+	rec := httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, pageRequest(c, "/api/events", "application/json", strings.NewReader(`{"type":"login","code":0}`)))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("login event: status %d", rec.Code)
+	}
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	log.Record(AuditRecord{Action: AuditConsoleStopped}) // discarded after Close
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var actions []AuditAction
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("line %q is not a JSON record: %v", scanner.Text(), err)
+		}
+		actions = append(actions, r.Action)
+		records = append(records, r)
+	}
+	if want := []AuditAction{"earlier", AuditPageLoad, AuditLogin}; !slices.Equal(actions, want) {
+		t.Fatalf("audit actions = %v, want %v", actions, want)
+	}
+	load, login := records[1], records[2]
+	if load.BMCIP != "10.0.0.1" || load.User != "alice" || load.Client == "" || load.Time.IsZero() {
+		t.Errorf("page load record = %+v", load)
+	}
+	if login.BMCUser != "USERID" || login.Code == nil || *login.Code != 0 || login.Result != LoginResults[0] {
+		t.Errorf("login record = %+v", login)
+	}
+}
+
+func TestAuditUserHeaderTrust(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		want       string
+	}{
+		{"loopback by default", nil, "127.0.0.1:5000", "alice"},
+		{"IPv6 loopback by default", nil, "[::1]:5000", "alice"},
+		{"remote client by default", nil, "10.1.2.3:5000", ""},
+		{"configured proxy", []string{"10.1.2.3"}, "10.1.2.3:5000", "alice"},
+		{"proxy range", []string{"10.1.0.0/16"}, "10.1.2.3:5000", "alice"},
+		{"other client", []string{"10.1.2.3"}, "10.1.2.4:5000", ""},
+		// A configured proxy replaces the loopback default
+		{"loopback with a configured proxy", []string{"10.1.2.3"}, "127.0.0.1:5000", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, records := auditedConsole(ConsoleConfig{
+				BMCIP: "10.0.0.1",
+				Audit: AuditConfig{UserHeader: "X-Forwarded-User", TrustedProxies: tt.proxies},
+			})
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			r.Header.Set("X-Forwarded-User", "alice")
+			c.auditRequest(r, AuditRecord{Action: AuditPageLoad})
+			got := records()
+			if len(got) != 1 || got[0].User != tt.want || got[0].Client != tt.remoteAddr {
+				t.Errorf("records = %+v, want user %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// blockingAuditSink holds every write until release is closed
+type blockingAuditSink struct {
+	memoryAuditSink
+	release chan struct{}
+}
+
+func (s *blockingAuditSink) WriteAudit(line []byte) error {
+	<-s.release
+	return s.memoryAuditSink.WriteAudit(line)
+}
+
+func TestAuditLogDropsWhenFull(t *testing.T) {
+	sink := &blockingAuditSink{release: make(chan struct{})}
+	log := NewAuditLog(sink)
+
+	// One record is held by the sink and auditQueueSize wait in the queue
+	const extra = 10
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < auditQueueSize+1+extra; i++ {
+			log.Record(AuditRecord{Action: AuditPageLoad})
+			if i == 0 {
+				// Let the delivery goroutine take the first record
+				time.Sleep(50 * time.Millisecond)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Record blocked on a full queue")
+	}
+	if got := log.Dropped(); got != extra {
+		t.Errorf("Dropped = %d, want %d", got, extra)
+	}
+
+	close(sink.release)
+	log.Close()
+	if got := len(sink.records); got != auditQueueSize+1 {
+		t.Errorf("delivered %d records, want %d", got, auditQueueSize+1)
+	}
+}
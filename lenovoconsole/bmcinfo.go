@@ -0,0 +1,159 @@
+package lenovoconsole
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BMCInfo identifies the server behind a BMC, as reported by Redfish
+type BMCInfo struct {
+	HostName        string    `json:"host_name,omitempty"`
+	Manufacturer    string    `json:"manufacturer,omitempty"`
+	Model           string    `json:"model,omitempty"`
+	SerialNumber    string    `json:"serial_number,omitempty"`
+	BIOSVersion     string    `json:"bios_version,omitempty"`
+	FirmwareVersion string    `json:"firmware_version,omitempty"` // BMC firmware
+	BMCModel        string    `json:"bmc_model,omitempty"`        // e.g. "Lenovo XClarity Controller"
+	PowerState      string    `json:"power_state,omitempty"`
+	Health          string    `json:"health,omitempty"` // Rolled up health: OK, Warning or Critical
+	Updated         time.Time `json:"updated"`
+}
+
+// GetBMCInfo reads the identity, power state and health of the first ComputerSystem
+// and the firmware of the first Manager from Redfish. Manager details are best effort:
+// only a failure to read the system is returned as an error.
+func GetBMCInfo(bmcIP, username, password string) (*BMCInfo, error) {
+	return getBMCInfo(nil, bmcIP, username, password)
+}
+
+// getBMCInfo is GetBMCInfo connecting through d
+func getBMCInfo(d Dialer, bmcIP, username, password string) (*BMCInfo, error) {
+	rf := newRedfishClient(d, bmcIP, username, password)
+	system, err := redfishSystem(rf)
+	if err != nil {
+		return nil, err
+	}
+	var s struct {
+		HostName     string `json:"HostName"`
+		Manufacturer string `json:"Manufacturer"`
+		Model        string `json:"Model"`
+		SerialNumber string `json:"SerialNumber"`
+		BiosVersion  string `json:"BiosVersion"`
+		PowerState   string `json:"PowerState"`
+		Status       struct {
+			Health       string `json:"Health"`
+			HealthRollup string `json:"HealthRollup"`
+		} `json:"Status"`
+	}
+	if err := rf.get(system, &s); err != nil {
+		return nil, err
+	}
+	info := &BMCInfo{
+		HostName:     s.HostName,
+		Manufacturer: s.Manufacturer,
+		Model:        strings.TrimSpace(s.Model),
+		SerialNumber: strings.TrimSpace(s.SerialNumber),
+		BIOSVersion:  s.BiosVersion,
+		PowerState:   s.PowerState,
+		Health:       s.Status.HealthRollup,
+		Updated:      time.Now(),
+	}
+	if info.Health == "" {
+		info.Health = s.Status.Health
+	}
+
+	if managers, err := rf.members("/redfish/v1/Managers"); err == nil && len(managers) > 0 {
+		var m struct {
+			Model           string `json:"Model"`
+			FirmwareVersion string `json:"FirmwareVersion"`
+		}
+		if rf.get(managers[0], &m) == nil {
+			info.BMCModel, info.FirmwareVersion = m.Model, m.FirmwareVersion
+		}
+	}
+	return info, nil
+}
+
+// Name returns the server's host name, or its model and serial number if it has none
+func (i *BMCInfo) Name() string {
+	if i.HostName != "" {
+		return i.HostName
+	}
+	return strings.TrimSpace(i.Model + " " + i.SerialNumber)
+}
+
+// Summary describes the server in one line, e.g.
+// "node01 · ThinkSystem SR650 V2 · S/N J900TEST · FW TGBT99Z · Power On · Health OK"
+func (i *BMCInfo) Summary() string {
+	var parts []string
+	add := func(prefix, value string) {
+		if value != "" {
+			parts = append(parts, prefix+value)
+		}
+	}
+	add("", i.HostName)
+	add("", i.Model)
+	add("S/N ", i.SerialNumber)
+	add("FW ", i.FirmwareVersion)
+	add("Power ", i.PowerState)
+	add("Health ", i.Health)
+	return strings.Join(parts, " · ")
+}
+
+// Info queries Redfish for the server behind the console's BMC and remembers the result
+// for CachedInfo
+func (c *Console) Info() (*BMCInfo, error) {
+	info, err := getBMCInfo(c.dialer, c.config.BMCIP, c.config.Username, c.config.Password)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.info = info
+	c.mu.Unlock()
+	return info, nil
+}
+
+// CachedInfo returns the result of the last successful Info call, or nil if there was none.
+// The console page calls Info when it loads, so running consoles usually have one.
+func (c *Console) CachedInfo() *BMCInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.info
+}
+
+// pageTitle returns the console page title, naming the server when info is known
+func (c *Console) pageTitle(info *BMCInfo) string {
+	title := c.provider().Title() + " Remote Console - " + c.config.BMCIP
+	if info != nil && info.Name() != "" {
+		title = info.Name() + " - " + title
+	}
+	return title
+}
+
+// infoHandler reports the server identity and the resulting page title as JSON
+func (c *Console) infoHandler(w http.ResponseWriter, r *http.Request) {
+	result := struct {
+		Info    *BMCInfo `json:"info,omitempty"`
+		Title   string   `json:"title"`
+		Summary string   `json:"summary,omitempty"`
+		Error   string   `json:"error,omitempty"`
+	}{}
+
+	info, err := c.Info()
+	if err != nil {
+		result.Error = err.Error()
+		// Keep showing what was last known, e.g. while the BMC restarts
+		info = c.CachedInfo()
+	}
+	result.Info = info
+	result.Title = c.pageTitle(info)
+	if info != nil {
+		result.Summary = info.Summary()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(result)
+}
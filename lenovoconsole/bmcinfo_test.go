@@ -0,0 +1,93 @@
+package lenovoconsole
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+func TestGetBMCInfo(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{
+		HostName:   "db-07",
+		Health:     "Warning",
+		PowerState: "Off",
+	})
+	defer xcc.Close()
+
+	info, err := GetBMCInfo(xcc.Addr, lenovoconsoletest.DefaultUsername, lenovoconsoletest.DefaultPassword)
+	if err != nil {
+		t.Fatalf("GetBMCInfo: %v", err)
+	}
+	want := BMCInfo{
+		HostName:        "db-07",
+		Manufacturer:    "Lenovo",
+		Model:           "ThinkSystem SR650 V2",
+		SerialNumber:    "J900TEST",
+		BIOSVersion:     "TEE170J-3.10",
+		FirmwareVersion: "TGBT99Z",
+		BMCModel:        "Lenovo XClarity Controller",
+		PowerState:      "Off",
+		Health:          "Warning",
+		Updated:         info.Updated,
+	}
+	if *info != want {
+		t.Errorf("info = %+v, want %+v", *info, want)
+	}
+	if got := info.Summary(); got != "db-07 · ThinkSystem SR650 V2 · S/N J900TEST · FW TGBT99Z · Power Off · Health Warning" {
+		t.Errorf("Summary = %q", got)
+	}
+
+	if _, err := GetBMCInfo(xcc.Addr, "USERID", "wrong"); err == nil {
+		t.Error("GetBMCInfo succeeded with bad credentials")
+	}
+	if got := (&BMCInfo{Model: "SR630", SerialNumber: "J1"}).Name(); got != "SR630 J1" {
+		t.Errorf("Name without a host name = %q", got)
+	}
+}
+
+func TestInfoHandler(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{HostName: "db-07"})
+	defer xcc.Close()
+
+	c := NewConsole(ConsoleConfig{
+		BMCIP:    xcc.Addr,
+		Username: lenovoconsoletest.DefaultUsername,
+		Password: lenovoconsoletest.DefaultPassword,
+	})
+	c.setupHandlers()
+	if c.CachedInfo() != nil {
+		t.Fatal("info cached before the page asked for it")
+	}
+
+	var result struct {
+		Info    *BMCInfo `json:"info"`
+		Title   string   `json:"title"`
+		Summary string   `json:"summary"`
+		Error   string   `json:"error"`
+	}
+	rec := httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/info", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("/api/info: HTTP %d %s", rec.Code, rec.Body.String())
+	}
+	if result.Title != "db-07 - Lenovo XCC Remote Console - "+xcc.Addr || result.Info == nil || !strings.HasPrefix(result.Summary, "db-07 · ") {
+		t.Errorf("/api/info = %+v", result)
+	}
+	if info := c.CachedInfo(); info == nil || info.HostName != "db-07" {
+		t.Errorf("CachedInfo = %+v", info)
+	}
+
+	// The last known identity is kept while the BMC cannot be reached
+	xcc.Close()
+	rec = httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/info", nil))
+	result.Info, result.Error = nil, ""
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if result.Error == "" || result.Info == nil || !strings.HasPrefix(result.Title, "db-07 - ") {
+		t.Errorf("/api/info with the BMC down = %+v", result)
+	}
+}
@@ -0,0 +1,478 @@
+package lenovoconsole
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Browser names accepted in BrowserConfig.Name
+const (
+	BrowserChrome   = "chrome"
+	BrowserChromium = "chromium"
+	BrowserFirefox  = "firefox"
+	BrowserEdge     = "edge"
+	BrowserBrave    = "brave"
+	BrowserDefault  = "default" // the system default browser (xdg-open, open, rundll32)
+)
+
+// BrowserConfig controls which browser OpenInBrowser launches and how
+type BrowserConfig struct {
+	// Name selects a browser: chrome, chromium, firefox, edge, brave or default.
+	// When empty, UseFirefox and the BROWSER environment variable are honored,
+	// then Chrome/Chromium are tried before falling back to the system default.
+	Name string
+
+	// Command is a custom command template that overrides Name, for example
+	// "/opt/vivaldi/vivaldi --user-data-dir={profile} --app={url}".
+	// {url} is replaced with the console URL (appended if absent) and {profile}
+	// with the profile directory. Arguments may be quoted with ' or ".
+	Command string
+
+	// IsolatedProfile gives each console its own temporary browser profile,
+	// which is removed when the console is stopped
+	IsolatedProfile bool
+
+	// AppMode opens the console in a chromeless app window (Chromium-based browsers only)
+	AppMode bool
+
+	// Kiosk opens the console fullscreen in kiosk mode
+	Kiosk bool
+}
+
+// browserFamily determines the command line flags a browser understands
+type browserFamily int
+
+const (
+	familyChromium browserFamily = iota
+	familyFirefox
+)
+
+// browserSpec describes where to find a browser on each platform
+type browserSpec struct {
+	family      browserFamily
+	paths       map[string][]string // absolute install paths keyed by GOOS
+	executables []string            // names looked up in PATH
+	flatpak     string              // flatpak application ID (Linux only)
+}
+
+var browserSpecs = map[string]browserSpec{
+	BrowserChrome: {
+		family: familyChromium,
+		paths: map[string][]string{
+			"windows": {
+				"C:\\Program Files\\Google\\Chrome\\Application\\chrome.exe",
+				"C:\\Program Files (x86)\\Google\\Chrome\\Application\\chrome.exe",
+				"${LOCALAPPDATA}\\Google\\Chrome\\Application\\chrome.exe",
+			},
+			"darwin": {"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"},
+			"linux": {
+				"/usr/bin/google-chrome",
+				"/usr/bin/google-chrome-stable",
+				"/opt/google/chrome/chrome",
+			},
+		},
+		executables: []string{"google-chrome", "google-chrome-stable", "chrome"},
+		flatpak:     "com.google.Chrome",
+	},
+	BrowserChromium: {
+		family: familyChromium,
+		paths: map[string][]string{
+			"darwin": {"/Applications/Chromium.app/Contents/MacOS/Chromium"},
+			"linux": {
+				"/usr/bin/chromium",
+				"/usr/bin/chromium-browser",
+				"/snap/bin/chromium",
+				"/opt/chromium/chromium",
+			},
+		},
+		executables: []string{"chromium", "chromium-browser"},
+		flatpak:     "org.chromium.Chromium",
+	},
+	BrowserFirefox: {
+		family: familyFirefox,
+		paths: map[string][]string{
+			"windows": {
+				"C:\\Program Files\\Mozilla Firefox\\firefox.exe",
+				"C:\\Program Files (x86)\\Mozilla Firefox\\firefox.exe",
+			},
+			"darwin": {"/Applications/Firefox.app/Contents/MacOS/firefox"},
+			"linux": {
+				"/usr/bin/firefox",
+				"/usr/local/bin/firefox",
+				"/snap/bin/firefox",
+				"/opt/firefox/firefox",
+			},
+		},
+		executables: []string{"firefox"},
+		flatpak:     "org.mozilla.firefox",
+	},
+	BrowserEdge: {
+		family: familyChromium,
+		paths: map[string][]string{
+			"windows": {
+				"C:\\Program Files (x86)\\Microsoft\\Edge\\Application\\msedge.exe",
+				"C:\\Program Files\\Microsoft\\Edge\\Application\\msedge.exe",
+			},
+			"darwin": {"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge"},
+			"linux": {
+				"/usr/bin/microsoft-edge",
+				"/usr/bin/microsoft-edge-stable",
+				"/opt/microsoft/msedge/msedge",
+			},
+		},
+		executables: []string{"msedge", "microsoft-edge", "microsoft-edge-stable"},
+		flatpak:     "com.microsoft.Edge",
+	},
+	BrowserBrave: {
+		family: familyChromium,
+		paths: map[string][]string{
+			"windows": {
+				"C:\\Program Files\\BraveSoftware\\Brave-Browser\\Application\\brave.exe",
+				"${LOCALAPPDATA}\\BraveSoftware\\Brave-Browser\\Application\\brave.exe",
+			},
+			"darwin": {"/Applications/Brave Browser.app/Contents/MacOS/Brave Browser"},
+			"linux": {
+				"/usr/bin/brave-browser",
+				"/usr/bin/brave",
+				"/opt/brave.com/brave/brave",
+			},
+		},
+		executables: []string{"brave-browser", "brave"},
+		flatpak:     "com.brave.Browser",
+	},
+}
+
+// chromiumFlags are passed to every Chromium-based browser to relax certificate checks
+// against the local console server and suppress first-run UI
+var chromiumFlags = []string{
+	"--ignore-certificate-errors",
+	"--test-type",
+	"--allow-insecure-localhost",
+	"--disable-popup-blocking",
+	"--disable-blink-features=AutomationControlled",
+	"--disable-session-crashed-bubble",
+	"--disable-infobars",
+	"--no-first-run",
+	"--no-default-browser-check",
+}
+
+// getBrowserCommand returns the appropriate command to open the browser
+func (c *Console) getBrowserCommand(url string) (*exec.Cmd, error) {
+	bc := c.config.Browser
+
+	if bc.Command != "" {
+		return c.customBrowserCommand(bc.Command, url)
+	}
+
+	name := strings.ToLower(bc.Name)
+	if name == "" && c.config.UseFirefox {
+		if cmd, err := c.namedBrowserCommand(BrowserFirefox, url); cmd != nil || err != nil {
+			return cmd, err
+		}
+	}
+
+	switch name {
+	case "":
+		if env := os.Getenv("BROWSER"); env != "" {
+			if cmd, ok := c.envBrowserCommand(env, url); ok {
+				return cmd, nil
+			}
+		}
+		for _, candidate := range []string{BrowserChrome, BrowserChromium} {
+			if cmd, err := c.namedBrowserCommand(candidate, url); cmd != nil || err != nil {
+				return cmd, err
+			}
+		}
+		return defaultBrowserCommand(url)
+	case BrowserDefault:
+		return defaultBrowserCommand(url)
+	}
+
+	if _, known := browserSpecs[name]; !known {
+		return nil, fmt.Errorf("unknown browser %q", bc.Name)
+	}
+	cmd, err := c.namedBrowserCommand(name, url)
+	if cmd == nil && err == nil {
+		return nil, fmt.Errorf("browser %q not found", name)
+	}
+	return cmd, err
+}
+
+// namedBrowserCommand builds a command for one of the known browsers.
+// It returns a nil command if the browser is not installed.
+func (c *Console) namedBrowserCommand(name, url string) (*exec.Cmd, error) {
+	spec := browserSpecs[name]
+
+	var prefix []string
+	path := locateBrowser(spec)
+	if path == "" && runtime.GOOS == "linux" && spec.flatpak != "" && flatpakInstalled(spec.flatpak) {
+		path, _ = exec.LookPath("flatpak")
+		// The sandbox cannot see the profile under the host temp directory without an explicit grant
+		prefix = []string{"run", "--filesystem=" + os.TempDir(), spec.flatpak}
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	args, err := c.browserArgs(spec.family, url)
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(path, append(prefix, args...)...), nil
+}
+
+// browserArgs returns the command line arguments for a browser family
+func (c *Console) browserArgs(family browserFamily, url string) ([]string, error) {
+	bc := c.config.Browser
+
+	profile, err := c.browserProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	switch family {
+	case familyFirefox:
+		if bc.IsolatedProfile {
+			args = append(args, "-profile", profile, "-no-remote")
+		}
+		if bc.Kiosk {
+			args = append(args, "--kiosk")
+		}
+		args = append(args, url)
+	default:
+		spki := c.trustedSPKI()
+		for _, flag := range chromiumFlags {
+			// With a pinned BMC certificate there is no need to accept every certificate
+			if spki == "" || flag != "--ignore-certificate-errors" {
+				args = append(args, flag)
+			}
+		}
+		if spki != "" {
+			args = append(args, spki)
+		}
+		if proxy := browserProxy(c.config.Proxy); proxy != "" {
+			// The viewer's WebSocket to the RP port goes through the same proxy
+			args = append(args, "--proxy-server="+proxy)
+		}
+		args = append(args, "--user-data-dir="+profile)
+		if bc.Kiosk {
+			args = append(args, "--kiosk")
+		}
+		if bc.AppMode {
+			args = append(args, "--app="+url)
+		} else {
+			args = append(args, url)
+		}
+	}
+	return args, nil
+}
+
+// browserProxy returns the Chromium --proxy-server value for ConsoleConfig.Proxy, or ""
+// for proxies Chromium cannot use itself: SSH jump hosts and SOCKS5 with credentials
+func browserProxy(proxy string) string {
+	if proxy == "" {
+		return ""
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "socks5", "socks5h":
+		if u.User != nil {
+			return ""
+		}
+		return "socks5://" + proxyHostPort(u, "1080")
+	case "http":
+		return "http://" + proxyHostPort(u, "8080")
+	}
+	return ""
+}
+
+// sharedBrowserProfile is the profile of browsers launched without an isolated profile.
+// Every such console uses it, so a browser started for one console may hand its window
+// to the browser process of another.
+func sharedBrowserProfile() string {
+	return filepath.Join(os.TempDir(), "chrome-temp-profile")
+}
+
+// browserProfile returns the profile directory for the launched browser.
+// Isolated profiles are created once per console and removed by Stop.
+func (c *Console) browserProfile() (string, error) {
+	if !c.config.Browser.IsolatedProfile {
+		return sharedBrowserProfile(), nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.profileDir == "" {
+		dir, err := os.MkdirTemp("", "lenovo-console-profile-")
+		if err != nil {
+			return "", fmt.Errorf("failed to create browser profile: %v", err)
+		}
+		c.profileDir = dir
+	}
+	return c.profileDir, nil
+}
+
+// removeBrowserProfile deletes the isolated profile directory, if one was created
+func (c *Console) removeBrowserProfile() error {
+	c.mu.Lock()
+	dir := c.profileDir
+	c.profileDir = ""
+	c.mu.Unlock()
+	if dir == "" {
+		return nil
+	}
+	return os.RemoveAll(dir)
+}
+
+// customBrowserCommand expands a user supplied command template
+func (c *Console) customBrowserCommand(template, url string) (*exec.Cmd, error) {
+	fields, err := splitCommandLine(template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid browser command: %v", err)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid browser command: empty")
+	}
+
+	needsProfile := strings.Contains(template, "{profile}")
+	profile := ""
+	if needsProfile {
+		if profile, err = c.browserProfile(); err != nil {
+			return nil, err
+		}
+	}
+
+	hasURL := false
+	for i, f := range fields {
+		if strings.Contains(f, "{url}") {
+			hasURL = true
+		}
+		f = strings.ReplaceAll(f, "{url}", url)
+		fields[i] = strings.ReplaceAll(f, "{profile}", profile)
+	}
+	if !hasURL {
+		fields = append(fields, url)
+	}
+	return exec.Command(fields[0], fields[1:]...), nil
+}
+
+// envBrowserCommand interprets the BROWSER environment variable: a list of commands
+// separated by the OS path list separator, where %s is replaced with the URL
+func (c *Console) envBrowserCommand(env, url string) (*exec.Cmd, bool) {
+	for _, entry := range filepath.SplitList(env) {
+		fields, err := splitCommandLine(entry)
+		if err != nil || len(fields) == 0 {
+			continue
+		}
+		path, err := exec.LookPath(fields[0])
+		if err != nil {
+			continue
+		}
+
+		hasURL := false
+		for i, f := range fields[1:] {
+			if strings.Contains(f, "%s") {
+				hasURL = true
+				fields[i+1] = strings.ReplaceAll(f, "%s", url)
+			}
+		}
+		if !hasURL {
+			fields = append(fields, url)
+		}
+		return exec.Command(path, fields[1:]...), true
+	}
+	return nil, false
+}
+
+// defaultBrowserCommand opens the URL with the platform's default handler
+func defaultBrowserCommand(url string) (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url), nil
+	case "darwin":
+		return exec.Command("open", url), nil
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return exec.Command("xdg-open", url), nil
+	default:
+		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+}
+
+// locateBrowser returns the first known install path or PATH entry for a browser
+func locateBrowser(spec browserSpec) string {
+	for _, p := range spec.paths[runtime.GOOS] {
+		p = os.ExpandEnv(p)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	for _, name := range spec.executables {
+		if p, err := exec.LookPath(name); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// flatpakInstalled reports whether a flatpak application is installed system-wide or per-user
+func flatpakInstalled(appID string) bool {
+	if _, err := exec.LookPath("flatpak"); err != nil {
+		return false
+	}
+	dirs := []string{"/var/lib/flatpak/app"}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".local", "share", "flatpak", "app"))
+	}
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, appID)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCommandLine splits a command line into arguments, honoring single and double quotes
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
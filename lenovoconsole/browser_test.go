@@ -0,0 +1,250 @@
+package lenovoconsole
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeBrowsers puts executables with the given names first in PATH and clears BROWSER
+func fakeBrowsers(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	t.Setenv("BROWSER", "")
+	return dir
+}
+
+func TestBrowserArgs(t *testing.T) {
+	const url = "http://localhost:8123"
+	shared := sharedBrowserProfile()
+	// chromium returns the arguments every Chromium-based browser gets, followed by extra
+	chromium := func(extra ...string) []string {
+		return append(append(append([]string{}, chromiumFlags...), "--user-data-dir="+shared), extra...)
+	}
+	tests := []struct {
+		name    string
+		family  browserFamily
+		browser BrowserConfig
+		proxy   string
+		want    []string
+	}{
+		{"chromium", familyChromium, BrowserConfig{}, "", chromium(url)},
+		{"chromium app", familyChromium, BrowserConfig{AppMode: true}, "", chromium("--app=" + url)},
+		{"chromium kiosk app", familyChromium, BrowserConfig{AppMode: true, Kiosk: true}, "", chromium("--kiosk", "--app="+url)},
+		{"chromium socks proxy", familyChromium, BrowserConfig{}, "socks5://bastion",
+			append(append([]string{}, chromiumFlags...), "--proxy-server=socks5://bastion:1080", "--user-data-dir="+shared, url)},
+		// Chromium cannot use SSH jump hosts itself
+		{"chromium ssh proxy", familyChromium, BrowserConfig{}, "ssh://jump.example", chromium(url)},
+		{"firefox", familyFirefox, BrowserConfig{}, "", []string{url}},
+		{"firefox kiosk", familyFirefox, BrowserConfig{Kiosk: true}, "", []string{"--kiosk", url}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", Browser: tt.browser, Proxy: tt.proxy})
+			got, err := c.browserArgs(tt.family, url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrowserArgsIsolatedProfile(t *testing.T) {
+	const url = "http://localhost:8123"
+	for _, family := range []browserFamily{familyChromium, familyFirefox} {
+		c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", Browser: BrowserConfig{IsolatedProfile: true}})
+		got, err := c.browserArgs(family, url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		profile := c.profileDir
+		if _, err := os.Stat(profile); err != nil {
+			t.Fatalf("isolated profile not created: %v", err)
+		}
+		want := []string{"-profile", profile, "-no-remote", url}
+		if family == familyChromium {
+			want = append(append([]string{}, chromiumFlags...), "--user-data-dir="+profile, url)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("family %d: args = %q\nwant %q", family, got, want)
+		}
+		if err := c.removeBrowserProfile(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(profile); !os.IsNotExist(err) {
+			t.Errorf("profile %s left behind: %v", profile, err)
+		}
+	}
+}
+
+func TestGetBrowserCommand(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the system default opener differs")
+	}
+	for name, spec := range browserSpecs {
+		for _, p := range spec.paths[runtime.GOOS] {
+			if _, err := os.Stat(os.ExpandEnv(p)); err == nil {
+				t.Skipf("%s is installed at %s, ahead of the fakes in PATH", name, p)
+			}
+		}
+	}
+	const url = "http://localhost:8123"
+	tests := []struct {
+		name      string
+		installed []string
+		config    ConsoleConfig
+		wantExe   string // base name of the executable, empty for an error
+		wantFirst string // first argument
+	}{
+		{"named browser", []string{"firefox", "chromium"}, ConsoleConfig{Browser: BrowserConfig{Name: "Firefox"}}, "firefox", url},
+		{"UseFirefox", []string{"firefox", "chromium"}, ConsoleConfig{UseFirefox: true}, "firefox", url},
+		{"Chromium before the default", []string{"chromium", "xdg-open"}, ConsoleConfig{}, "chromium", chromiumFlags[0]},
+		{"fall back to the default", []string{"xdg-open"}, ConsoleConfig{}, "xdg-open", url},
+		{"system default", []string{"chromium", "xdg-open"}, ConsoleConfig{Browser: BrowserConfig{Name: BrowserDefault}}, "xdg-open", url},
+		{"not installed", nil, ConsoleConfig{Browser: BrowserConfig{Name: BrowserBrave}}, "", ""},
+		{"unknown", nil, ConsoleConfig{Browser: BrowserConfig{Name: "mosaic"}}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeBrowsers(t, tt.installed...)
+			tt.config.BMCIP = "10.0.0.1"
+			cmd, err := NewConsole(tt.config).getBrowserCommand(url)
+			if tt.wantExe == "" {
+				if err == nil {
+					t.Errorf("getBrowserCommand = %q, want an error", cmd.Args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cmd.Path != filepath.Join(dir, tt.wantExe) {
+				t.Errorf("command = %s, want %s", cmd.Path, tt.wantExe)
+			}
+			if len(cmd.Args) < 2 || cmd.Args[1] != tt.wantFirst {
+				t.Errorf("args = %q, want %q first", cmd.Args, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestCustomBrowserCommand(t *testing.T) {
+	const url = "http://localhost:8123"
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"/opt/vivaldi/vivaldi --app={url}", []string{"/opt/vivaldi/vivaldi", "--app=" + url}},
+		{"surf", []string{"surf", url}},
+		{`"/Applications/My Browser" --profile={profile} '{url}'`, []string{"/Applications/My Browser", "--profile=" + sharedBrowserProfile(), url}},
+	}
+	for _, tt := range tests {
+		c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", Browser: BrowserConfig{Command: tt.command}})
+		cmd, err := c.getBrowserCommand(url)
+		if err != nil {
+			t.Errorf("%s: %v", tt.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(cmd.Args, tt.want) {
+			t.Errorf("%s: args = %q, want %q", tt.command, cmd.Args, tt.want)
+		}
+	}
+
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", Browser: BrowserConfig{Command: `browser "--app={url}`}})
+	if _, err := c.getBrowserCommand(url); err == nil || !strings.Contains(err.Error(), "unterminated quote") {
+		t.Errorf("unterminated quote: err = %v", err)
+	}
+}
+
+func TestEnvBrowserCommand(t *testing.T) {
+	const url = "http://localhost:8123"
+	fakeBrowsers(t, "lynx", "w3m")
+	sep := string(os.PathListSeparator)
+	tests := []struct {
+		env  string
+		want []string // arguments after the executable, nil if no entry is usable
+	}{
+		{"w3m", []string{url}},
+		{"w3m -o open=%s", []string{"-o", "open=" + url}},
+		{"missing" + sep + "lynx -dump", []string{"-dump", url}},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		cmd, ok := NewConsole(ConsoleConfig{}).envBrowserCommand(tt.env, url)
+		if ok != (tt.want != nil) {
+			t.Errorf("BROWSER=%q: ok = %v", tt.env, ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(cmd.Args[1:], tt.want) {
+			t.Errorf("BROWSER=%q: args = %q, want %q", tt.env, cmd.Args[1:], tt.want)
+		}
+	}
+}
+
+func TestLocateBrowser(t *testing.T) {
+	dir := t.TempDir()
+	installed := filepath.Join(dir, "Browser", "browser")
+	if err := os.MkdirAll(filepath.Dir(installed), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(installed, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BROWSER_HOME", dir)
+	fakeBrowsers(t, "browser-in-path")
+
+	// Install paths for this platform come first, with environment variables expanded
+	spec := browserSpec{
+		paths: map[string][]string{
+			runtime.GOOS: {filepath.Join(dir, "missing"), filepath.Join("${BROWSER_HOME}", "Browser", "browser")},
+		},
+		executables: []string{"browser-in-path"},
+	}
+	if got := locateBrowser(spec); got != installed {
+		t.Errorf("locateBrowser = %q, want %q", got, installed)
+	}
+	delete(spec.paths, runtime.GOOS)
+	if got := locateBrowser(spec); strings.TrimSuffix(filepath.Base(got), ".exe") != "browser-in-path" {
+		t.Errorf("locateBrowser without install paths = %q, want the PATH entry", got)
+	}
+	spec.executables = []string{"not-installed"}
+	if got := locateBrowser(spec); got != "" {
+		t.Errorf("locateBrowser = %q for a missing browser", got)
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"a b\tc", []string{"a", "b", "c"}},
+		{`a "b c" 'd "e"'`, []string{"a", "b c", `d "e"`}},
+		{`a ""`, []string{"a", ""}},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		got, err := splitCommandLine(tt.line)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommandLine(%q) = %q, %v, want %q", tt.line, got, err, tt.want)
+		}
+	}
+	if _, err := splitCommandLine(`a 'b`); err == nil {
+		t.Error("unterminated quote accepted")
+	}
+}
@@ -0,0 +1,310 @@
+package lenovoconsole
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// certificateTimeout bounds the TLS handshake used to fetch a BMC certificate
+const certificateTimeout = 10 * time.Second
+
+// CertificateInfo describes the TLS certificate a BMC presents on a port
+type CertificateInfo struct {
+	Host        string    `json:"host"`
+	Port        int       `json:"port"`
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	Fingerprint string    `json:"fingerprint"` // SHA-256 of the certificate, colon separated hex
+	SPKIHash    string    `json:"spki_sha256"` // Base64 SHA-256 of the public key, as Chromium pins it
+
+	Certificate *x509.Certificate `json:"-"`
+}
+
+// FetchCertificate connects to host:port and returns the certificate presented there
+// without verifying it
+func FetchCertificate(host string, port int, timeout time.Duration) (*CertificateInfo, error) {
+	return fetchCertificate(nil, host, port, timeout)
+}
+
+// fetchCertificate is FetchCertificate connecting through d
+func fetchCertificate(d Dialer, host string, port int, timeout time.Duration) (*CertificateInfo, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := dialTLS(d, addr, timeout, &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("TLS handshake with %s failed: %v", addr, err)
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s presented no certificate", addr)
+	}
+	return newCertificateInfo(host, port, certs[0]), nil
+}
+
+func newCertificateInfo(host string, port int, cert *x509.Certificate) *CertificateInfo {
+	spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return &CertificateInfo{
+		Host:        host,
+		Port:        port,
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		NotBefore:   cert.NotBefore,
+		NotAfter:    cert.NotAfter,
+		Fingerprint: Fingerprint(cert),
+		SPKIHash:    base64.StdEncoding.EncodeToString(spki[:]),
+		Certificate: cert,
+	}
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate as colon separated hex,
+// the form browsers show in their certificate viewers
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// Matches reports whether fingerprint is this certificate's SHA-256 fingerprint.
+// Case, colons and spaces are ignored.
+func (i *CertificateInfo) Matches(fingerprint string) bool {
+	normalize := strings.NewReplacer(":", "", " ", "", "-", "")
+	return fingerprint != "" && strings.EqualFold(normalize.Replace(fingerprint), normalize.Replace(i.Fingerprint))
+}
+
+// PEM returns the certificate PEM encoded
+func (i *CertificateInfo) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: i.Certificate.Raw})
+}
+
+// rpHost returns the host the viewer connects to on the RP port
+func (c *Console) rpHost() string {
+	return c.bmc().Host
+}
+
+// RPCertificate fetches the certificate the BMC presents on the remote presence port,
+// which the browser must accept before the viewer can open its WebSocket
+func (c *Console) RPCertificate() (*CertificateInfo, error) {
+	return fetchCertificate(c.dialer, c.rpHost(), c.rpPort(), certificateTimeout)
+}
+
+// HTTPSCertificate fetches the certificate the BMC presents on its HTTPS port, from
+// which the page loads the viewer SDK scripts
+func (c *Console) HTTPSCertificate() (*CertificateInfo, error) {
+	addr := c.bmc()
+	return fetchCertificate(c.dialer, addr.Host, addr.Port, certificateTimeout)
+}
+
+// TrustCertificate makes browsers launched by the console accept cert on the host and
+// port it was fetched from; certificates trusted earlier stay trusted. Chromium-based
+// browsers are started with the public keys pinned; for Firefox a certificate
+// exception is written to the isolated profile, so Firefox requires
+// BrowserConfig.IsolatedProfile. Call it before OpenInBrowser.
+func (c *Console) TrustCertificate(cert *CertificateInfo) error {
+	if c.usesFirefox() {
+		if !c.config.Browser.IsolatedProfile {
+			return fmt.Errorf("trusting a certificate in Firefox requires an isolated profile")
+		}
+		profile, err := c.browserProfile()
+		if err != nil {
+			return err
+		}
+		if err := writeFirefoxCertOverride(profile, cert); err != nil {
+			return fmt.Errorf("failed to install certificate exception: %v", err)
+		}
+	}
+
+	c.mu.Lock()
+	c.trustedCerts = append(c.trustedCerts, cert)
+	c.mu.Unlock()
+	c.audit(AuditRecord{Action: AuditCertificateTrusted, Result: "SHA-256 " + cert.Fingerprint})
+	return nil
+}
+
+// preAcceptCertificate fetches the RP-port certificate before the browser is launched
+// and trusts it if it matches ConsoleConfig.CertificateFingerprint or ConfirmCertificate
+// approves it. A certificate that does not match a configured fingerprint is an error.
+// The HTTPS-port certificate is then trusted as well, see preAcceptHTTPSCertificate.
+func (c *Console) preAcceptCertificate() error {
+	if c.config.CertificateFingerprint == "" && c.config.ConfirmCertificate == nil {
+		return nil
+	}
+
+	cert, err := c.RPCertificate()
+	if err != nil {
+		if c.config.CertificateFingerprint != "" {
+			return fmt.Errorf("cannot verify the RP certificate: %v", err)
+		}
+		fmt.Printf("Warning: could not fetch the RP certificate, accept it in the browser instead: %v\n", err)
+		return nil
+	}
+
+	switch {
+	case c.config.CertificateFingerprint != "":
+		if !cert.Matches(c.config.CertificateFingerprint) {
+			return fmt.Errorf("RP certificate fingerprint %s does not match the expected %s",
+				cert.Fingerprint, c.config.CertificateFingerprint)
+		}
+	case !c.config.ConfirmCertificate(*cert):
+		return nil
+	}
+	if err := c.TrustCertificate(cert); err != nil {
+		return err
+	}
+	return c.preAcceptHTTPSCertificate(cert)
+}
+
+// preAcceptHTTPSCertificate trusts the certificate on the BMC's HTTPS port once the
+// RP-port certificate rp is trusted, so that the SDK scripts load without a warning
+// either. BMCs normally present the same certificate on both ports. A different one
+// is reported, and trusted only if it matches ConsoleConfig.CertificateFingerprint or
+// ConfirmCertificate approves it as well.
+func (c *Console) preAcceptHTTPSCertificate(rp *CertificateInfo) error {
+	addr := c.bmc()
+	if addr.Port == rp.Port {
+		return nil
+	}
+	cert, err := c.HTTPSCertificate()
+	if err != nil {
+		fmt.Printf("Warning: could not fetch the HTTPS certificate, accept it in the browser instead: %v\n", err)
+		return nil
+	}
+
+	if cert.Fingerprint != rp.Fingerprint {
+		fmt.Printf("Warning: BMC %s presents a different certificate on HTTPS port %d (SHA-256 %s) than on RP port %d (SHA-256 %s)\n",
+			addr, cert.Port, cert.Fingerprint, rp.Port, rp.Fingerprint)
+		approved := cert.Matches(c.config.CertificateFingerprint) ||
+			(c.config.ConfirmCertificate != nil && c.config.ConfirmCertificate(*cert))
+		if !approved {
+			fmt.Println("The HTTPS certificate is not trusted; accept it in the browser if the SDK scripts fail to load")
+			return nil
+		}
+	}
+	return c.TrustCertificate(cert)
+}
+
+// usesFirefox reports whether the console launches Firefox
+func (c *Console) usesFirefox() bool {
+	name := strings.ToLower(c.config.Browser.Name)
+	return name == BrowserFirefox || (name == "" && c.config.Browser.Command == "" && c.config.UseFirefox)
+}
+
+// trustedSPKI returns the Chromium flag pinning the trusted certificates, if any
+func (c *Console) trustedSPKI() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var hashes []string
+	seen := make(map[string]bool)
+	for _, cert := range c.trustedCerts {
+		if !seen[cert.SPKIHash] {
+			seen[cert.SPKIHash] = true
+			hashes = append(hashes, cert.SPKIHash)
+		}
+	}
+	if len(hashes) == 0 {
+		return ""
+	}
+	return "--ignore-certificate-errors-spki-list=" + strings.Join(hashes, ",")
+}
+
+// certificateTrusted reports whether cert is trusted on its port
+func (c *Console) certificateTrusted(cert *CertificateInfo) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, trusted := range c.trustedCerts {
+		if trusted.Port == cert.Port && trusted.Fingerprint == cert.Fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// firefoxSHA256OID identifies SHA-256 fingerprints in cert_override.txt
+const firefoxSHA256OID = "OID.2.16.840.1.101.3.4.2.1"
+
+// writeFirefoxCertOverride appends a certificate exception for cert to the
+// cert_override.txt of a Firefox profile, in the format of current Firefox releases
+func writeFirefoxCertOverride(profile string, cert *CertificateInfo) error {
+	path := filepath.Join(profile, "cert_override.txt")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	host := cert.Host
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	line := fmt.Sprintf("%s:%d:\t%s\t%s\t%s\n", host, cert.Port, firefoxSHA256OID, cert.Fingerprint, firefoxDBKey(cert.Certificate))
+	if _, err := f.WriteString(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// firefoxDBKey builds the NSS database key Firefox stores with an exception: module and
+// slot IDs (unused), the lengths of the serial number and issuer, then both in DER
+func firefoxDBKey(cert *x509.Certificate) string {
+	serial := cert.SerialNumber.Bytes()
+	if len(serial) == 0 || serial[0]&0x80 != 0 {
+		serial = append([]byte{0}, serial...)
+	}
+	key := make([]byte, 16, 16+len(serial)+len(cert.RawIssuer))
+	binary.BigEndian.PutUint32(key[8:], uint32(len(serial)))
+	binary.BigEndian.PutUint32(key[12:], uint32(len(cert.RawIssuer)))
+	key = append(key, serial...)
+	key = append(key, cert.RawIssuer...)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// certificateHandler reports the RP-port certificate to the page so operators can compare
+// its fingerprint with the browser warning
+func (c *Console) certificateHandler(w http.ResponseWriter, r *http.Request) {
+	cert, err := c.RPCertificate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	trusted := c.certificateTrusted(cert)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(struct {
+		*CertificateInfo
+		Trusted bool `json:"trusted"`
+	}{cert, trusted})
+}
+
+// certPEMHandler serves the RP-port certificate for import into a browser or trust store
+func (c *Console) certPEMHandler(w http.ResponseWriter, r *http.Request) {
+	cert, err := c.RPCertificate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%d.pem"`, cert.Host, cert.Port))
+	w.Write(cert.PEM())
+}
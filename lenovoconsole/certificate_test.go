@@ -0,0 +1,258 @@
+package lenovoconsole
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+// rpConsole returns a console whose RP port is the fake's HTTPS port, so the fake
+// presents its certificate where the viewer expects the RP service
+func rpConsole(xcc *lenovoconsoletest.Server, config ConsoleConfig) *Console {
+	config.BMCIP = xcc.Addr
+	config.RPPort = xcc.Port()
+	return NewConsole(config)
+}
+
+func TestFetchCertificate(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{TLS: lenovoconsoletest.TLSExpired})
+	defer xcc.Close()
+
+	cert, err := FetchCertificate(xcc.Host(), xcc.Port(), time.Second)
+	if err != nil {
+		t.Fatalf("FetchCertificate: %v", err)
+	}
+	want := sha256.Sum256(xcc.Certificate().Raw)
+	if !cert.Matches(strings.ToLower(strings.ReplaceAll(cert.Fingerprint, ":", " "))) {
+		t.Errorf("Matches rejects its own fingerprint in another form")
+	}
+	if got := strings.ReplaceAll(cert.Fingerprint, ":", ""); got != strings.ToUpper(hex.EncodeToString(want[:])) {
+		t.Errorf("Fingerprint = %s", cert.Fingerprint)
+	}
+	if !strings.Contains(cert.Subject, "XCC-7X06-TEST") {
+		t.Errorf("Subject = %q", cert.Subject)
+	}
+	if cert.Matches("AA:BB") {
+		t.Error("Matches accepted a different fingerprint")
+	}
+
+	plain := lenovoconsoletest.NewServer(lenovoconsoletest.Options{TLS: lenovoconsoletest.TLSPlainHTTP})
+	defer plain.Close()
+	if _, err := FetchCertificate(plain.Host(), plain.Port(), time.Second); err == nil {
+		t.Error("FetchCertificate succeeded against plain HTTP")
+	}
+}
+
+func TestPreAcceptCertificateChromium(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer xcc.Close()
+
+	var asked CertificateInfo
+	c := rpConsole(xcc, ConsoleConfig{
+		Browser: BrowserConfig{Name: BrowserChromium},
+		ConfirmCertificate: func(cert CertificateInfo) bool {
+			asked = cert
+			return true
+		},
+	})
+	if err := c.preAcceptCertificate(); err != nil {
+		t.Fatalf("preAcceptCertificate: %v", err)
+	}
+	if asked.Fingerprint != Fingerprint(xcc.Certificate()) {
+		t.Fatalf("confirmation asked for %q", asked.Fingerprint)
+	}
+
+	args, err := c.browserArgs(familyChromium, "http://localhost:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spki := sha256.Sum256(xcc.Certificate().RawSubjectPublicKeyInfo)
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "--ignore-certificate-errors-spki-list="+base64.StdEncoding.EncodeToString(spki[:])) {
+		t.Errorf("args do not pin the certificate: %s", joined)
+	}
+	for _, arg := range args {
+		if arg == "--ignore-certificate-errors" {
+			t.Error("args still ignore every certificate error")
+		}
+	}
+}
+
+func TestPreAcceptCertificateFirefox(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer xcc.Close()
+
+	c := rpConsole(xcc, ConsoleConfig{
+		Browser:                BrowserConfig{Name: BrowserFirefox, IsolatedProfile: true},
+		CertificateFingerprint: strings.ToLower(Fingerprint(xcc.Certificate())),
+	})
+	defer c.removeBrowserProfile()
+	if err := c.preAcceptCertificate(); err != nil {
+		t.Fatalf("preAcceptCertificate: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.profileDir, "cert_override.txt"))
+	if err != nil {
+		t.Fatalf("no certificate exception written: %v", err)
+	}
+	fields := strings.Split(strings.TrimSuffix(string(data), "\n"), "\t")
+	if len(fields) != 4 || fields[0] != xcc.Addr+":" || fields[1] != firefoxSHA256OID || fields[2] != Fingerprint(xcc.Certificate()) {
+		t.Errorf("cert_override.txt = %q", data)
+	}
+}
+
+func TestPreAcceptCertificateRefused(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer xcc.Close()
+
+	c := rpConsole(xcc, ConsoleConfig{CertificateFingerprint: "00:11:22"})
+	if err := c.preAcceptCertificate(); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("mismatched fingerprint: err = %v", err)
+	}
+
+	c = rpConsole(xcc, ConsoleConfig{
+		Browser:            BrowserConfig{Name: BrowserChromium},
+		ConfirmCertificate: func(CertificateInfo) bool { return false },
+	})
+	if err := c.preAcceptCertificate(); err != nil {
+		t.Fatalf("declined certificate: %v", err)
+	}
+	if c.trustedSPKI() != "" {
+		t.Error("declined certificate was trusted")
+	}
+}
+
+func TestCertificateHandlers(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer xcc.Close()
+
+	c := rpConsole(xcc, ConsoleConfig{})
+	c.setupHandlers()
+
+	rec := httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/certificate", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), Fingerprint(xcc.Certificate())) {
+		t.Errorf("/api/certificate: HTTP %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/cert.pem", nil))
+	block, _ := pem.Decode(rec.Body.Bytes())
+	if rec.Code != http.StatusOK || block == nil || string(block.Bytes) != string(xcc.Certificate().Raw) {
+		t.Errorf("/cert.pem: HTTP %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestPreAcceptHTTPSCertificate(t *testing.T) {
+	web := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer web.Close()
+	rp := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer rp.Close()
+	spki := func(xcc *lenovoconsoletest.Server) string {
+		sum := sha256.Sum256(xcc.Certificate().RawSubjectPublicKeyInfo)
+		return base64.StdEncoding.EncodeToString(sum[:])
+	}
+
+	// Each fake has its own certificate, so the HTTPS port differs from the RP port
+	tests := []struct {
+		name    string
+		confirm func(CertificateInfo) bool
+		want    string
+	}{
+		{"both approved", func(CertificateInfo) bool { return true },
+			"--ignore-certificate-errors-spki-list=" + spki(rp) + "," + spki(web)},
+		{"HTTPS declined", func(cert CertificateInfo) bool { return cert.Port == rp.Port() },
+			"--ignore-certificate-errors-spki-list=" + spki(rp)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var asked []int
+			c := NewConsole(ConsoleConfig{
+				BMCIP:   web.Addr,
+				RPPort:  rp.Port(),
+				Browser: BrowserConfig{Name: BrowserChromium},
+				ConfirmCertificate: func(cert CertificateInfo) bool {
+					asked = append(asked, cert.Port)
+					return tt.confirm(cert)
+				},
+			})
+			if err := c.preAcceptCertificate(); err != nil {
+				t.Fatalf("preAcceptCertificate: %v", err)
+			}
+			if len(asked) != 2 || asked[0] != rp.Port() || asked[1] != web.Port() {
+				t.Errorf("confirmation asked for ports %v, want RP then HTTPS", asked)
+			}
+			if got := c.trustedSPKI(); got != tt.want {
+				t.Errorf("trustedSPKI = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The same certificate on both ports is trusted on both without asking again
+	c := NewConsole(ConsoleConfig{BMCIP: web.Addr, RPPort: rp.Port()})
+	rpCert, err := FetchCertificate(rp.Host(), web.Port(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpCert.Port = rp.Port()
+	if err := c.preAcceptHTTPSCertificate(rpCert); err != nil {
+		t.Fatal(err)
+	}
+	webCert, _ := c.HTTPSCertificate()
+	if !c.certificateTrusted(webCert) {
+		t.Error("HTTPS certificate not trusted")
+	}
+}
+
+func TestFirefoxCertOverride(t *testing.T) {
+	// Serial 0x80 needs a leading zero byte to stay positive in DER
+	issuer := []byte{0x30, 0x03, 0x31, 0x01, 0x00}
+	cert := &x509.Certificate{SerialNumber: big.NewInt(0x80), RawIssuer: issuer}
+	want := []byte{
+		0, 0, 0, 0, 0, 0, 0, 0, // module and slot IDs
+		0, 0, 0, 2, // serial length
+		0, 0, 0, 5, // issuer length
+		0x00, 0x80,
+	}
+	want = append(want, issuer...)
+	if got := firefoxDBKey(cert); got != base64.StdEncoding.EncodeToString(want) {
+		t.Errorf("firefoxDBKey = %s, want %s", got, base64.StdEncoding.EncodeToString(want))
+	}
+
+	cert.SerialNumber = big.NewInt(0x1234)
+	if key, _ := base64.StdEncoding.DecodeString(firefoxDBKey(cert)); len(key) < 18 || key[11] != 2 || key[16] != 0x12 || key[17] != 0x34 {
+		t.Errorf("firefoxDBKey for serial 0x1234 = % x", key)
+	}
+
+	profile := t.TempDir()
+	for _, info := range []*CertificateInfo{
+		{Host: "10.0.0.1", Port: 3900, Fingerprint: "AA:BB", Certificate: cert},
+		{Host: "fd00::1", Port: 443, Fingerprint: "CC:DD", Certificate: cert},
+	} {
+		if err := writeFirefoxCertOverride(profile, info); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(profile, "cert_override.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := firefoxDBKey(cert)
+	wantFile := "10.0.0.1:3900:\t" + firefoxSHA256OID + "\tAA:BB\t" + key + "\n" +
+		"[fd00::1]:443:\t" + firefoxSHA256OID + "\tCC:DD\t" + key + "\n"
+	if string(data) != wantFile {
+		t.Errorf("cert_override.txt = %q\nwant %q", data, wantFile)
+	}
+}
@@ -0,0 +1,263 @@
+package lenovoconsole
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultCheckTimeout is the per-step timeout used by Check when none is given
+const DefaultCheckTimeout = 10 * time.Second
+
+// CheckResult is the outcome of a single preflight step
+type CheckResult struct {
+	Name       string `json:"name"`
+	Passed     bool   `json:"passed"`
+	Skipped    bool   `json:"skipped,omitempty"`
+	Detail     string `json:"detail"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// CheckReport is the full preflight report for one BMC
+type CheckReport struct {
+	BMCIP   string        `json:"bmc_ip"`
+	RPPort  int           `json:"rp_port"`
+	Passed  bool          `json:"passed"`
+	Results []CheckResult `json:"results"`
+}
+
+// Check runs reachability and health checks against a BMC before a console is launched.
+// It tests DNS resolution, TCP reachability of the HTTPS and RP ports, the TLS handshake,
+// credential validity, the rp_port API and availability of the RPViewer SDK assets.
+// A timeout of 0 uses DefaultCheckTimeout for each step.
+func Check(config ConsoleConfig, timeout time.Duration) *CheckReport {
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout
+	}
+
+	report := &CheckReport{BMCIP: config.BMCIP, Passed: true}
+	dialer := dialerFor(config.Proxy)
+	provider := providerFor(config.Provider, dialer)
+	client := &http.Client{
+		Transport: bmcTransport(dialer),
+		Timeout:   timeout,
+	}
+
+	add := func(name string, fn func() (string, error)) bool {
+		start := time.Now()
+		detail, err := fn()
+		result := CheckResult{Name: name, Passed: err == nil, Detail: detail, DurationMS: time.Since(start).Milliseconds()}
+		if err != nil {
+			result.Detail = err.Error()
+			report.Passed = false
+		}
+		report.Results = append(report.Results, result)
+		return err == nil
+	}
+	skip := func(name, reason string) {
+		report.Results = append(report.Results, CheckResult{Name: name, Skipped: true, Detail: reason})
+	}
+
+	if !add("dns", func() (string, error) { return checkDNS(config.BMCIP, config.Proxy) }) {
+		for _, name := range []string{"https-port", "tls", "credentials", "rp-port-api", "rp-port", "sdk-assets"} {
+			skip(name, "DNS resolution failed")
+		}
+		return report
+	}
+
+	addr := parseBMCAddressLenient(config.BMCIP)
+	httpsAddr := addr.HostPort()
+	if !add("https-port", func() (string, error) { return checkTCP(dialer, httpsAddr, timeout) }) {
+		for _, name := range []string{"tls", "credentials", "rp-port-api", "sdk-assets"} {
+			skip(name, "HTTPS port unreachable")
+		}
+	} else {
+		add("tls", func() (string, error) { return checkTLS(dialer, httpsAddr, timeout) })
+		add("credentials", func() (string, error) {
+			return checkCredentials(client, config.BMCIP, config.Username, config.Password)
+		})
+		add("rp-port-api", func() (string, error) {
+			port, err := provider.RPPort(config.BMCIP, config.Username, config.Password)
+			if err != nil {
+				return "", err
+			}
+			if config.RPPort == 0 {
+				config.RPPort = port
+			}
+			return fmt.Sprintf("port %d", port), nil
+		})
+		add("sdk-assets", func() (string, error) { return checkSDKAssets(client, config.BMCIP, provider.SDKScripts()) })
+	}
+
+	if config.RPPort == 0 {
+		config.RPPort = 3900
+	}
+	report.RPPort = config.RPPort
+	rpAddr := addr.Dial(config.RPPort)
+	add("rp-port", func() (string, error) { return checkTCP(dialer, rpAddr, timeout) })
+
+	return report
+}
+
+// WriteText writes a human readable pass/fail report
+func (r *CheckReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "BMC %s (RP port %d)\n", r.BMCIP, r.RPPort)
+	for _, res := range r.Results {
+		status := "PASS"
+		if res.Skipped {
+			status = "SKIP"
+		} else if !res.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "  [%s] %-12s %s\n", status, res.Name, res.Detail)
+	}
+	if r.Passed {
+		b.WriteString("Result: PASS\n")
+	} else {
+		b.WriteString("Result: FAIL\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *CheckReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func checkDNS(address, proxy string) (string, error) {
+	addr, err := ParseBMCAddress(address)
+	if err != nil {
+		return "", err
+	}
+	host := addr.Host
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return "literal IP address", nil
+	}
+	if proxy != "" {
+		// Names of BMCs behind a proxy may only resolve on the far side
+		return "resolved by the proxy", nil
+	}
+	addrs, err := net.LookupHost(host)
+	if err != nil {
+		return "", fmt.Errorf("lookup failed: %v", err)
+	}
+	return "resolved to " + strings.Join(addrs, ", "), nil
+}
+
+func checkTCP(d Dialer, addr string, timeout time.Duration) (string, error) {
+	start := time.Now()
+	if err := dialReachable(d, addr, timeout); err != nil {
+		return "", fmt.Errorf("connect to %s failed: %v", addr, err)
+	}
+	return fmt.Sprintf("%s reachable in %s", addr, time.Since(start).Round(time.Millisecond)), nil
+}
+
+func checkTLS(d Dialer, addr string, timeout time.Duration) (string, error) {
+	conn, err := dialTLS(d, addr, timeout, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return "", fmt.Errorf("TLS handshake failed: %v", err)
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return "", fmt.Errorf("server presented no certificate")
+	}
+	cert := state.PeerCertificates[0]
+	fingerprint := sha256.Sum256(cert.Raw)
+	detail := fmt.Sprintf("%s, subject=%q issuer=%q expires=%s sha256=%X",
+		tls.VersionName(state.Version), cert.Subject.CommonName, cert.Issuer.CommonName,
+		cert.NotAfter.Format("2006-01-02"), fingerprint)
+
+	now := time.Now()
+	if now.After(cert.NotAfter) {
+		return "", fmt.Errorf("certificate expired on %s (%s)", cert.NotAfter.Format("2006-01-02"), detail)
+	}
+	if now.Before(cert.NotBefore) {
+		return "", fmt.Errorf("certificate not valid until %s (%s)", cert.NotBefore.Format("2006-01-02"), detail)
+	}
+	return detail, nil
+}
+
+func checkCredentials(client *http.Client, bmcIP, username, password string) (string, error) {
+	req, err := http.NewRequest("GET", bmcURL(bmcIP, "/redfish/v1/Managers"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(username, password)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", fmt.Errorf("credentials rejected for user %q (HTTP %d)", username, resp.StatusCode)
+	case resp.StatusCode >= 400:
+		return "", fmt.Errorf("unexpected HTTP %d from Redfish", resp.StatusCode)
+	}
+	return fmt.Sprintf("user %q accepted", username), nil
+}
+
+func checkRPPortAPI(client *http.Client, bmcIP, username, password string) (int, string, error) {
+	req, err := http.NewRequest("GET", bmcURL(bmcIP, "/api/providers/rp_port"), nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.SetBasicAuth(username, password)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("unexpected HTTP %d", resp.StatusCode)
+	}
+	var result struct {
+		Port int `json:"port"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, "", fmt.Errorf("invalid response: %v", err)
+	}
+	if result.Port <= 0 {
+		return 0, "", fmt.Errorf("response did not contain a port")
+	}
+	return result.Port, fmt.Sprintf("port %d", result.Port), nil
+}
+
+func checkSDKAssets(client *http.Client, bmcIP string, scripts []string) (string, error) {
+	var missing []string
+	for _, script := range scripts {
+		asset := script[strings.LastIndex(script, "/")+1:]
+		resp, err := client.Get(bmcURL(bmcIP, script))
+		if err != nil {
+			missing = append(missing, fmt.Sprintf("%s (%v)", asset, err))
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			missing = append(missing, fmt.Sprintf("%s (HTTP %d)", asset, resp.StatusCode))
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%d/%d assets unavailable: %s", len(missing), len(scripts), strings.Join(missing, ", "))
+	}
+	return fmt.Sprintf("%d assets available", len(scripts)), nil
+}
@@ -0,0 +1,167 @@
+package lenovoconsole
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+// checkResults indexes a report's results by step name
+func checkResults(r *CheckReport) map[string]CheckResult {
+	results := make(map[string]CheckResult)
+	for _, res := range r.Results {
+		results[res.Name] = res
+	}
+	return results
+}
+
+// closedPort returns a local port nothing listens on
+func closedPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	return port
+}
+
+func TestCheck(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer xcc.Close()
+	// The fake's HTTPS port stands in for the RP port
+	xcc.SetRPPort(xcc.Port())
+	noAPI := lenovoconsoletest.NewServer(lenovoconsoletest.Options{NoRPPort: true})
+	defer noAPI.Close()
+	closed := closedPort(t)
+
+	// want maps step names to "pass", "skip" or a substring of the failure
+	tests := []struct {
+		name     string
+		bmc      string
+		password string
+		rpPort   int
+		want     map[string]string
+		wantPort int
+	}{
+		{"healthy", xcc.Addr, lenovoconsoletest.DefaultPassword, 0, map[string]string{
+			"dns": "pass", "https-port": "pass", "tls": "pass", "credentials": "pass",
+			"rp-port-api": "pass", "sdk-assets": "pass", "rp-port": "pass",
+		}, xcc.Port()},
+		{"wrong password", xcc.Addr, "wrong", xcc.Port(), map[string]string{
+			"https-port": "pass", "credentials": `credentials rejected for user "USERID" (HTTP 401)`,
+			"rp-port-api": "unexpected HTTP 401",
+		}, xcc.Port()},
+		{"no rp_port API", noAPI.Addr, lenovoconsoletest.DefaultPassword, 0, map[string]string{
+			"credentials": "pass", "rp-port-api": "unexpected HTTP 404", "rp-port": "connect to 127.0.0.1:3900 failed",
+		}, 3900},
+		{"HTTPS port closed", "127.0.0.1:" + strconv.Itoa(closed), lenovoconsoletest.DefaultPassword, xcc.Port(), map[string]string{
+			"dns": "pass", "https-port": "connect to 127.0.0.1:" + strconv.Itoa(closed) + " failed",
+			"tls": "skip", "credentials": "skip", "rp-port-api": "skip", "sdk-assets": "skip",
+			// The RP port is checked on its own
+			"rp-port": "pass",
+		}, xcc.Port()},
+		{"RP port closed", xcc.Addr, lenovoconsoletest.DefaultPassword, closed, map[string]string{
+			"credentials": "pass", "rp-port-api": "pass", "rp-port": "failed",
+		}, closed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Check(ConsoleConfig{
+				BMCIP:    tt.bmc,
+				Username: lenovoconsoletest.DefaultUsername,
+				Password: tt.password,
+				RPPort:   tt.rpPort,
+			}, 2*time.Second)
+			results := checkResults(report)
+			passed := true
+			for name, want := range tt.want {
+				res, ok := results[name]
+				switch {
+				case !ok:
+					t.Errorf("no %s result", name)
+				case want == "pass":
+					if !res.Passed || res.Skipped {
+						t.Errorf("%s = %+v, want a pass", name, res)
+					}
+				case want == "skip":
+					if !res.Skipped {
+						t.Errorf("%s = %+v, want it skipped", name, res)
+					}
+				default:
+					passed = false
+					if res.Passed || res.Skipped || !strings.Contains(res.Detail, want) {
+						t.Errorf("%s = %+v, want a failure with %q", name, res, want)
+					}
+				}
+			}
+			if report.Passed != passed {
+				t.Errorf("Passed = %v, want %v", report.Passed, passed)
+			}
+			if report.RPPort != tt.wantPort {
+				t.Errorf("RPPort = %d, want %d", report.RPPort, tt.wantPort)
+			}
+		})
+	}
+	if !xcc.Requested("/redfish/v1/Managers") {
+		t.Error("credentials were not checked against /redfish/v1/Managers")
+	}
+}
+
+func TestCheckUnexpectedResponses(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		credentials string // substring of the failure, empty for a pass
+		rpPortAPI   string
+	}{
+		{"server errors", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}, "unexpected HTTP 500 from Redfish", "unexpected HTTP 500"},
+		{"forbidden", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+		}, "credentials rejected", "unexpected HTTP 403"},
+		{"invalid rp_port response", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/providers/rp_port" {
+				w.Write([]byte("<html>login</html>"))
+			}
+		}, "", "invalid response"},
+		{"rp_port response without a port", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/providers/rp_port" {
+				w.Write([]byte(`{"port":0}`))
+			}
+		}, "", "did not contain a port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(tt.handler)
+			defer server.Close()
+			report := Check(ConsoleConfig{
+				BMCIP:    server.Listener.Addr().String(),
+				Username: "USERID",
+				Password: "PASSW0RD",
+			}, 2*time.Second)
+			results := checkResults(report)
+			for name, want := range map[string]string{"credentials": tt.credentials, "rp-port-api": tt.rpPortAPI} {
+				res := results[name]
+				if want == "" {
+					if !res.Passed {
+						t.Errorf("%s = %+v, want a pass", name, res)
+					}
+				} else if res.Passed || !strings.Contains(res.Detail, want) {
+					t.Errorf("%s = %+v, want a failure with %q", name, res, want)
+				}
+			}
+			if report.Passed {
+				t.Error("report passed")
+			}
+		})
+	}
+}
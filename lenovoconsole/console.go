@@ -3,17 +3,16 @@
 package lenovoconsole
 
 import (
-	"crypto/tls"
-	"encoding/json"
+	"context"
 	"fmt"
 	"html/template"
 	"io"
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
-	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -30,14 +29,55 @@ type Credentials struct {
 
 // ConsoleConfig contains configuration for a remote console session
 type ConsoleConfig struct {
-	BMCIP      string // IP address of the BMC/XCC
+	BMCIP      string // BMC/XCC address: host name, IPv4 or IPv6 address, optionally with an HTTPS port (see ParseBMCAddress)
 	Username   string // Username for authentication
 	Password   string // Password for authentication
-	RPPort     int    // Remote Presence port (default: 3900)
+	RPPort     int    // Remote Presence port (0 to ask the provider at Initialize)
 	UseFirefox bool   // Whether to prefer Firefox browser
 	ServerPort int    // Local server port (0 for auto-assign)
+	ListenHost string // Local address the server listens on, e.g. 127.0.0.1 (empty for all interfaces)
+
+	// Proxy routes all connections to the BMC, including RP port checks, through an
+	// upstream: socks5://[user:password@]host[:port], http://[user:password@]host[:port]
+	// (CONNECT) or ssh://[user@]host[:port] (jump host). Empty connects directly. See NewDialer.
+	Proxy string
+
+	Provider Provider // BMC family (nil for the XCC provider)
+
+	Browser            BrowserConfig // Browser selection and launch options
+	StopOnBrowserClose bool          // Stop the console when the user closes the launched browser
+	NoBrowser          bool          // Print the URL instead of opening a browser
+	ShowLANURL         bool          // Also print the console's URL on the network in no-browser mode
+	ShowQRCode         bool          // With ShowLANURL, also print a terminal QR code of that URL
+
+	Template TemplateConfig // Console page layout or custom template
+	Viewer   *ViewerOptions // RPViewer settings (nil for DefaultViewerOptions)
+
+	Reconnect ReconnectPolicy // Automatic reconnect after BMC reboots and firmware updates
+
+	IdleTimeout        time.Duration // Stop the console after this long without page loads or viewer input (0 disables)
+	MaxSessionDuration time.Duration // Stop the console this long after it starts (0 disables)
+	LimitWarning       time.Duration // How long before a limit the page shows a warning banner (default: 1m)
+
+	Audit AuditConfig // Append-only audit log of console activity
+
+	// Certificate pre-acceptance: before the browser opens, the RP-port certificate is
+	// fetched and trusted in the launched browser, see TrustCertificate
+	CertificateFingerprint string                     // Trust the RP-port certificate if its SHA-256 fingerprint matches; refuse to open otherwise
+	ConfirmCertificate     func(CertificateInfo) bool // Ask whether to trust the RP-port certificate (nil skips pre-acceptance)
+
+	PasteRate    int // Key presses per second when pasting text (default: 20)
+	PasteConfirm int // Pastes from the page longer than this many characters need confirmation (default: 200)
+
+	ScreenWatch ScreenWatch // Emit EventScreenMatch when text matching a pattern appears on screen
 }
 
+// pageShutdownGrace is how long Shutdown waits for open pages to close their viewer sessions
+const pageShutdownGrace = time.Second
+
+// rpPortTimeout bounds the rp_port API request
+const rpPortTimeout = 15 * time.Second
+
 // Console represents a remote console session
 type Console struct {
 	config      ConsoleConfig
@@ -45,67 +85,102 @@ type Console struct {
 	server      *http.Server
 	consoleHTML string
 	mux         *http.ServeMux
+	pageToken   string // secret rendered into the console page, required on its POST requests
+
+	mu            sync.Mutex
+	browsers      []*browserProcess // browsers launched and owned by this console
+	profileDir    string            // isolated browser profile, removed on Stop
+	stopping      bool
+	browserClosed chan struct{}
+	closeOnce     sync.Once
+
+	subscribers  map[chan Event]struct{}   // event subscribers, see Subscribe
+	pages        map[chan pageCommand]bool // open console pages listening for commands; true for view-only thumbnails
+	reconnecting bool
+	stopCh       chan struct{} // closed by Stop
+	stopOnce     sync.Once
+	lastActivity time.Time          // last page load or viewer heartbeat, see LastActivity
+	trustedCerts []*CertificateInfo // RP- and HTTPS-port certificates trusted for launched browsers
+	info         *BMCInfo           // last server identity read from Redfish, see CachedInfo
+	dialer       Dialer             // connects to the BMC, see ConsoleConfig.Proxy
+
+	screenshots   map[string]chan screenshotResult // pending Screenshot calls by request ID
+	screenshotSeq int
+	typing        map[string]chan error // pending typeKeysAndWait calls by request ID
+	typingSeq     int
 }
 
 // NewConsole creates a new Console instance with the given configuration
 func NewConsole(config ConsoleConfig) *Console {
 	return &Console{
-		config: config,
-		mux:    http.NewServeMux(),
+		config:        config,
+		dialer:        dialerFor(config.Proxy),
+		pageToken:     newPageToken(),
+		mux:           http.NewServeMux(),
+		browserClosed: make(chan struct{}),
+		subscribers:   make(map[chan Event]struct{}),
+		pages:         make(map[chan pageCommand]bool),
+		stopCh:        make(chan struct{}),
+		screenshots:   make(map[string]chan screenshotResult),
+		typing:        make(map[string]chan error),
 	}
 }
 
 // GetRPPort queries the XCC for the Remote Presence port
 // Returns the port number or 3900 as default if query fails
 func GetRPPort(bmcIP, username, password string) (int, error) {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{Transport: tr}
-
-	url := fmt.Sprintf("https://%s/api/providers/rp_port", bmcIP)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return 3900, nil // default port
-	}
-
-	req.SetBasicAuth(username, password)
-	resp, err := client.Do(req)
+	port, err := getRPPort(nil, bmcIP, username, password)
 	if err != nil {
 		return 3900, nil // default port
 	}
-	defer resp.Body.Close()
+	return port, nil
+}
 
-	var result struct {
-		Port int `json:"port"`
-	}
-	json.NewDecoder(resp.Body).Decode(&result)
+// getRPPort is GetRPPort connecting through d. Unlike GetRPPort it reports failures,
+// so that callers can tell a missing answer from the default port.
+func getRPPort(d Dialer, bmcIP, username, password string) (int, error) {
+	client := &http.Client{Transport: bmcTransport(d), Timeout: rpPortTimeout}
+	port, _, err := checkRPPortAPI(client, bmcIP, username, password)
+	return port, err
+}
 
-	if result.Port > 0 {
-		return result.Port, nil
-	}
-	return 3900, nil // default port
+// DiscoverRPPort asks the console's provider for the remote presence port, connecting
+// through ConsoleConfig.Proxy
+func (c *Console) DiscoverRPPort() (int, error) {
+	return c.provider().RPPort(c.config.BMCIP, c.config.Username, c.config.Password)
 }
 
 // Initialize prepares the console for launch
 func (c *Console) Initialize() error {
+	if _, err := ParseBMCAddress(c.config.BMCIP); err != nil {
+		return err
+	}
+	if _, err := NewDialer(c.config.Proxy); err != nil {
+		return err
+	}
+
 	// Get RP port if not set
-	if c.config.RPPort == 0 {
-		port, err := GetRPPort(c.config.BMCIP, c.config.Username, c.config.Password)
+	if c.rpPort() == 0 {
+		port, err := c.DiscoverRPPort()
 		if err != nil {
 			return fmt.Errorf("failed to get RP port: %v", err)
 		}
+		c.mu.Lock()
 		c.config.RPPort = port
+		c.mu.Unlock()
 	}
 
 	// Generate HTML
-	if err := c.generateHTML(); err != nil {
+	c.mu.Lock()
+	err := c.generateHTML()
+	c.mu.Unlock()
+	if err != nil {
 		return fmt.Errorf("failed to generate HTML: %v", err)
 	}
 
 	// Find available port if not specified
 	if c.config.ServerPort == 0 {
-		port, err := findAvailablePort()
+		port, err := findAvailablePort(c.config.ListenHost)
 		if err != nil {
 			return fmt.Errorf("failed to find available port: %v", err)
 		}
@@ -123,8 +198,12 @@ func (c *Console) Initialize() error {
 // Start begins serving the console on the configured port
 // This method does not block
 func (c *Console) Start() error {
+	c.mu.Lock()
+	c.lastActivity = time.Now()
+	c.mu.Unlock()
+
 	c.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", c.serverPort),
+		Addr:    net.JoinHostPort(c.config.ListenHost, strconv.Itoa(c.serverPort)),
 		Handler: c.mux,
 		// TLSConfig: &tls.Config{
 		// 	MinVersion: tls.VersionTLS12,
@@ -137,18 +216,90 @@ func (c *Console) Start() error {
 		}
 	}()
 
+	if c.config.IdleTimeout > 0 || c.config.MaxSessionDuration > 0 {
+		go c.enforceLimits()
+	}
+	if len(c.config.ScreenWatch.Patterns) > 0 {
+		go c.watchScreen()
+	}
+	c.audit(AuditRecord{Action: AuditConsoleCreated, BMCUser: c.config.Username,
+		Result: fmt.Sprintf("serving on port %d", c.serverPort)})
+
 	// Give server time to start
 	time.Sleep(500 * time.Millisecond)
 
 	return nil
 }
 
-// Stop gracefully shuts down the console server
+// Stop gracefully shuts down the console server.
+// Browsers launched by OpenInBrowser on an isolated profile are terminated; windows
+// opened through the system default browser or on the shared profile are left alone.
 func (c *Console) Stop() error {
+	return c.stop(func(srv *http.Server) error { return srv.Close() })
+}
+
+// Shutdown disconnects the viewer in every open console page, then stops the console
+// like Stop, letting in-flight requests finish until ctx is done
+func (c *Console) Shutdown(ctx context.Context) error {
+	if c.sendCommand(pageCommand{Name: "shutdown", Message: "Console closed"}) > 0 {
+		// Give pages a moment to close the viewer session before the server goes away
+		select {
+		case <-time.After(pageShutdownGrace):
+		case <-ctx.Done():
+		}
+	}
+	return c.stop(func(srv *http.Server) error { return srv.Shutdown(ctx) })
+}
+
+// stop terminates owned browsers, closes the server with closeServer and removes the browser profile
+func (c *Console) stop(closeServer func(*http.Server) error) error {
+	c.mu.Lock()
+	c.stopping = true
+	c.mu.Unlock()
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+		c.audit(AuditRecord{Action: AuditConsoleStopped})
+	})
+
+	err := c.terminateBrowsers()
+	bmcTransport(c.dialer).CloseIdleConnections()
 	if c.server != nil {
-		return c.server.Close()
+		if closeErr := closeServer(c.server); closeErr != nil && err == nil {
+			err = closeErr
+		}
 	}
-	return nil
+	if rmErr := c.removeBrowserProfile(); rmErr != nil && err == nil {
+		err = fmt.Errorf("failed to remove browser profile: %v", rmErr)
+	}
+	return err
+}
+
+// touch records console activity
+func (c *Console) touch() {
+	c.mu.Lock()
+	c.lastActivity = time.Now()
+	c.mu.Unlock()
+}
+
+// LastActivity returns when a console page was last loaded or reported viewer input,
+// or when the console was started
+func (c *Console) LastActivity() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastActivity
+}
+
+// Stopped returns a channel that is closed when the console stops,
+// including when it is stopped by a session limit
+func (c *Console) Stopped() <-chan struct{} {
+	return c.stopCh
+}
+
+// ConnectedPages returns the number of console pages currently open
+func (c *Console) ConnectedPages() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pages)
 }
 
 // GetURL returns the URL to access the console
@@ -165,12 +316,16 @@ func (c *Console) GetPort() int {
 func (c *Console) OpenInBrowser() error {
 	consoleURL := c.GetURL()
 
+	if err := c.preAcceptCertificate(); err != nil {
+		return err
+	}
+
 	cmd, err := c.getBrowserCommand(consoleURL)
 	if err != nil {
 		return err
 	}
 
-	if err := cmd.Start(); err != nil {
+	if err := c.launchBrowser(cmd); err != nil {
 		return fmt.Errorf("failed to open browser: %v", err)
 	}
 
@@ -179,6 +334,8 @@ func (c *Console) OpenInBrowser() error {
 
 // LaunchAndOpen initializes, starts the server, and opens the console in a browser
 // This is a convenience method that combines Initialize, Start, and OpenInBrowser
+// In no-browser mode, or when no display is available, the URL and an SSH
+// forwarding hint are printed instead
 func (c *Console) LaunchAndOpen() error {
 	if err := c.Initialize(); err != nil {
 		return err
@@ -188,13 +345,19 @@ func (c *Console) LaunchAndOpen() error {
 		return err
 	}
 
+	if c.Headless() {
+		fmt.Println("\n✓ Console started (no browser)")
+		c.PrintAccessInfo(os.Stdout)
+		return nil
+	}
+
 	if err := c.OpenInBrowser(); err != nil {
 		return err
 	}
 
 	fmt.Println("\n✓ Console launched in browser")
 	fmt.Printf("Console URL: %s\n", c.GetURL())
-	fmt.Printf("BMC IP: %s (Port: %d)\n", c.config.BMCIP, c.config.RPPort)
+	fmt.Printf("BMC IP: %s (Port: %d)\n", c.config.BMCIP, c.rpPort())
 	fmt.Println("✓ This console instance is running independently")
 
 	return nil
@@ -205,29 +368,39 @@ func (c *Console) WaitForever() {
 	select {}
 }
 
-// generateHTML creates the HTML content for the console viewer
+// generateHTML creates the HTML content for the console viewer. Callers hold c.mu.
 func (c *Console) generateHTML() error {
-	tmpl, err := template.New("console").Parse(htmlTemplate)
+	tmpl, err := c.pageTemplate()
+	if err != nil {
+		return err
+	}
+
+	viewer, err := c.viewerOptions()
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %v", err)
+		return fmt.Errorf("invalid viewer options: %v", err)
 	}
 
 	var buf strings.Builder
-	data := struct {
-		BMCIP       string
-		RPPort      int
-		BMCUsername string
-		BMCPassword string
-	}{
-		BMCIP:       c.config.BMCIP,
-		RPPort:      c.config.RPPort,
-		BMCUsername: c.config.Username,
-		BMCPassword: c.config.Password,
+	data := TemplateData{
+		Title:        c.pageTitle(nil),
+		BMCIP:        c.bmc().String(),
+		BMCHost:      c.bmc().URLHostname(),
+		BMCOrigin:    c.bmc().Origin(),
+		RPPort:       c.config.RPPort,
+		BMCUsername:  c.config.Username,
+		BMCPassword:  c.config.Password,
+		Viewer:       viewer,
+		Scripts:      c.provider().SDKScripts(),
+		ViewerScript: template.JS(c.provider().ViewerScript()),
+		PageToken:    c.pageToken,
 	}
 
 	if err := tmpl.Execute(&buf, data); err != nil {
 		return fmt.Errorf("failed to execute template: %v", err)
 	}
+	if err := validatePage(buf.String()); err != nil {
+		return fmt.Errorf("invalid template: %v", err)
+	}
 
 	c.consoleHTML = buf.String()
 	return nil
@@ -237,36 +410,45 @@ func (c *Console) generateHTML() error {
 func (c *Console) setupHandlers() {
 	// Main console handler
 	c.mux.HandleFunc("/", c.consoleHandler)
-	c.mux.HandleFunc("/cert.pem", certHandler)
+	c.mux.HandleFunc("/cert.pem", c.certPEMHandler)
+	c.mux.HandleFunc("/api/certificate", c.certificateHandler)
+	c.mux.HandleFunc("/api/sessions", c.sessionsHandler)
+	c.mux.HandleFunc("/api/info", c.infoHandler)
+	c.mux.HandleFunc("/api/events", c.eventsHandler)
+	c.mux.HandleFunc("/api/control", c.controlHandler)
+	c.mux.HandleFunc("/api/heartbeat", c.heartbeatHandler)
+	c.mux.HandleFunc("/api/paste", c.pasteHandler)
+	c.mux.HandleFunc("/api/typed", c.typedHandler)
+	c.mux.HandleFunc("/api/screenshot", c.screenshotHandler)
 
 	// Proxy handlers for SDK files
 	proxyHandler := c.proxySDKHandler()
-	c.mux.HandleFunc("/SDK_Pilot4/", proxyHandler)
-	c.mux.HandleFunc("/offscreenworker.js", proxyHandler)
-	c.mux.HandleFunc("/mouseworker.js", proxyHandler)
-	c.mux.HandleFunc("/utility.js", proxyHandler)
-	c.mux.HandleFunc("/mediaTypes.js", proxyHandler)
-	c.mux.HandleFunc("/rphandlers.js", proxyHandler)
-	c.mux.HandleFunc("/websockethandler.js", proxyHandler)
-	c.mux.HandleFunc("/virtualkeyboard.js", proxyHandler)
-	c.mux.HandleFunc("/mediaworkerhandler.js", proxyHandler)
+	for _, path := range c.provider().ProxyPaths() {
+		c.mux.HandleFunc(path, proxyHandler)
+	}
 }
 
 // consoleHandler serves the main console HTML
 func (c *Console) consoleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		c.touch()
+		c.auditRequest(r, AuditRecord{Action: AuditPageLoad})
+	}
+
+	c.mu.Lock()
+	html := c.consoleHTML
+	c.mu.Unlock()
+
 	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(c.consoleHTML))
+	w.Write([]byte(html))
 }
 
 // proxySDKHandler creates a handler to proxy SDK files from BMC
 func (c *Console) proxySDKHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		client := &http.Client{Transport: tr}
+		client := &http.Client{Transport: bmcTransport(c.dialer)}
 
-		bmcURL := fmt.Sprintf("https://%s%s", c.config.BMCIP, r.URL.Path)
+		bmcURL := c.bmc().URL(r.URL.Path)
 
 		req, err := http.NewRequest(r.Method, bmcURL, nil)
 		if err != nil {
@@ -303,181 +485,9 @@ func (c *Console) proxySDKHandler() http.HandlerFunc {
 	}
 }
 
-// getBrowserCommand returns the appropriate command to open the browser
-func (c *Console) getBrowserCommand(url string) (*exec.Cmd, error) {
-	switch runtime.GOOS {
-	case "windows":
-		return c.getWindowsBrowserCommand(url)
-	case "darwin":
-		return c.getDarwinBrowserCommand(url)
-	case "linux":
-		return c.getLinuxBrowserCommand(url)
-	default:
-		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
-	}
-}
-
-func (c *Console) getWindowsBrowserCommand(url string) (*exec.Cmd, error) {
-	if c.config.UseFirefox {
-		firefoxPaths := []string{
-			"C:\\Program Files\\Mozilla Firefox\\firefox.exe",
-			"C:\\Program Files (x86)\\Mozilla Firefox\\firefox.exe",
-		}
-		for _, ffPath := range firefoxPaths {
-			if _, err := os.Stat(ffPath); err == nil {
-				return exec.Command(ffPath, url), nil
-			}
-		}
-	}
-
-	// Try Chrome
-	chromePath := "C:\\Program Files\\Google\\Chrome\\Application\\chrome.exe"
-	if _, err := os.Stat(chromePath); err == nil {
-		return exec.Command(chromePath,
-			"--ignore-certificate-errors",
-			"--test-type",
-			"--allow-insecure-localhost",
-			"--disable-popup-blocking",
-			"--disable-blink-features=AutomationControlled",
-			"--disable-session-crashed-bubble",
-			"--disable-infobars",
-			"--no-first-run",
-			"--no-default-browser-check",
-			"--user-data-dir="+os.TempDir()+"/chrome-temp-profile",
-			url), nil
-	}
-
-	// Fallback to default browser
-	return exec.Command("rundll32", "url.dll,FileProtocolHandler", url), nil
-}
-
-func (c *Console) getDarwinBrowserCommand(url string) (*exec.Cmd, error) {
-	if c.config.UseFirefox {
-		firefoxPath := "/Applications/Firefox.app/Contents/MacOS/firefox"
-		if _, err := os.Stat(firefoxPath); err == nil {
-			return exec.Command(firefoxPath, url), nil
-		}
-	}
-
-	// Try Chrome
-	chromePath := "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"
-	if _, err := os.Stat(chromePath); err == nil {
-		return exec.Command(chromePath,
-			"--ignore-certificate-errors",
-			"--test-type",
-			"--allow-insecure-localhost",
-			"--disable-popup-blocking",
-			"--disable-blink-features=AutomationControlled",
-			"--disable-session-crashed-bubble",
-			"--disable-infobars",
-			"--no-first-run",
-			"--no-default-browser-check",
-			"--user-data-dir="+os.TempDir()+"/chrome-temp-profile",
-			url), nil
-	}
-
-	// Fallback to default browser
-	return exec.Command("open", url), nil
-}
-
-func (c *Console) getLinuxBrowserCommand(url string) (*exec.Cmd, error) {
-	if c.config.UseFirefox {
-		firefoxPaths := []string{
-			"/usr/bin/firefox",
-			"/usr/local/bin/firefox",
-			"/snap/bin/firefox",
-		}
-		for _, ffPath := range firefoxPaths {
-			if _, err := os.Stat(ffPath); err == nil {
-				return exec.Command(ffPath, url), nil
-			}
-		}
-	}
-
-	// Try Chrome/Chromium
-	chromePaths := []string{
-		"/usr/bin/google-chrome",
-		"/usr/bin/google-chrome-stable",
-		"/usr/bin/chromium",
-		"/usr/bin/chromium-browser",
-	}
-	for _, chromePath := range chromePaths {
-		if _, err := os.Stat(chromePath); err == nil {
-			return exec.Command(chromePath,
-				"--ignore-certificate-errors",
-				"--test-type",
-				"--allow-insecure-localhost",
-				"--disable-popup-blocking",
-				"--disable-blink-features=AutomationControlled",
-				"--disable-session-crashed-bubble",
-				"--disable-infobars",
-				"--no-first-run",
-				"--no-default-browser-check",
-				"--user-data-dir="+os.TempDir()+"/chrome-temp-profile",
-				url), nil
-		}
-	}
-
-	// Fallback to default browser
-	return exec.Command("xdg-open", url), nil
-}
-
-// certHandler serves a dummy certificate page for RPViewer
-func certHandler(w http.ResponseWriter, r *http.Request) {
-	certHTML := `<!DOCTYPE html>
-<html>
-<head>
-    <title>Certificate Acceptance</title>
-    <script>
-        function acceptCertificate() {
-            if (window.opener && !window.opener.closed) {
-                try {
-                    window.opener.postMessage({
-                        type: 'certificate',
-                        action: 'accept',
-                        accepted: true
-                    }, '*');
-                    window.opener.postMessage('CERT_ACCEPTED', '*');
-                } catch(e) {
-                    console.log('Could not post message to opener:', e);
-                }
-            }
-            
-            try {
-                localStorage.setItem('rpviewer_cert_accepted', 'true');
-            } catch(e) {
-                console.log('Could not set localStorage:', e);
-            }
-            
-            if (window.opener && window.opener.rpCertAccepted) {
-                try {
-                    window.opener.rpCertAccepted();
-                } catch(e) {
-                    console.log('Could not call rpCertAccepted:', e);
-                }
-            }
-            
-            setTimeout(function() {
-                window.close();
-            }, 100);
-        }
-        
-        window.onload = acceptCertificate;
-        acceptCertificate();
-    </script>
-</head>
-<body style="font-family: Arial, sans-serif; padding: 20px;">
-    <h3>Certificate Handler</h3>
-    <p>The SSL certificate has been accepted. This window will close automatically.</p>
-</body>
-</html>`
-	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(certHTML))
-}
-
-// findAvailablePort finds an available port on the system
-func findAvailablePort() (int, error) {
-	listener, err := net.Listen("tcp", "127.0.0.1:0")
+// findAvailablePort finds a port that is available on host
+func findAvailablePort(host string) (int, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,304 @@
+package lenovoconsole
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+func TestGetRPPort(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     lenovoconsoletest.Options
+		password string
+		want     int
+	}{
+		{"reported port", lenovoconsoletest.Options{RPPort: 3911}, lenovoconsoletest.DefaultPassword, 3911},
+		{"wrong password", lenovoconsoletest.Options{RPPort: 3911}, "wrong", 3900},
+		{"auth failure", lenovoconsoletest.Options{RPPort: 3911, AuthFailures: -1}, lenovoconsoletest.DefaultPassword, 3900},
+		{"no rp_port API", lenovoconsoletest.Options{RPPort: 3911, NoRPPort: true}, lenovoconsoletest.DefaultPassword, 3900},
+		{"expired certificate", lenovoconsoletest.Options{RPPort: 3911, TLS: lenovoconsoletest.TLSExpired}, lenovoconsoletest.DefaultPassword, 3911},
+		{"legacy TLS", lenovoconsoletest.Options{RPPort: 3911, TLS: lenovoconsoletest.TLSLegacy}, lenovoconsoletest.DefaultPassword, 3900},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xcc := lenovoconsoletest.NewServer(tt.opts)
+			defer xcc.Close()
+
+			got, err := GetRPPort(xcc.Addr, lenovoconsoletest.DefaultUsername, tt.password)
+			if err != nil {
+				t.Fatalf("GetRPPort returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetRPPort = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxySDKHandler(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{
+		Assets: map[string]string{"rpviewer.js": "function RPViewer() {}"},
+	})
+	defer xcc.Close()
+
+	c := NewConsole(ConsoleConfig{BMCIP: xcc.Addr})
+	handler := c.proxySDKHandler()
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/SDK_Pilot4/rpviewer.js", http.StatusOK, "function RPViewer() {}"},
+		{"/SDK_Pilot4/utility.js", http.StatusOK, "stub for utility.js"},
+		{"/mouseworker.js", http.StatusOK, "stub for mouseworker.js"},
+		{"/SDK_Pilot4/missing.js", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest("GET", tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/javascript" {
+				t.Errorf("Content-Type = %q, want application/javascript", got)
+			}
+		})
+	}
+
+	if !xcc.Requested("/SDK_Pilot4/rpviewer.js") {
+		t.Error("fake XCC did not receive the proxied request")
+	}
+}
+
+func TestProxySDKHandlerUnreachable(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{TLS: lenovoconsoletest.TLSPlainHTTP})
+	defer xcc.Close()
+
+	c := NewConsole(ConsoleConfig{BMCIP: xcc.Addr})
+	rec := httptest.NewRecorder()
+	c.proxySDKHandler()(rec, httptest.NewRequest("GET", "/SDK_Pilot4/rpviewer.js", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestGenerateHTML(t *testing.T) {
+	c := NewConsole(ConsoleConfig{
+		BMCIP:    "10.0.0.1",
+		Username: "admin",
+		Password: `pa'ss</script>`,
+		RPPort:   3911,
+	})
+	if err := c.generateHTML(); err != nil {
+		t.Fatalf("generateHTML: %v", err)
+	}
+
+	html := c.consoleHTML
+	for _, want := range []string{
+		"<title>Lenovo XCC Remote Console - 10.0.0.1</title>",
+		"rpPort:  3911 ",
+		`id="toolbar"`,
+		`"exclusiveLogin":false`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+	if strings.Contains(html, `pa'ss</script>`) {
+		t.Error("password is not escaped")
+	}
+}
+
+func TestGenerateHTMLTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  TemplateConfig
+		want    string
+		wantErr string
+	}{
+		{"embed layout", TemplateConfig{Layout: LayoutEmbed}, `<canvas id="kvmCanvas">`, ""},
+		{"unknown layout", TemplateConfig{Layout: "grid"}, "", "unknown layout"},
+		{
+			"custom template",
+			TemplateConfig{FS: fstest.MapFS{"console.html": {Data: []byte(
+				`<h1>{{.BMCIP}}</h1><canvas id="kvmCanvas"></canvas>{{template "viewer" .}}`)}}},
+			"<h1>10.0.0.1</h1>", "",
+		},
+		{
+			"template without viewer",
+			TemplateConfig{FS: fstest.MapFS{"console.html": {Data: []byte(`<canvas id="kvmCanvas"></canvas>`)}}},
+			"", "does not render the viewer script",
+		},
+		{
+			"template without canvas",
+			TemplateConfig{FS: fstest.MapFS{"console.html": {Data: []byte(`{{template "viewer" .}}`)}}},
+			"", "kvmCanvas",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", RPPort: 3900, Template: tt.config})
+			err := c.generateHTML()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("generateHTML error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("generateHTML: %v", err)
+			}
+			if !strings.Contains(c.consoleHTML, tt.want) {
+				t.Errorf("page does not contain %q", tt.want)
+			}
+		})
+	}
+}
+
+func TestSetupHandlers(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{RPPort: 3911})
+	defer xcc.Close()
+
+	c := NewConsole(ConsoleConfig{
+		BMCIP:    xcc.Addr,
+		Username: lenovoconsoletest.DefaultUsername,
+		Password: lenovoconsoletest.DefaultPassword,
+	})
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	server := httptest.NewServer(c.mux)
+	defer server.Close()
+
+	tests := []struct {
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"GET", "/", "", http.StatusOK, "rpPort:  3911 "},
+		{"GET", "/cert.pem", "", http.StatusBadGateway, "TLS handshake"},
+		{"GET", "/SDK_Pilot4/rpviewer.js", "", http.StatusOK, "stub RPViewer"},
+		{"GET", "/websockethandler.js", "", http.StatusOK, "stub for websockethandler.js"},
+		{"GET", "/api/sessions", "", http.StatusOK, `"sessions":[]`},
+		{"GET", "/api/events", "", http.StatusMethodNotAllowed, ""},
+		{"POST", "/api/events", `{"type":"bogus"}`, http.StatusBadRequest, "Unknown event type"},
+		{"POST", "/api/events", `{"type":"login","code":0}`, http.StatusNoContent, ""},
+		{"GET", "/api/heartbeat", "", http.StatusMethodNotAllowed, ""},
+		{"POST", "/api/heartbeat", "", http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(pageTokenHeader, c.pageToken)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", resp.StatusCode, tt.wantStatus, body)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+	if !strings.Contains(c.consoleHTML, "pageToken: '"+c.pageToken+"'") {
+		t.Error("page does not carry the console token")
+	}
+}
+
+// pageRequest returns a POST request as the console page sends it
+func pageRequest(c *Console, target, contentType string, body io.Reader) *http.Request {
+	req := httptest.NewRequest("POST", target, body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(pageTokenHeader, c.pageToken)
+	return req
+}
+
+func TestPagePostForgery(t *testing.T) {
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", RPPort: 3900})
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	events, cancel := c.Subscribe()
+	defer cancel()
+
+	tests := []struct {
+		name, path, token, contentType string
+		want                           int
+	}{
+		{"no token", "/api/events", "", "application/json", http.StatusForbidden},
+		{"wrong token", "/api/events", "0123", "application/json", http.StatusForbidden},
+		{"form post", "/api/events", c.pageToken, "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"text post", "/api/events", c.pageToken, "text/plain", http.StatusUnsupportedMediaType},
+		{"heartbeat", "/api/heartbeat", "", "", http.StatusForbidden},
+		{"screenshot", "/api/screenshot?id=1&error=x", "", "", http.StatusForbidden},
+		{"typed", "/api/typed?id=1", "", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.path, strings.NewReader(`{"type":"exit"}`))
+		if tt.token != "" {
+			req.Header.Set(pageTokenHeader, tt.token)
+		}
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		rec := httptest.NewRecorder()
+		c.mux.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+	select {
+	case e := <-events:
+		t.Errorf("forged request emitted %+v", e)
+	default:
+	}
+	if other := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1"}); other.pageToken == c.pageToken || len(c.pageToken) != 32 {
+		t.Errorf("page tokens %q and %q", c.pageToken, other.pageToken)
+	}
+}
+
+func TestEventsHandlerEmits(t *testing.T) {
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", RPPort: 3900})
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	events, cancel := c.Subscribe()
+	defer cancel()
+
+	rec := httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, pageRequest(c, "/api/events", "application/json", strings.NewReader(`{"type":"terminated","code":3}`)))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+
+	e := <-events
+	got, _ := json.Marshal(e)
+	if e.Type != EventSessionTerminated || e.Code != 3 || e.Message != "Reboot" || e.BMCIP != "10.0.0.1" {
+		t.Errorf("unexpected event %s", got)
+	}
+}
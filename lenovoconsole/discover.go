@@ -0,0 +1,309 @@
+package lenovoconsole
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Discovery defaults and limits
+const (
+	defaultDiscoverConcurrency = 64
+	defaultDiscoverTimeout     = 3 * time.Second
+	maxDiscoverAddresses       = 1 << 16 // a /16 of IPv4 addresses
+)
+
+// DiscoverOptions controls a Discover scan
+type DiscoverOptions struct {
+	Ports       []int         // HTTPS ports probed on every address (default: 443)
+	Concurrency int           // Addresses probed at once (default: 64)
+	Timeout     time.Duration // Limit for probing one address and port (default: 3s)
+
+	// Username and Password, if set, are used for the rp_port API and to read the model,
+	// firmware and serial number through Redfish. Without them only unauthenticated
+	// endpoints are read and the firmware version is unknown.
+	Username string
+	Password string
+
+	Proxy string // Upstream for probe connections, see ConsoleConfig.Proxy
+
+	Progress func(DiscoveredBMC) // Called as each BMC is found
+}
+
+// DiscoveredBMC is a Lenovo BMC found by Discover
+type DiscoveredBMC struct {
+	Address        string `json:"address"`            // BMC address as accepted by ConsoleConfig.BMCIP
+	Provider       string `json:"provider,omitempty"` // ProviderXCC, ProviderIMM2 or empty if unknown
+	Model          string `json:"model,omitempty"`
+	Firmware       string `json:"firmware,omitempty"`
+	SerialNumber   string `json:"serial_number,omitempty"`
+	HostName       string `json:"host_name,omitempty"`
+	Banner         string `json:"banner,omitempty"`      // Title of the web login page
+	Certificate    string `json:"certificate,omitempty"` // Subject of the HTTPS certificate
+	RedfishVersion string `json:"redfish_version,omitempty"`
+	RPPortAPI      bool   `json:"rp_port_api"`
+	RPPort         int    `json:"rp_port,omitempty"` // Only known with credentials
+}
+
+// DiscoveryReport is the outcome of a Discover scan, with BMCs in address order
+type DiscoveryReport struct {
+	Scanned int             `json:"scanned"` // Address and port pairs probed
+	Found   []DiscoveredBMC `json:"found"`
+}
+
+// Discover scans CIDR ranges, or single addresses, for Lenovo BMCs. Every address is
+// probed on each HTTPS port for the web login page, the Redfish service root and the
+// rp_port API; endpoints that are not Lenovo BMCs are left out of the report. A scan
+// ended by ctx returns what was found so far along with ctx's error.
+func Discover(ctx context.Context, ranges []string, opts DiscoverOptions) (*DiscoveryReport, error) {
+	if len(opts.Ports) == 0 {
+		opts.Ports = []int{443}
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultDiscoverConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultDiscoverTimeout
+	}
+	for _, port := range opts.Ports {
+		if port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+	}
+
+	var addresses []net.IP
+	for _, r := range ranges {
+		ips, err := expandRange(r, maxDiscoverAddresses-len(addresses))
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, ips...)
+	}
+
+	type probe struct {
+		index int
+		ip    net.IP
+		port  int
+	}
+	probes := make(chan probe)
+	results := make([]*DiscoveredBMC, len(addresses)*len(opts.Ports))
+	dialer, err := NewDialer(opts.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	transport := newBMCTransport(dialer)
+	transport.DisableKeepAlives = true
+	client := &http.Client{
+		Transport: transport,
+		// Do not follow redirects away from the login page
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency && i < len(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range probes {
+				bmc := probeBMC(ctx, client, dialer, p.ip, p.port, opts)
+				if bmc == nil {
+					continue
+				}
+				mu.Lock()
+				results[p.index] = bmc
+				if opts.Progress != nil {
+					opts.Progress(*bmc)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	report := &DiscoveryReport{Found: []DiscoveredBMC{}}
+	index := 0
+scan:
+	for _, ip := range addresses {
+		for _, port := range opts.Ports {
+			select {
+			case probes <- probe{index, ip, port}:
+				index++
+			case <-ctx.Done():
+				break scan
+			}
+		}
+	}
+	close(probes)
+	wg.Wait()
+
+	report.Scanned = index
+	for _, bmc := range results {
+		if bmc != nil {
+			report.Found = append(report.Found, *bmc)
+		}
+	}
+	return report, ctx.Err()
+}
+
+// expandRange returns the host addresses of a CIDR range or a single IP address,
+// refusing ranges with more than limit addresses
+func expandRange(r string, limit int) ([]net.IP, error) {
+	if ip := net.ParseIP(r); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	_, network, err := net.ParseCIDR(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range %q: want a CIDR such as 10.0.0.0/24 or an IP address", r)
+	}
+
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	if size.Cmp(big.NewInt(int64(limit))) > 0 {
+		return nil, fmt.Errorf("range %s has %s addresses, more than the %d a scan may cover", r, size, maxDiscoverAddresses)
+	}
+
+	first := new(big.Int).SetBytes(network.IP)
+	n := int(size.Int64())
+	ips := make([]net.IP, 0, n)
+	for i := 0; i < n; i++ {
+		// Skip the network and broadcast addresses of IPv4 subnets
+		if bits == 32 && n > 2 && (i == 0 || i == n-1) {
+			continue
+		}
+		b := new(big.Int).Add(first, big.NewInt(int64(i))).Bytes()
+		ip := make(net.IP, len(network.IP))
+		copy(ip[len(ip)-len(b):], b)
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+// htmlTitle extracts the title of an HTML page
+var htmlTitle = regexp.MustCompile(`(?is)<title[^>]*>\s*(.*?)\s*</title>`)
+
+// probeBMC checks one address and port, returning nil unless a Lenovo BMC answers there
+func probeBMC(ctx context.Context, client *http.Client, dialer Dialer, ip net.IP, port int, opts DiscoverOptions) *DiscoveredBMC {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	host := ip.String()
+	address := host
+	if port != 443 {
+		address = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	base := "https://" + net.JoinHostPort(host, strconv.Itoa(port))
+	bmc := &DiscoveredBMC{Address: address}
+
+	// HTTPS banner: the login page title and the certificate subject
+	resp, err := discoverGet(ctx, client, base+"/", "", "")
+	if err != nil {
+		return nil
+	}
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		bmc.Certificate = resp.TLS.PeerCertificates[0].Subject.String()
+	}
+	page, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	if m := htmlTitle.FindSubmatch(page); m != nil {
+		bmc.Banner = strings.TrimSpace(string(m[1]))
+	}
+
+	// Redfish service root, readable without credentials
+	var root struct {
+		RedfishVersion string `json:"RedfishVersion"`
+		Vendor         string `json:"Vendor"`
+		Product        string `json:"Product"`
+		Oem            struct {
+			Lenovo json.RawMessage `json:"Lenovo"`
+		} `json:"Oem"`
+	}
+	lenovoRedfish := false
+	if resp, err := discoverGet(ctx, client, base+"/redfish/v1/", "", ""); err == nil {
+		if resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(&root) == nil {
+			bmc.RedfishVersion, bmc.Model = root.RedfishVersion, root.Product
+			lenovoRedfish = strings.EqualFold(root.Vendor, "Lenovo") || root.Oem.Lenovo != nil
+		}
+		resp.Body.Close()
+	}
+
+	// rp_port API: answered with 401 without credentials, missing on IMM2
+	if resp, err := discoverGet(ctx, client, base+"/api/providers/rp_port", opts.Username, opts.Password); err == nil {
+		switch resp.StatusCode {
+		case http.StatusOK:
+			var result struct {
+				Port int `json:"port"`
+			}
+			if json.NewDecoder(resp.Body).Decode(&result) == nil {
+				bmc.RPPortAPI, bmc.RPPort = true, result.Port
+			}
+		case http.StatusUnauthorized, http.StatusForbidden:
+			bmc.RPPortAPI = true
+		}
+		resp.Body.Close()
+	}
+
+	title, subject := strings.ToLower(bmc.Banner), strings.ToLower(bmc.Certificate)
+	switch {
+	case strings.Contains(title, "xclarity"):
+		bmc.Provider = ProviderXCC
+	case strings.Contains(title, "integrated management module") || strings.Contains(title, "imm2"):
+		bmc.Provider = ProviderIMM2
+	case lenovoRedfish && bmc.RPPortAPI, strings.HasPrefix(subject, "cn=xcc"):
+		bmc.Provider = ProviderXCC
+	case lenovoRedfish, strings.Contains(title+" "+subject, "lenovo"):
+		// A Lenovo BMC of a kind the console has no provider for
+	default:
+		return nil
+	}
+
+	if opts.Username != "" {
+		if info, err := getBMCInfo(dialer, address, opts.Username, opts.Password); err == nil {
+			bmc.HostName, bmc.SerialNumber, bmc.Firmware = info.HostName, info.SerialNumber, info.FirmwareVersion
+			if info.Model != "" {
+				bmc.Model = info.Model
+			}
+		}
+	}
+	return bmc
+}
+
+// discoverGet makes a GET request for a discovery probe
+func discoverGet(ctx context.Context, client *http.Client, url, username, password string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	return client.Do(req)
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *DiscoveryReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the BMCs found as CSV with a header row
+func (r *DiscoveryReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"address", "model", "firmware", "provider", "serial_number", "host_name", "redfish_version", "rp_port_api", "banner"})
+	for _, b := range r.Found {
+		cw.Write([]string{b.Address, b.Model, b.Firmware, b.Provider, b.SerialNumber, b.HostName,
+			b.RedfishVersion, strconv.FormatBool(b.RPPortAPI), b.Banner})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,118 @@
+package lenovoconsole
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+func TestExpandRange(t *testing.T) {
+	tests := []struct {
+		r     string
+		count int
+		first string
+	}{
+		{"10.0.0.7", 1, "10.0.0.7"},
+		{"10.0.0.0/30", 2, "10.0.0.1"},
+		{"10.0.0.4/31", 2, "10.0.0.4"},
+		{"10.1.0.0/16", 65534, "10.1.0.1"},
+		{"fd00::/126", 4, "fd00::"},
+	}
+	for _, tt := range tests {
+		ips, err := expandRange(tt.r, maxDiscoverAddresses)
+		if err != nil || len(ips) != tt.count || ips[0].String() != tt.first {
+			t.Errorf("expandRange(%s) = %d addresses from %v, %v", tt.r, len(ips), ips[:1], err)
+		}
+	}
+	for _, r := range []string{"10.0.0.0/15", "fd00::/64", "xcc01.example.com", "10.0.0.0/33"} {
+		if _, err := expandRange(r, maxDiscoverAddresses); err == nil {
+			t.Errorf("expandRange(%s) accepted", r)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	xcc1 := lenovoconsoletest.NewServer(lenovoconsoletest.Options{RPPort: 3901})
+	defer xcc1.Close()
+	xcc2 := lenovoconsoletest.NewServer(lenovoconsoletest.Options{Model: "ThinkSystem SR630", Firmware: "TGBT88A"})
+	defer xcc2.Close()
+	imm2 := lenovoconsoletest.NewServer(lenovoconsoletest.Options{IMM2: true, Model: "System x3650 M5"})
+	defer imm2.Close()
+	other := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><head><title>Switch management</title></head></html>")
+	}))
+	defer other.Close()
+	closed := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	closed.Close()
+
+	ports := []int{xcc1.Port(), closed.Port(), other.Listener.Addr().(*net.TCPAddr).Port, imm2.Port(), xcc2.Port()}
+
+	var progress int
+	report, err := Discover(context.Background(), []string{"127.0.0.1/32"}, DiscoverOptions{
+		Ports:       ports,
+		Concurrency: 2,
+		Timeout:     5 * time.Second,
+		Progress:    func(DiscoveredBMC) { progress++ },
+	})
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if report.Scanned != 5 || len(report.Found) != 3 || progress != 3 {
+		t.Fatalf("report = %+v", report)
+	}
+	want := []struct {
+		address, provider, model string
+		rpPortAPI                bool
+	}{
+		{xcc1.Addr, ProviderXCC, "ThinkSystem SR650 V2", true},
+		{imm2.Addr, ProviderIMM2, "System x3650 M5", false},
+		{xcc2.Addr, ProviderXCC, "ThinkSystem SR630", true},
+	}
+	for i, w := range want {
+		got := report.Found[i]
+		if got.Address != w.address || got.Provider != w.provider || got.Model != w.model || got.RPPortAPI != w.rpPortAPI {
+			t.Errorf("found[%d] = %+v, want %+v", i, got, w)
+		}
+		if got.Firmware != "" || got.RedfishVersion == "" || !strings.Contains(got.Certificate, "O=Lenovo") {
+			t.Errorf("found[%d] without credentials = %+v", i, got)
+		}
+	}
+
+	// Credentials add the firmware, serial number and RP port
+	report, err = Discover(context.Background(), []string{"127.0.0.1"}, DiscoverOptions{
+		Ports:    []int{xcc1.Port(), xcc2.Port()},
+		Username: lenovoconsoletest.DefaultUsername,
+		Password: lenovoconsoletest.DefaultPassword,
+	})
+	if err != nil || len(report.Found) != 2 {
+		t.Fatalf("Discover with credentials: %+v, %v", report, err)
+	}
+	if got := report.Found[0]; got.Firmware != "TGBT99Z" || got.SerialNumber != "J900TEST" || got.RPPort != 3901 {
+		t.Errorf("found[0] with credentials = %+v", got)
+	}
+	if got := report.Found[1]; got.Firmware != "TGBT88A" {
+		t.Errorf("found[1] with credentials = %+v", got)
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil || len(records) != 3 || records[0][0] != "address" || records[2][0] != xcc2.Addr || records[2][2] != "TGBT88A" {
+		t.Errorf("CSV = %q, %v", records, err)
+	}
+
+	if _, err := Discover(context.Background(), []string{"10.0.0.0/8"}, DiscoverOptions{}); err == nil {
+		t.Error("oversized range accepted")
+	}
+}
@@ -0,0 +1,289 @@
+package lenovoconsole
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// EventType identifies what happened in a console session
+type EventType string
+
+// Event types emitted by a Console
+const (
+	EventLoginResult          EventType = "login"                  // The viewer finished logging in; Code is the login result
+	EventSessionTerminated    EventType = "terminated"             // The XCC ended the session; Code is the termination reason
+	EventViewerExit           EventType = "exit"                   // The viewer closed
+	EventReconnectAttempt     EventType = "reconnect-attempt"      // The supervisor is about to retry; Attempt is set
+	EventReconnectRetryFailed EventType = "reconnect-retry-failed" // A reconnect attempt failed and will be retried; Message says why
+	EventReconnected          EventType = "reconnected"            // The supervisor restored the session
+	EventReconnectFailed      EventType = "reconnect-failed"       // The supervisor gave up
+	EventLimitWarning         EventType = "limit-warning"          // A session limit is close; the page shows a banner
+	EventLimitReached         EventType = "limit-reached"          // A session limit was hit and the console is stopping
+	EventScreenMatch          EventType = "screen-match"           // A ScreenWatch pattern appeared on screen; Message is the matching text
+)
+
+// LoginResults describes the login result codes reported by the RPViewer
+var LoginResults = map[int]string{
+	0:   "Login success",
+	1:   "Login denied",
+	2:   "Invalid user",
+	3:   "Invalid password",
+	4:   "Session in use",
+	5:   "Session full",
+	6:   "Login timeout",
+	7:   "No share available",
+	11:  "Login failed",
+	101: "WebSocket exception",
+	102: "Certificate not verified",
+	103: "Certificate timeout",
+}
+
+// TerminationReasons describes the session termination reason codes reported by the RPViewer
+var TerminationReasons = map[int]string{
+	0: "Admin termination",
+	1: "Timeout",
+	2: "WebSocket error",
+	3: "Reboot",
+	4: "Upgrade",
+	5: "Preempted by another user",
+	6: "Unshare",
+	7: "Exclusive mode",
+	8: "Out of memory",
+}
+
+// Event describes something that happened in a console session
+type Event struct {
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	BMCIP   string    `json:"bmcIp"`
+	Code    int       `json:"code"`              // Login result or termination reason
+	Message string    `json:"message,omitempty"` // Human readable description
+	Attempt int       `json:"attempt,omitempty"` // Reconnect attempt number
+	Pattern string    `json:"pattern,omitempty"` // Screen watch expression that matched
+}
+
+// eventBufferSize is the number of events buffered per subscriber before events are dropped
+const eventBufferSize = 64
+
+// Subscribe returns a channel that receives the console's events and a function
+// that cancels the subscription. Slow subscribers miss events rather than block the console.
+func (c *Console) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	c.mu.Lock()
+	c.subscribers[ch] = struct{}{}
+	c.mu.Unlock()
+
+	cancel := func() {
+		c.mu.Lock()
+		if _, ok := c.subscribers[ch]; ok {
+			delete(c.subscribers, ch)
+			close(ch)
+		}
+		c.mu.Unlock()
+	}
+	return ch, cancel
+}
+
+// emit delivers an event to every subscriber
+func (c *Console) emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.BMCIP = c.config.BMCIP
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ch := range c.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// pageCommand is an instruction pushed from Go to the console page
+type pageCommand struct {
+	Name    string `json:"-"`
+	RPPort  int    `json:"rpPort,omitempty"`
+	Message string `json:"message,omitempty"`
+	Reason  string `json:"reason,omitempty"`  // Session limit that is about to be hit
+	Seconds int    `json:"seconds,omitempty"` // Time left before the limit
+
+	Keys []Keystroke `json:"keys,omitempty"` // Key presses to type, see Paste
+	Rate int         `json:"rate,omitempty"` // Key presses per second
+
+	ID string `json:"id,omitempty"` // Request the page answers, see Screenshot and typeKeysAndWait
+}
+
+// sendCommand pushes a command to every open console page and returns how many received it
+func (c *Console) sendCommand(cmd pageCommand) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for ch := range c.pages {
+		select {
+		case ch <- cmd:
+			n++
+		default:
+		}
+	}
+	return n
+}
+
+// sendCommandToOne pushes a command to a single open console page, for commands that
+// must not be repeated by every open page. View-only thumbnails never receive them.
+func (c *Console) sendCommandToOne(cmd pageCommand) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ch, viewOnly := range c.pages {
+		if viewOnly {
+			continue
+		}
+		select {
+		case ch <- cmd:
+			return true
+		default:
+		}
+	}
+	return false
+}
+
+// pageTokenHeader carries the console's page token on POST requests from the page
+// and on GET /api/screenshot
+const pageTokenHeader = "X-Console-Token"
+
+// Token returns the secret that requests to GET /api/screenshot send in the
+// X-Console-Token header. The console page receives it in TemplateData.PageToken.
+func (c *Console) Token() string {
+	return c.pageToken
+}
+
+// newPageToken returns a random token for a console page
+func newPageToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("lenovoconsole: no random source: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// pagePost checks that r is a POST from the console page. Other sites cannot read the
+// page token, and neither can a cross-site form set the header or a JSON content type,
+// so forged requests are refused. contentType is the media type the body must have, or
+// empty for requests without a body. pagePost writes the error response when it fails.
+func (c *Console) pagePost(w http.ResponseWriter, r *http.Request, contentType string) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return checkToken(w, r, c.pageToken, contentType)
+}
+
+// checkToken checks that r carries token in the X-Console-Token header and, unless
+// contentType is empty, a body of that media type. It writes the error response when
+// it fails.
+func checkToken(w http.ResponseWriter, r *http.Request, token, contentType string) bool {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(pageTokenHeader)), []byte(token)) != 1 {
+		http.Error(w, "Missing or invalid console token", http.StatusForbidden)
+		return false
+	}
+	if contentType != "" {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != contentType {
+			http.Error(w, "Content-Type must be "+contentType, http.StatusUnsupportedMediaType)
+			return false
+		}
+	}
+	return true
+}
+
+// eventsHandler receives session events posted by the console page
+func (c *Console) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.pagePost(w, r, "application/json") {
+		return
+	}
+
+	var posted struct {
+		Type EventType `json:"type"`
+		Code int       `json:"code"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&posted); err != nil {
+		http.Error(w, "Invalid event", http.StatusBadRequest)
+		return
+	}
+
+	e := Event{Type: posted.Type, Code: posted.Code}
+	switch posted.Type {
+	case EventLoginResult:
+		e.Message = LoginResults[posted.Code]
+	case EventSessionTerminated:
+		e.Message = TerminationReasons[posted.Code]
+	case EventViewerExit:
+	default:
+		http.Error(w, "Unknown event type", http.StatusBadRequest)
+		return
+	}
+	if e.Message == "" && posted.Type != EventViewerExit {
+		e.Message = fmt.Sprintf("Unknown code %d", posted.Code)
+	}
+
+	rec := AuditRecord{Action: AuditSessionEnd, BMCUser: c.config.Username, Result: e.Message}
+	if e.Type == EventLoginResult {
+		rec.Action = AuditLogin
+	}
+	if e.Type != EventViewerExit {
+		rec.Code = &e.Code
+	} else {
+		rec.Result = "Viewer closed"
+	}
+	c.auditRequest(r, rec)
+
+	c.emit(e)
+	c.handlePageEvent(e)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// controlHandler streams commands to the console page as server-sent events. Thumbnail
+// pages of the console wall connect with ?thumbnail and get only the commands sent to
+// every page, never input or screenshot requests.
+func (c *Console) controlHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan pageCommand, 8)
+	c.mu.Lock()
+	c.pages[ch] = r.URL.Query().Has("thumbnail")
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pages, ch)
+		c.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case cmd := <-ch:
+			data, _ := json.Marshal(cmd)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", cmd.Name, data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-c.stopCh:
+			return
+		}
+	}
+}
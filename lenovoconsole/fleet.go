@@ -0,0 +1,360 @@
+package lenovoconsole
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Fleet defaults
+const (
+	defaultFleetConcurrency = 10
+	defaultFleetTimeout     = time.Minute
+	defaultFleetRetryDelay  = 2 * time.Second
+)
+
+// FleetTarget is one BMC of a fleet operation
+type FleetTarget struct {
+	BMCIP    string
+	Username string
+	Password string
+	Provider Provider // nil for the XCC provider
+	Proxy    string   // Upstream for connections to the BMC, see ConsoleConfig.Proxy
+}
+
+// config returns a console configuration for the target based on base
+func (t FleetTarget) config(base ConsoleConfig) ConsoleConfig {
+	base.BMCIP, base.Username, base.Password = t.BMCIP, t.Username, t.Password
+	if t.Provider != nil {
+		base.Provider = t.Provider
+	}
+	if t.Proxy != "" {
+		base.Proxy = t.Proxy
+	}
+	return base
+}
+
+// ParseTargets reads a target list with one BMC per line: "BMC [USERNAME [PASSWORD
+// [PROVIDER]]]", separated by spaces or commas. Blank lines and lines starting with #
+// are skipped; missing fields are taken from defaults.
+func ParseTargets(r io.Reader, defaults FleetTarget) ([]FleetTarget, error) {
+	var targets []FleetTarget
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := splitList(text)
+		if len(fields) > 4 {
+			return nil, fmt.Errorf("line %d: too many fields", line)
+		}
+		addr, err := ParseBMCAddress(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		target := defaults
+		target.BMCIP = addr.String()
+		if len(fields) > 1 {
+			target.Username = fields[1]
+		}
+		if len(fields) > 2 {
+			target.Password = fields[2]
+		}
+		if len(fields) > 3 {
+			provider, err := ProviderByName(fields[3])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			target.Provider = provider
+		}
+		targets = append(targets, target)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// FleetOperation is work RunFleet performs against each target
+type FleetOperation struct {
+	Name string
+
+	// Run performs the operation and returns a one line detail and optional data for
+	// JSON output. It should return when ctx ends; RunFleet stops waiting for it then.
+	Run func(ctx context.Context, target FleetTarget) (detail string, data interface{}, err error)
+
+	// NoRetry disables retries, for operations such as power actions that must not be
+	// repeated after a failure whose effect is unknown
+	NoRetry bool
+}
+
+// FleetOptions controls how RunFleet schedules an operation
+type FleetOptions struct {
+	Concurrency int               // Targets worked on at once (default: 10)
+	Timeout     time.Duration     // Limit for each attempt on a target (default: 1m)
+	Retries     int               // Further attempts after a failure
+	RetryDelay  time.Duration     // Pause between attempts (default: 2s)
+	Progress    func(FleetResult) // Called as each target finishes
+}
+
+// FleetResult is the outcome of an operation on one target
+type FleetResult struct {
+	BMCIP      string      `json:"bmc_ip"`
+	Passed     bool        `json:"passed"`
+	Detail     string      `json:"detail"`
+	Attempts   int         `json:"attempts"`
+	DurationMS int64       `json:"duration_ms"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+// FleetReport is the outcome of an operation across all targets, in target order
+type FleetReport struct {
+	Operation string        `json:"operation"`
+	Passed    int           `json:"passed"`
+	Failed    int           `json:"failed"`
+	Results   []FleetResult `json:"results"`
+}
+
+// RunFleet runs op against every target with at most Concurrency targets at once.
+// Each attempt is limited to Timeout and failed targets are retried Retries times.
+// Targets not started when ctx ends are reported as failed.
+func RunFleet(ctx context.Context, targets []FleetTarget, op FleetOperation, opts FleetOptions) *FleetReport {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultFleetConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultFleetTimeout
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = defaultFleetRetryDelay
+	}
+	if op.NoRetry {
+		opts.Retries = 0
+	}
+
+	report := &FleetReport{Operation: op.Name, Results: make([]FleetResult, len(targets))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, opts.Concurrency)
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target FleetTarget) {
+			defer wg.Done()
+			var result FleetResult
+			select {
+			case slots <- struct{}{}:
+				result = runFleetTarget(ctx, target, op, opts)
+				<-slots
+			case <-ctx.Done():
+				result = FleetResult{BMCIP: target.BMCIP, Detail: "not run: " + ctx.Err().Error()}
+			}
+
+			mu.Lock()
+			report.Results[i] = result
+			if result.Passed {
+				report.Passed++
+			} else {
+				report.Failed++
+			}
+			if opts.Progress != nil {
+				opts.Progress(result)
+			}
+			mu.Unlock()
+		}(i, target)
+	}
+	wg.Wait()
+	return report
+}
+
+// runFleetTarget runs op against one target with retries
+func runFleetTarget(ctx context.Context, target FleetTarget, op FleetOperation, opts FleetOptions) FleetResult {
+	result := FleetResult{BMCIP: target.BMCIP}
+	start := time.Now()
+	for {
+		result.Attempts++
+		detail, data, err := runFleetAttempt(ctx, target, op, opts.Timeout)
+		result.Passed, result.Detail, result.Data = err == nil, detail, data
+		if err != nil {
+			result.Detail = err.Error()
+		}
+		if err == nil || result.Attempts > opts.Retries || sleepContext(ctx, opts.RetryDelay) != nil {
+			break
+		}
+	}
+	result.DurationMS = time.Since(start).Milliseconds()
+	return result
+}
+
+// runFleetAttempt runs op once, abandoning it if it outlives the timeout
+func runFleetAttempt(ctx context.Context, target FleetTarget, op FleetOperation, timeout time.Duration) (string, interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		detail string
+		data   interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		detail, data, err := op.Run(ctx, target)
+		done <- outcome{detail, data, err}
+	}()
+	select {
+	case o := <-done:
+		return o.detail, o.data, o.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", nil, fmt.Errorf("timed out after %s", timeout)
+		}
+		return "", nil, ctx.Err()
+	}
+}
+
+// WriteText writes the results as a table followed by a summary line
+func (r *FleetReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BMC\tRESULT\tATTEMPTS\tTIME\tDETAIL")
+	for _, res := range r.Results {
+		status := "PASS"
+		if !res.Passed {
+			status = "FAIL"
+		}
+		elapsed := (time.Duration(res.DurationMS) * time.Millisecond).Round(10 * time.Millisecond)
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", res.BMCIP, status, res.Attempts, elapsed, res.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s: %d passed, %d failed\n", r.Operation, r.Passed, r.Failed)
+	return err
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *FleetReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// RPPortOperation reads each BMC's remote presence port. Unlike GetRPPort it reports
+// failures instead of assuming the default port.
+func RPPortOperation() FleetOperation {
+	return FleetOperation{Name: "rp-port", Run: func(ctx context.Context, t FleetTarget) (string, interface{}, error) {
+		dialer := dialerFor(t.Proxy)
+		provider := providerFor(t.Provider, dialer)
+		port, err := provider.RPPort(t.BMCIP, t.Username, t.Password)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("port %d", port), port, nil
+	}}
+}
+
+// CheckOperation runs Check against each BMC, with timeout for each check step
+func CheckOperation(timeout time.Duration) FleetOperation {
+	return FleetOperation{Name: "check", Run: func(ctx context.Context, t FleetTarget) (string, interface{}, error) {
+		report := Check(t.config(ConsoleConfig{}), timeout)
+		var failed []string
+		for _, res := range report.Results {
+			if !res.Passed && !res.Skipped {
+				failed = append(failed, res.Name+": "+res.Detail)
+			}
+		}
+		if !report.Passed {
+			return "", report, fmt.Errorf("%s", strings.Join(failed, "; "))
+		}
+		return fmt.Sprintf("all checks passed, RP port %d", report.RPPort), report, nil
+	}}
+}
+
+// PowerOperation performs a power action on each server, or reads the power state
+// when action is empty. Power actions are never retried.
+func PowerOperation(action PowerAction) FleetOperation {
+	if action == "" {
+		return FleetOperation{Name: "power-state", Run: func(ctx context.Context, t FleetTarget) (string, interface{}, error) {
+			state, err := NewConsole(t.config(ConsoleConfig{})).PowerState()
+			return state, state, err
+		}}
+	}
+	return FleetOperation{Name: "power " + string(action), NoRetry: true, Run: func(ctx context.Context, t FleetTarget) (string, interface{}, error) {
+		if err := NewConsole(t.config(ConsoleConfig{})).Power(action); err != nil {
+			return "", nil, err
+		}
+		return "requested " + string(action), nil, nil
+	}}
+}
+
+// InfoOperation reads each server's identity, power state and health from Redfish
+func InfoOperation() FleetOperation {
+	return FleetOperation{Name: "info", Run: func(ctx context.Context, t FleetTarget) (string, interface{}, error) {
+		info, err := getBMCInfo(dialerFor(t.Proxy), t.BMCIP, t.Username, t.Password)
+		if err != nil {
+			return "", nil, err
+		}
+		return info.Summary(), info, nil
+	}}
+}
+
+// ScreenshotOperation opens a console for each BMC in the browser configured in base,
+// waits for the viewer to log in and saves a screenshot as <dir>/<BMC>.png. A headless
+// browser can be used through BrowserConfig.Command.
+func ScreenshotOperation(base ConsoleConfig, dir string) FleetOperation {
+	return FleetOperation{Name: "screenshot", Run: func(ctx context.Context, t FleetTarget) (string, interface{}, error) {
+		config := t.config(base)
+		if config.RPPort == 0 {
+			port, err := providerFor(config.Provider, dialerFor(config.Proxy)).RPPort(config.BMCIP, config.Username, config.Password)
+			if err != nil {
+				return "", nil, err
+			}
+			config.RPPort = port
+		}
+		console := NewConsole(config)
+		defer console.Stop()
+		events, cancel := console.Subscribe()
+		defer cancel()
+		if err := console.LaunchAndOpen(); err != nil {
+			return "", nil, err
+		}
+
+		// Wait for a successful login so the screen has been drawn
+		for login := false; !login; {
+			select {
+			case e := <-events:
+				if e.Type == EventLoginResult {
+					if e.Code != 0 {
+						return "", nil, fmt.Errorf("login failed: %s", e.Message)
+					}
+					login = true
+				}
+			case <-ctx.Done():
+				return "", nil, fmt.Errorf("viewer did not log in: %v", ctx.Err())
+			}
+		}
+		if err := sleepContext(ctx, screenshotSettle); err != nil {
+			return "", nil, err
+		}
+
+		png, err := console.Screenshot(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+		path := filepath.Join(dir, unsafeFileChars.ReplaceAllString(config.BMCIP, "_")+".png")
+		if err := os.WriteFile(path, png, 0644); err != nil {
+			return "", nil, err
+		}
+		return path, path, nil
+	}}
+}
+
+// screenshotSettle gives the viewer time to draw the first frames after login
+const screenshotSettle = 3 * time.Second
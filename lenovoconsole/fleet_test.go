@@ -0,0 +1,167 @@
+package lenovoconsole
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+func TestParseTargets(t *testing.T) {
+	input := `# rack 12
+10.0.0.1
+10.0.0.2 admin
+10.0.0.3, admin, s3cret, imm2
+[FD00:0::7]:443
+
+`
+	targets, err := ParseTargets(strings.NewReader(input), FleetTarget{Username: "USERID", Password: "PASSW0RD"})
+	if err != nil {
+		t.Fatalf("ParseTargets: %v", err)
+	}
+	if len(targets) != 4 || targets[3].BMCIP != "fd00::7" {
+		t.Fatalf("targets = %+v", targets)
+	}
+	if targets[0].Username != "USERID" || targets[1].Username != "admin" || targets[1].Password != "PASSW0RD" {
+		t.Errorf("defaults not applied: %+v", targets)
+	}
+	if targets[2].Password != "s3cret" || targets[2].Provider == nil || targets[2].Provider.Name() != ProviderIMM2 {
+		t.Errorf("explicit fields: %+v", targets[2])
+	}
+
+	if _, err := ParseTargets(strings.NewReader("10.0.0.1 u p vax"), FleetTarget{}); err == nil {
+		t.Error("unknown provider accepted")
+	}
+	if _, err := ParseTargets(strings.NewReader("10.0.0.1:https"), FleetTarget{}); err == nil {
+		t.Error("invalid address accepted")
+	}
+}
+
+func TestRunFleet(t *testing.T) {
+	var targets []FleetTarget
+	for i := 1; i <= 12; i++ {
+		targets = append(targets, FleetTarget{BMCIP: fmt.Sprintf("10.0.0.%d", i)})
+	}
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	attempts := make(map[string]int)
+	op := FleetOperation{Name: "test", Run: func(ctx context.Context, target FleetTarget) (string, interface{}, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		attempts[target.BMCIP]++
+		n := attempts[target.BMCIP]
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+
+		switch target.BMCIP {
+		case "10.0.0.2": // flaky: succeeds on the second attempt
+			if n == 1 {
+				return "", nil, fmt.Errorf("connection reset")
+			}
+		case "10.0.0.3": // hangs until the per-host timeout
+			<-ctx.Done()
+			return "", nil, ctx.Err()
+		case "10.0.0.4":
+			return "", nil, fmt.Errorf("bad credentials")
+		}
+		time.Sleep(5 * time.Millisecond)
+		return "ok", n, nil
+	}}
+
+	var progress int
+	report := RunFleet(context.Background(), targets, op, FleetOptions{
+		Concurrency: 3,
+		Timeout:     50 * time.Millisecond,
+		Retries:     1,
+		RetryDelay:  time.Millisecond,
+		Progress:    func(FleetResult) { progress++ },
+	})
+	if maxRunning > 3 {
+		t.Errorf("%d targets ran at once, limit 3", maxRunning)
+	}
+	if report.Passed != 10 || report.Failed != 2 || progress != 12 {
+		t.Errorf("passed %d, failed %d, progress %d", report.Passed, report.Failed, progress)
+	}
+	for i, res := range report.Results {
+		if res.BMCIP != targets[i].BMCIP {
+			t.Fatalf("results out of order: %d is %s", i, res.BMCIP)
+		}
+	}
+	if r := report.Results[1]; !r.Passed || r.Attempts != 2 {
+		t.Errorf("flaky target: %+v", r)
+	}
+	if r := report.Results[2]; r.Passed || r.Attempts != 2 || !strings.Contains(r.Detail, "timed out") {
+		t.Errorf("hanging target: %+v", r)
+	}
+
+	failing := FleetOperation{Name: "test", NoRetry: true, Run: func(ctx context.Context, target FleetTarget) (string, interface{}, error) {
+		return "", nil, fmt.Errorf("unknown outcome")
+	}}
+	report = RunFleet(context.Background(), targets[1:2], failing, FleetOptions{Retries: 3})
+	if r := report.Results[0]; r.Passed || r.Attempts != 1 {
+		t.Errorf("NoRetry operation retried: %+v", r)
+	}
+
+	var text, js bytes.Buffer
+	report.WriteText(&text)
+	if !strings.Contains(text.String(), "10.0.0.2  FAIL") || !strings.Contains(text.String(), "test: 0 passed, 1 failed") {
+		t.Errorf("text report:\n%s", text.String())
+	}
+	report.WriteJSON(&js)
+	var decoded FleetReport
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || decoded.Failed != 1 {
+		t.Errorf("JSON report %s: %v", js.String(), err)
+	}
+}
+
+func TestFleetOperations(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{RPPort: 3901})
+	defer xcc.Close()
+	down := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	down.Close()
+
+	targets := []FleetTarget{
+		{BMCIP: xcc.Addr, Username: lenovoconsoletest.DefaultUsername, Password: lenovoconsoletest.DefaultPassword},
+		{BMCIP: down.Addr, Username: lenovoconsoletest.DefaultUsername, Password: lenovoconsoletest.DefaultPassword},
+	}
+	opts := FleetOptions{Timeout: 5 * time.Second}
+
+	report := RunFleet(context.Background(), targets, RPPortOperation(), opts)
+	if r := report.Results[0]; !r.Passed || r.Data != 3901 {
+		t.Errorf("rp-port: %+v", r)
+	}
+	if r := report.Results[1]; r.Passed {
+		t.Errorf("rp-port of an unreachable BMC: %+v", r)
+	}
+
+	report = RunFleet(context.Background(), targets, InfoOperation(), opts)
+	if r := report.Results[0]; !r.Passed || !strings.Contains(r.Detail, "S/N J900TEST") {
+		t.Errorf("info: %+v", r)
+	}
+	if r := report.Results[1]; r.Passed {
+		t.Errorf("info of an unreachable BMC: %+v", r)
+	}
+
+	report = RunFleet(context.Background(), targets[:1], PowerOperation(PowerOff), opts)
+	if !report.Results[0].Passed || xcc.PowerState() != "Off" {
+		t.Errorf("power off: %+v", report.Results[0])
+	}
+	report = RunFleet(context.Background(), targets[:1], PowerOperation(""), opts)
+	if r := report.Results[0]; !r.Passed || r.Detail != "Off" {
+		t.Errorf("power state: %+v", r)
+	}
+}
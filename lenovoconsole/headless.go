@@ -0,0 +1,122 @@
+package lenovoconsole
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// HasDisplay reports whether a graphical browser can be opened on this machine.
+// On Linux and the BSDs this requires DISPLAY or WAYLAND_DISPLAY to be set.
+func HasDisplay() bool {
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return true
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	default:
+		return false
+	}
+}
+
+// Headless reports whether LaunchAndOpen will print access details instead of opening a browser
+func (c *Console) Headless() bool {
+	return c.config.NoBrowser || !HasDisplay()
+}
+
+// lanURLWarning explains the exposure of a console URL reachable from the network
+const lanURLWarning = "Warning: this URL is plain HTTP and the page contains the BMC password. " +
+	"Anyone on the network who opens it can read the password and use the console; prefer the SSH forward."
+
+// PrintAccessInfo writes the console URL and an SSH port forwarding hint. With
+// ShowLANURL it also writes the URL on this machine's network address, with a warning,
+// and with ShowQRCode a QR code of it rendered for the terminal.
+func (c *Console) PrintAccessInfo(w io.Writer) {
+	fmt.Fprintf(w, "Console URL: %s\n", c.GetURL())
+
+	host := sshServerHost()
+	user := os.Getenv("USER")
+	if user == "" {
+		user = os.Getenv("USERNAME")
+	}
+	target := host
+	if user != "" {
+		target = user + "@" + host
+	}
+
+	fmt.Fprintln(w, "\nTo reach it from your workstation, forward the port over SSH:")
+	fmt.Fprintf(w, "  ssh -L %d:localhost:%d %s\n", c.serverPort, c.serverPort, target)
+	fmt.Fprintf(w, "then open http://localhost:%d in your browser.\n", c.serverPort)
+	fmt.Fprintf(w, "The browser must also be able to reach the BMC at %s (HTTPS and port %d).\n",
+		c.config.BMCIP, c.rpPort())
+
+	if !c.config.ShowLANURL {
+		if c.config.ShowQRCode {
+			fmt.Fprintln(w, "\nThe QR code encodes the URL on the network, which is only shown with ShowLANURL (-lan-url).")
+		}
+		return
+	}
+	lanURL := fmt.Sprintf("http://%s:%d", host, c.serverPort)
+	fmt.Fprintf(w, "\nOn the network: %s\n%s\n", lanURL, lanURLWarning)
+	if c.config.ShowQRCode {
+		if err := writeQRCode(w, lanURL); err != nil {
+			fmt.Fprintf(w, "Could not render QR code: %v\n", err)
+		}
+	}
+}
+
+// sshServerHost returns the address this machine was reached on over SSH, or its hostname
+func sshServerHost() string {
+	// SSH_CONNECTION is "client_ip client_port server_ip server_port"
+	if fields := strings.Fields(os.Getenv("SSH_CONNECTION")); len(fields) == 4 {
+		if strings.Contains(fields[2], ":") {
+			return "[" + fields[2] + "]"
+		}
+		return fields[2]
+	}
+	if name, err := os.Hostname(); err == nil {
+		return name
+	}
+	return "localhost"
+}
+
+// writeQRCode renders text as a QR code using Unicode half blocks, two modules per character row
+func writeQRCode(w io.Writer, text string) error {
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		return err
+	}
+
+	const quiet = 2
+	dark := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= code.Size || y >= code.Size {
+			return false
+		}
+		return code.Black(x, y)
+	}
+
+	var b strings.Builder
+	for y := -quiet; y < code.Size+quiet; y += 2 {
+		for x := -quiet; x < code.Size+quiet; x++ {
+			// Light-on-dark terminals are the norm, so dark modules are drawn as spaces
+			top, bottom := dark(x, y), dark(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString(" ")
+			case top:
+				b.WriteString("▄")
+			case bottom:
+				b.WriteString("▀")
+			default:
+				b.WriteString("█")
+			}
+		}
+		b.WriteString("\n")
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
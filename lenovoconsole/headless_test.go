@@ -0,0 +1,107 @@
+package lenovoconsole
+
+import (
+	"strings"
+	"testing"
+
+	"rsc.io/qr"
+)
+
+func TestPrintAccessInfo(t *testing.T) {
+	t.Setenv("SSH_CONNECTION", "192.0.2.50 50000 192.0.2.10 22")
+	t.Setenv("USER", "ops")
+
+	tests := []struct {
+		name      string
+		lan, qr   bool
+		want      []string
+		forbidden []string
+	}{
+		{"default", false, false,
+			[]string{"Console URL: http://localhost:8123", "ssh -L 8123:localhost:8123 ops@192.0.2.10"},
+			[]string{"192.0.2.10:8123", "password", "█"}},
+		{"QR code without the LAN URL", false, true,
+			[]string{"only shown with ShowLANURL"},
+			[]string{"http://192.0.2.10:8123", "█"}},
+		{"LAN URL", true, false,
+			[]string{"On the network: http://192.0.2.10:8123", lanURLWarning},
+			[]string{"█"}},
+		{"LAN URL and QR code", true, true,
+			[]string{"On the network: http://192.0.2.10:8123", lanURLWarning, "█"},
+			nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", RPPort: 3900, ShowLANURL: tt.lan, ShowQRCode: tt.qr})
+			c.serverPort = 8123
+			var b strings.Builder
+			c.PrintAccessInfo(&b)
+			out := b.String()
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output lacks %q:\n%s", want, out)
+				}
+			}
+			for _, forbidden := range tt.forbidden {
+				if strings.Contains(out, forbidden) {
+					t.Errorf("output contains %q:\n%s", forbidden, out)
+				}
+			}
+		})
+	}
+}
+
+func TestSSHServerHost(t *testing.T) {
+	t.Setenv("SSH_CONNECTION", "2001:db8::50 50000 2001:db8::10 22")
+	if got := sshServerHost(); got != "[2001:db8::10]" {
+		t.Errorf("sshServerHost = %q for an IPv6 connection", got)
+	}
+	t.Setenv("SSH_CONNECTION", "")
+	if got := sshServerHost(); got == "" {
+		t.Error("sshServerHost is empty without SSH_CONNECTION")
+	}
+}
+
+func TestWriteQRCode(t *testing.T) {
+	const text = "http://192.0.2.10:8123"
+	var b strings.Builder
+	if err := writeQRCode(&b, text); err != nil {
+		t.Fatal(err)
+	}
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each character holds two modules; dark modules are drawn light for dark terminals
+	const quiet = 2
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if want := (code.Size + 2*quiet + 1) / 2; len(lines) != want {
+		t.Fatalf("QR code has %d lines, want %d", len(lines), want)
+	}
+	for row, line := range lines {
+		cells := []rune(line)
+		if len(cells) != code.Size+2*quiet {
+			t.Fatalf("line %d has %d characters, want %d", row, len(cells), code.Size+2*quiet)
+		}
+		for i, cell := range cells {
+			x, y := i-quiet, 2*row-quiet
+			var top, bottom bool
+			switch cell {
+			case ' ':
+				top, bottom = true, true
+			case '▄':
+				top = true
+			case '▀':
+				bottom = true
+			case '█':
+			default:
+				t.Fatalf("unexpected character %q", cell)
+			}
+			if top != (x >= 0 && y >= 0 && x < code.Size && y < code.Size && code.Black(x, y)) ||
+				bottom != (x >= 0 && y+1 >= 0 && x < code.Size && y+1 < code.Size && code.Black(x, y+1)) {
+				t.Fatalf("module (%d, %d) drawn as %q", x, y, cell)
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package lenovoconsole
+
+// imm2DefaultRPPort is the remote presence port of an IMM2 unless reconfigured
+const imm2DefaultRPPort = 3900
+
+// imm2Provider supports the Integrated Management Module II of System x servers.
+// The IMM2 web interface lives under /designs/imm and serves the same RPViewer SDK as
+// the XCC. Power actions use Redfish, which IMM2 firmware provides from version 4.x.
+type imm2Provider struct {
+	dialer Dialer // nil connects directly
+}
+
+func (p imm2Provider) withDialer(d Dialer) Provider {
+	p.dialer = d
+	return p
+}
+
+func (imm2Provider) Name() string  { return ProviderIMM2 }
+func (imm2Provider) Title() string { return "Lenovo IMM2" }
+
+// RPPort returns imm2DefaultRPPort without contacting the BMC: IMM2 firmware has no
+// API that reports the remote presence port. For an IMM2 whose port was changed, set
+// ConsoleConfig.RPPort, or rp_port in a registry entry.
+func (imm2Provider) RPPort(bmcIP, username, password string) (int, error) {
+	return imm2DefaultRPPort, nil
+}
+
+func (imm2Provider) SDKScripts() []string {
+	return sdkScripts("/designs/imm/SDK_Pilot4/")
+}
+
+func (imm2Provider) ViewerScript() string {
+	return rpViewerScript + `
+        // Some IMM2 firmware serves the SDK from the root instead of /designs/imm
+        function sdkScriptFallback(path) {
+            return path.replace('/designs/imm', '');
+        }
+`
+}
+
+func (imm2Provider) ProxyPaths() []string {
+	return append([]string{"/designs/imm/"}, viewerWorkers...)
+}
+
+func (p imm2Provider) Power(bmcIP, username, password string, action PowerAction) error {
+	return redfishPower(p.dialer, bmcIP, username, password, action)
+}
+
+func (p imm2Provider) PowerState(bmcIP, username, password string) (string, error) {
+	return redfishPowerState(p.dialer, bmcIP, username, password)
+}
@@ -0,0 +1,272 @@
+package lenovoconsole
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Keystroke is one press of a physical key, identified the way browsers report it.
+// The remote host maps the key to a character through its own keyboard layout.
+type Keystroke struct {
+	Code    string `json:"code"`            // KeyboardEvent.code of the physical key, e.g. "KeyA"
+	Key     string `json:"key"`             // Character the key produces on the remote layout
+	KeyCode int    `json:"keyCode"`         // Legacy KeyboardEvent.keyCode of the physical key
+	Shift   bool   `json:"shift,omitempty"` // Hold Shift
+	AltGr   bool   `json:"altGr,omitempty"` // Hold AltGr (right Alt)
+	Ctrl    bool   `json:"ctrl,omitempty"`  // Hold Ctrl
+	Alt     bool   `json:"alt,omitempty"`   // Hold Alt (left Alt)
+}
+
+// keyCodes maps the physical keys used by the layouts to their legacy key codes
+var keyCodes = map[string]int{
+	"Backquote": 192, "Minus": 189, "Equal": 187,
+	"BracketLeft": 219, "BracketRight": 221, "Backslash": 220,
+	"Semicolon": 186, "Quote": 222, "Comma": 188, "Period": 190, "Slash": 191,
+	"IntlBackslash": 226, "Space": 32, "Enter": 13, "Tab": 9,
+	"Escape": 27, "Backspace": 8, "Insert": 45, "Delete": 46, "Home": 36, "End": 35,
+	"PageUp": 33, "PageDown": 34, "ArrowLeft": 37, "ArrowUp": 38, "ArrowRight": 39, "ArrowDown": 40,
+}
+
+// namedKeys are the keys KeySequence accepts in braces, by lower case name
+var namedKeys = map[string]string{
+	"enter": "Enter", "return": "Enter", "tab": "Tab", "space": "Space",
+	"esc": "Escape", "escape": "Escape", "backspace": "Backspace", "bs": "Backspace",
+	"insert": "Insert", "ins": "Insert", "delete": "Delete", "del": "Delete",
+	"home": "Home", "end": "End", "pageup": "PageUp", "pgup": "PageUp", "pagedown": "PageDown", "pgdn": "PageDown",
+	"left": "ArrowLeft", "up": "ArrowUp", "right": "ArrowRight", "down": "ArrowDown",
+}
+
+func init() {
+	for i := 0; i < 10; i++ {
+		keyCodes[fmt.Sprintf("Digit%d", i)] = 48 + i
+	}
+	for r := 'A'; r <= 'Z'; r++ {
+		keyCodes["Key"+string(r)] = int(r)
+	}
+	for i := 1; i <= 12; i++ {
+		name := fmt.Sprintf("F%d", i)
+		keyCodes[name] = 111 + i
+		namedKeys[strings.ToLower(name)] = name
+	}
+}
+
+// keyLayout describes which character each physical key produces on a layout: plain,
+// with Shift and with AltGr, in that order. "\x00" marks an unused position.
+type keyLayout struct {
+	keys map[string]string
+	dead string // characters produced by dead keys, typed as the key followed by Space
+}
+
+// keyLayouts are the keyboard languages Paste can type in, by ViewerOptions.KeyboardLanguage
+var keyLayouts = map[string]keyLayout{
+	"en": {keys: withLetters(map[string]string{
+		"Backquote": "`~", "Digit1": "1!", "Digit2": "2@", "Digit3": "3#", "Digit4": "4$",
+		"Digit5": "5%", "Digit6": "6^", "Digit7": "7&", "Digit8": "8*", "Digit9": "9(",
+		"Digit0": "0)", "Minus": "-_", "Equal": "=+", "BracketLeft": "[{", "BracketRight": "]}",
+		"Backslash": `\|`, "Semicolon": ";:", "Quote": `'"`, "Comma": ",<", "Period": ".>",
+		"Slash": "/?",
+	})},
+	"de": {keys: withLetters(map[string]string{
+		"Backquote": "^°", "Digit1": "1!", "Digit2": "2\"²", "Digit3": "3§³", "Digit4": "4$",
+		"Digit5": "5%", "Digit6": "6&", "Digit7": "7/{", "Digit8": "8([", "Digit9": "9)]",
+		"Digit0": "0=}", "Minus": `ß?\`, "Equal": "´`", "BracketLeft": "üÜ", "BracketRight": "+*~",
+		"Backslash": "#'", "Semicolon": "öÖ", "Quote": "äÄ", "Comma": ",;", "Period": ".:",
+		"Slash": "-_", "IntlBackslash": "<>|",
+		"KeyQ": "qQ@", "KeyE": "eE€", "KeyM": "mMµ", "KeyY": "zZ", "KeyZ": "yY",
+	}), dead: "^´`"},
+	"fr": {keys: withLetters(map[string]string{
+		"Backquote": "²", "Digit1": "&1", "Digit2": "é2~", "Digit3": "\"3#", "Digit4": "'4{",
+		"Digit5": "(5[", "Digit6": "-6|", "Digit7": "è7`", "Digit8": `_8\`, "Digit9": "ç9^",
+		"Digit0": "à0@", "Minus": ")°]", "Equal": "=+}", "BracketLeft": "^¨", "BracketRight": "$£¤",
+		"Backslash": "*µ", "Semicolon": "mM", "Quote": "ù%", "Comma": ";.", "Period": ":/",
+		"Slash": "!§", "IntlBackslash": "<>",
+		"KeyQ": "aA", "KeyW": "zZ", "KeyE": "eE€", "KeyA": "qQ", "KeyZ": "wW", "KeyM": ",?",
+	}), dead: "~`^¨"},
+}
+
+// withLetters adds the keys A to Z, producing their own letter, unless keys remaps them
+func withLetters(keys map[string]string) map[string]string {
+	for r := 'a'; r <= 'z'; r++ {
+		code := "Key" + strings.ToUpper(string(r))
+		if _, ok := keys[code]; !ok {
+			keys[code] = string(r) + strings.ToUpper(string(r))
+		}
+	}
+	return keys
+}
+
+// KeyboardLayouts lists the keyboard languages Paste can type in
+func KeyboardLayouts() []string {
+	names := make([]string, 0, len(keyLayouts))
+	for name := range keyLayouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Keystrokes translates text into key presses for a keyboard language such as "en" or
+// "de". Newlines become Enter and tabs Tab; other control characters and characters the
+// layout cannot type are reported in the error.
+func Keystrokes(text, language string) ([]Keystroke, error) {
+	layout, name, err := lookupLayout(language)
+	if err != nil {
+		return nil, err
+	}
+	chars := layout.index()
+
+	var keys []Keystroke
+	var missing []string
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, r := range text {
+		switch r {
+		case '\n', '\r':
+			keys = append(keys, Keystroke{Code: "Enter", Key: "Enter", KeyCode: keyCodes["Enter"]})
+			continue
+		case '\t':
+			keys = append(keys, Keystroke{Code: "Tab", Key: "Tab", KeyCode: keyCodes["Tab"]})
+			continue
+		case ' ':
+			keys = append(keys, space)
+			continue
+		}
+		k, ok := chars[r]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%q", r))
+			continue
+		}
+		keys = append(keys, k)
+		if strings.ContainsRune(layout.dead, r) {
+			// A dead key waits for the next key; Space makes it produce its own character
+			keys = append(keys, space)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("cannot type %s with the %s keyboard layout", strings.Join(missing, ", "), name)
+	}
+	return keys, nil
+}
+
+var space = Keystroke{Code: "Space", Key: " ", KeyCode: 32}
+
+// lookupLayout returns the layout for a keyboard language and its name
+func lookupLayout(language string) (keyLayout, string, error) {
+	name := strings.ToLower(language)
+	if i := strings.IndexAny(name, "-_"); i > 0 {
+		name = name[:i] // "en-US" types like "en"
+	}
+	layout, ok := keyLayouts[name]
+	if !ok {
+		return layout, name, fmt.Errorf("no keyboard layout for language %q (available: %s)",
+			language, strings.Join(KeyboardLayouts(), ", "))
+	}
+	return layout, name, nil
+}
+
+// KeySequence translates a key sequence into key presses for a keyboard language. Text is
+// typed as by Keystrokes; keys are named in braces, optionally with modifiers:
+// "{F1}", "{Down}", "{Esc}", "{Ctrl+Alt+Delete}", "{Shift+Tab}". "{{" types a brace.
+func KeySequence(sequence, language string) ([]Keystroke, error) {
+	var keys []Keystroke
+	var text strings.Builder
+	flush := func() error {
+		if text.Len() == 0 {
+			return nil
+		}
+		typed, err := Keystrokes(text.String(), language)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, typed...)
+		text.Reset()
+		return nil
+	}
+
+	for sequence != "" {
+		i := strings.Index(sequence, "{")
+		if i < 0 {
+			text.WriteString(sequence)
+			break
+		}
+		text.WriteString(sequence[:i])
+		sequence = sequence[i:]
+		if strings.HasPrefix(sequence, "{{") {
+			text.WriteByte('{')
+			sequence = sequence[2:]
+			continue
+		}
+		end := strings.Index(sequence, "}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed key name in %q", sequence)
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		key, err := namedKeystroke(sequence[1:end], language)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		sequence = sequence[end+1:]
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// namedKeystroke translates a braced key name such as "F1" or "Ctrl+Alt+Delete". The key
+// is a name from namedKeys or a single character of the layout.
+func namedKeystroke(name, language string) (Keystroke, error) {
+	parts := strings.Split(name, "+")
+	base := parts[len(parts)-1]
+
+	var key Keystroke
+	if code, ok := namedKeys[strings.ToLower(base)]; ok {
+		key = Keystroke{Code: code, Key: code, KeyCode: keyCodes[code]}
+		if code == "Space" {
+			key = space
+		}
+	} else if r := []rune(strings.ToLower(base)); len(r) == 1 {
+		layout, _, err := lookupLayout(language)
+		if err != nil {
+			return key, err
+		}
+		if key, ok = layout.index()[r[0]]; !ok {
+			return key, fmt.Errorf("cannot type %q in {%s}", r[0], name)
+		}
+	} else {
+		return key, fmt.Errorf("unknown key {%s}", name)
+	}
+
+	for _, modifier := range parts[:len(parts)-1] {
+		switch strings.ToLower(modifier) {
+		case "ctrl", "control":
+			key.Ctrl = true
+		case "alt":
+			key.Alt = true
+		case "shift":
+			key.Shift = true
+		case "altgr":
+			key.AltGr = true
+		default:
+			return key, fmt.Errorf("unknown modifier %q in {%s}", modifier, name)
+		}
+	}
+	return key, nil
+}
+
+// index maps every character of the layout to the key press that produces it
+func (l keyLayout) index() map[rune]Keystroke {
+	chars := make(map[rune]Keystroke)
+	for code, produced := range l.keys {
+		for i, r := range []rune(produced) {
+			if r == 0 {
+				continue
+			}
+			chars[r] = Keystroke{Code: code, Key: string(r), KeyCode: keyCodes[code], Shift: i == 1, AltGr: i == 2}
+		}
+	}
+	return chars
+}
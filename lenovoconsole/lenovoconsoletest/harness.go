@@ -0,0 +1,208 @@
+package lenovoconsoletest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// Engine identifies what RunPage uses to execute a console page
+type Engine string
+
+// Engines supported by RunPage
+const (
+	EngineChromium Engine = "chromium" // Headless Chromium or Chrome with a real DOM
+	EngineNode     Engine = "node"     // Node.js with a minimal DOM shim
+)
+
+// EngineEnv overrides engine selection, e.g. LENOVOCONSOLETEST_ENGINE=node
+const EngineEnv = "LENOVOCONSOLETEST_ENGINE"
+
+// ErrNoEngine is returned by FindEngine when neither Chromium nor Node.js is installed
+var ErrNoEngine = errors.New("lenovoconsoletest: no headless Chromium or Node.js found")
+
+// chromiumExecutables are the names Chromium-based browsers are installed under
+var chromiumExecutables = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome"}
+
+// FindEngine returns the engine RunPage will use and its executable, preferring Chromium
+func FindEngine() (Engine, string, error) {
+	want := Engine(os.Getenv(EngineEnv))
+	if want == "" || want == EngineChromium {
+		for _, name := range chromiumExecutables {
+			if path, err := exec.LookPath(name); err == nil {
+				return EngineChromium, path, nil
+			}
+		}
+	}
+	if want == "" || want == EngineNode {
+		if path, err := exec.LookPath("node"); err == nil {
+			return EngineNode, path, nil
+		}
+	}
+	return "", "", ErrNoEngine
+}
+
+// RunPage loads the console page at url in a headless engine and runs it until the stub
+// RPViewer has played its ViewerBehavior or ctx is done. Calls made on the stub are then
+// available from the fake's ViewerCalls.
+func RunPage(ctx context.Context, url string) (Engine, error) {
+	engine, path, err := FindEngine()
+	if err != nil {
+		return "", err
+	}
+
+	var cmd *exec.Cmd
+	switch engine {
+	case EngineChromium:
+		budget := 30 * time.Second
+		if deadline, ok := ctx.Deadline(); ok {
+			budget = time.Until(deadline)
+		}
+		cmd = exec.CommandContext(ctx, path,
+			"--headless=new",
+			"--no-sandbox",
+			"--disable-gpu",
+			"--ignore-certificate-errors",
+			"--virtual-time-budget="+strconv.FormatInt(budget.Milliseconds()/2, 10),
+			"--dump-dom",
+			url)
+	case EngineNode:
+		cmd = exec.CommandContext(ctx, path, "-e", nodeShim, url)
+		// The fake XCC uses a self-signed certificate
+		cmd.Env = append(os.Environ(), "NODE_TLS_REJECT_UNAUTHORIZED=0", "NODE_NO_WARNINGS=1")
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return engine, fmt.Errorf("%s: page did not finish: %v\n%s", engine, ctx.Err(), output.String())
+		}
+		return engine, fmt.Errorf("%s: %v\n%s", engine, err, output.String())
+	}
+	return engine, nil
+}
+
+// nodeShim runs a console page in Node.js. It provides just enough of the DOM for the
+// console page: elements with ids found in the HTML, script loading, timers and fetch
+// resolved against the page URL. It exits once the stub viewer is done and every
+// request has completed; uncaught errors in page code fail the run.
+const nodeShim = `
+'use strict';
+const vm = require('vm');
+const pageURL = process.argv[1];
+let pending = 0;
+
+function shimFetch(input, init) {
+    init = Object.assign({}, init);
+    delete init.cache;
+    delete init.mode;
+    pending++;
+    return fetch(new URL(input, pageURL).toString(), init).finally(() => { pending--; });
+}
+
+class Element {
+    constructor(tagName, id) {
+        this.tagName = tagName.toUpperCase();
+        this.id = id || '';
+        this.style = {};
+        this.children = [];
+        this.innerHTML = '';
+        this.textContent = '';
+        this.className = '';
+        this.offsetHeight = 0;
+    }
+    appendChild(child) {
+        this.children.push(child);
+        if (child.tagName === 'SCRIPT' && child.src) {
+            loadScript(child);
+        }
+        return child;
+    }
+    addEventListener() {}
+    removeEventListener() {}
+    requestFullscreen() {}
+}
+
+const elements = {};
+const document = {
+    head: new Element('head'),
+    body: new Element('body'),
+    documentElement: new Element('html'),
+    getElementById: id => elements[id] || null,
+    createElement: tagName => new Element(tagName),
+    addEventListener() {},
+    removeEventListener() {}
+};
+
+const window = {
+    document: document,
+    console: console,
+    fetch: shimFetch,
+    URL: URL,
+    URLSearchParams: URLSearchParams,
+    setTimeout: setTimeout,
+    clearTimeout: clearTimeout,
+    setInterval: (fn, ms) => { const t = setInterval(fn, ms); t.unref(); return t; },
+    clearInterval: clearInterval,
+    innerWidth: 1280,
+    innerHeight: 800,
+    location: {href: pageURL, search: new URL(pageURL).search, reload() { console.log('page reload requested'); }},
+    open() {},
+    addEventListener() {},
+    removeEventListener() {}
+};
+window.window = window;
+window.self = window;
+const context = vm.createContext(window);
+
+function loadScript(script) {
+    shimFetch(script.src)
+        .then(response => {
+            if (!response.ok) {
+                throw new Error('HTTP ' + response.status);
+            }
+            return response.text();
+        })
+        .then(source => {
+            vm.runInContext(source, context, {filename: script.src});
+            if (script.onload) {
+                script.onload();
+            }
+        }, error => {
+            console.log('Failed to load ' + script.src + ': ' + error);
+            if (script.onerror) {
+                script.onerror(error);
+            }
+        });
+}
+
+process.on('uncaughtException', error => {
+    console.error('Uncaught error in page:', error);
+    process.exit(1);
+});
+
+shimFetch(pageURL)
+    .then(response => response.text())
+    .then(html => {
+        for (const match of html.matchAll(/<(\w+)[^>]*\sid="([^"]+)"/g)) {
+            elements[match[2]] = new Element(match[1], match[2]);
+        }
+        for (const match of html.matchAll(/<script>([\s\S]*?)<\/script>/g)) {
+            vm.runInContext(match[1], context, {filename: pageURL});
+        }
+    });
+
+const poll = setInterval(() => {
+    if (window.lenovoconsoletestDone && pending === 0) {
+        clearInterval(poll);
+        process.exit(0);
+    }
+}, 20);
+`
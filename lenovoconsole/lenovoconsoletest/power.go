@@ -0,0 +1,51 @@
+package lenovoconsoletest
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// PowerState returns the server's current Redfish PowerState
+func (s *Server) PowerState() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.powerState
+}
+
+// Resets returns the ResetType of every ComputerSystem.Reset action received, in order
+func (s *Server) Resets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.resets...)
+}
+
+// resetHandler applies a ComputerSystem.Reset action to the simulated power state
+func (s *Server) resetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		ResetType string `json:"ResetType"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch body.ResetType {
+	case "On", "ForceOn":
+		s.powerState = "On"
+	case "ForceOff", "GracefulShutdown":
+		s.powerState = "Off"
+	case "GracefulRestart", "ForceRestart", "PowerCycle", "Nmi":
+		s.powerState = "On"
+	default:
+		http.Error(w, "Unsupported ResetType", http.StatusBadRequest)
+		return
+	}
+	s.resets = append(s.resets, body.ResetType)
+	w.WriteHeader(http.StatusNoContent)
+}
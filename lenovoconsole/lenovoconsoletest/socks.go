@@ -0,0 +1,216 @@
+package lenovoconsoletest
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// SOCKSProxy is a SOCKS5 proxy standing in for the bastion in front of a BMC network.
+// It records every address it is asked to connect to and resolves the host names in
+// Hosts itself, so that tests can use BMC names that only exist behind the proxy.
+type SOCKSProxy struct {
+	Addr string // host:port the proxy listens on
+	URL  string // socks5:// URL of the proxy, with credentials if it requires them
+
+	// Hosts maps host names to the addresses the proxy connects to instead. Set it
+	// before the first connection.
+	Hosts map[string]string
+
+	username string
+	password string
+	listener net.Listener
+
+	mu      sync.Mutex
+	targets []string
+	conns   map[net.Conn]struct{}
+}
+
+// NewSOCKSProxy starts a SOCKS5 proxy on a loopback port. With a username it requires
+// username and password authentication; otherwise it accepts anyone.
+func NewSOCKSProxy(username, password string) *SOCKSProxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("lenovoconsoletest: failed to listen: " + err.Error())
+	}
+	p := &SOCKSProxy{
+		Addr:     l.Addr().String(),
+		Hosts:    map[string]string{},
+		username: username,
+		password: password,
+		listener: l,
+		conns:    map[net.Conn]struct{}{},
+	}
+	u := url.URL{Scheme: "socks5", Host: p.Addr}
+	if username != "" {
+		u.User = url.UserPassword(username, password)
+	}
+	p.URL = u.String()
+	go p.serve()
+	return p
+}
+
+// Close stops the proxy and drops the connections it is relaying
+func (p *SOCKSProxy) Close() {
+	p.listener.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for conn := range p.conns {
+		conn.Close()
+	}
+}
+
+// Targets returns the host:port addresses clients asked to connect to, in order
+func (p *SOCKSProxy) Targets() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+// Connected reports whether a client asked the proxy to connect to address
+func (p *SOCKSProxy) Connected(address string) bool {
+	for _, t := range p.Targets() {
+		if t == address {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *SOCKSProxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go p.handle(conn)
+	}
+}
+
+// track adds or removes a connection closed by Close
+func (p *SOCKSProxy) track(conn net.Conn, add bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if add {
+		p.conns[conn] = struct{}{}
+	} else {
+		delete(p.conns, conn)
+	}
+}
+
+// handle serves one client: method negotiation, authentication and a CONNECT request
+func (p *SOCKSProxy) handle(conn net.Conn) {
+	p.track(conn, true)
+	defer p.track(conn, false)
+	defer conn.Close()
+
+	var head [2]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil || head[0] != 5 {
+		return
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	want := byte(0x00)
+	if p.username != "" {
+		want = 0x02
+	}
+	offered := false
+	for _, m := range methods {
+		offered = offered || m == want
+	}
+	if !offered {
+		conn.Write([]byte{5, 0xFF})
+		return
+	}
+	conn.Write([]byte{5, want})
+	if want == 0x02 && !p.authenticate(conn) {
+		return
+	}
+
+	var req [4]byte
+	if _, err := io.ReadFull(conn, req[:]); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1, 4:
+		ip := make(net.IP, map[byte]int{1: net.IPv4len, 4: net.IPv6len}[req[3]])
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = ip.String()
+	case 3:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		conn.Write([]byte{5, 8, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(conn, port[:]); err != nil {
+		return
+	}
+	portNum := strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))
+	target := net.JoinHostPort(host, portNum)
+	p.mu.Lock()
+	p.targets = append(p.targets, target)
+	p.mu.Unlock()
+
+	if req[1] != 1 {
+		conn.Write([]byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	if mapped, ok := p.Hosts[host]; ok {
+		host = mapped
+	}
+	upstream, err := net.Dial("tcp", net.JoinHostPort(host, portNum))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	p.track(upstream, true)
+	defer p.track(upstream, false)
+	defer upstream.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(upstream, conn); done <- struct{}{} }()
+	go func() { io.Copy(conn, upstream); done <- struct{}{} }()
+	<-done
+}
+
+// authenticate checks a username and password sub-negotiation (RFC 1929)
+func (p *SOCKSProxy) authenticate(conn net.Conn) bool {
+	field := func() string {
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return ""
+		}
+		b := make([]byte, n[0])
+		io.ReadFull(conn, b)
+		return string(b)
+	}
+	var version [1]byte
+	if _, err := io.ReadFull(conn, version[:]); err != nil || version[0] != 1 {
+		return false
+	}
+	username, password := field(), field()
+	if username != p.username || password != p.password {
+		conn.Write([]byte{1, 1})
+		return false
+	}
+	conn.Write([]byte{1, 0})
+	return true
+}
@@ -0,0 +1,83 @@
+package lenovoconsoletest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"time"
+)
+
+// TLSQuirk selects how the fake XCC presents itself over TLS
+type TLSQuirk int
+
+// TLS behaviours of a fake XCC
+const (
+	TLSSelfSigned TLSQuirk = iota // Self-signed certificate for 127.0.0.1 and localhost, like a factory XCC
+	TLSExpired                    // Self-signed certificate that expired a day ago
+	TLSWrongHost                  // Certificate issued for another host name
+	TLSLegacy                     // Only TLS 1.0 and 1.1, which current clients refuse
+	TLSPlainHTTP                  // No TLS at all
+)
+
+// config returns the server TLS configuration for the quirk
+func (q TLSQuirk) config() *tls.Config {
+	now := time.Now()
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "XCC-7X06-TEST", Organization: []string{"Lenovo"}},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	config := &tls.Config{}
+	switch q {
+	case TLSExpired:
+		template.NotBefore = now.Add(-48 * time.Hour)
+		template.NotAfter = now.Add(-24 * time.Hour)
+	case TLSWrongHost:
+		template.DNSNames = []string{"xcc.example.invalid"}
+		template.IPAddresses = nil
+	case TLSLegacy:
+		config.MinVersion = tls.VersionTLS10
+		config.MaxVersion = tls.VersionTLS11
+	}
+
+	config.Certificates = []tls.Certificate{selfSigned(template)}
+	return config
+}
+
+// selfSigned creates a certificate from template signed by its own fresh key
+func selfSigned(template *x509.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic("lenovoconsoletest: " + err.Error())
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		panic("lenovoconsoletest: " + err.Error())
+	}
+	template.SerialNumber = serial
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic("lenovoconsoletest: " + err.Error())
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// Certificate returns the certificate presented by the fake, or nil for TLSPlainHTTP
+func (s *Server) Certificate() *x509.Certificate {
+	if s.server.TLS == nil || len(s.server.TLS.Certificates) == 0 {
+		return nil
+	}
+	return s.server.TLS.Certificates[0].Leaf
+}
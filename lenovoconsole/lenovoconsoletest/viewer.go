@@ -0,0 +1,188 @@
+package lenovoconsoletest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ViewerBehavior scripts how the stub RPViewer responds once the page calls connectRPViewer
+type ViewerBehavior struct {
+	LoginResult     int           // Result passed to the login callback (0 for success)
+	LoginDelay      time.Duration // Delay before the login callback
+	Terminate       bool          // Terminate the session after a successful login
+	TerminateReason int           // Reason passed to the session termination callback
+	TerminateAfter  time.Duration // Delay between login and termination
+}
+
+// ViewerCall is a method call made by the console page on the stub RPViewer
+type ViewerCall struct {
+	Method string            `json:"method"`
+	Args   []json.RawMessage `json:"args"`
+}
+
+// StringArg returns argument i as a string, or "" if it is not one
+func (c ViewerCall) StringArg(i int) string {
+	var s string
+	if i < len(c.Args) {
+		json.Unmarshal(c.Args[i], &s)
+	}
+	return s
+}
+
+// IntArg returns argument i as an int, or 0 if it is not a number
+func (c ViewerCall) IntArg(i int) int {
+	var n int
+	if i < len(c.Args) {
+		json.Unmarshal(c.Args[i], &n)
+	}
+	return n
+}
+
+// viewerDoneMethod is recorded when the stub has played its scripted behaviour
+const viewerDoneMethod = "done"
+
+// ViewerCalls returns the calls the console page made on the stub RPViewer, in order
+func (s *Server) ViewerCalls() []ViewerCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ViewerCall(nil), s.viewerCalls...)
+}
+
+// ViewerCall returns the last call of method, if any
+func (s *Server) ViewerCall(method string) (ViewerCall, bool) {
+	calls := s.ViewerCalls()
+	for i := len(calls) - 1; i >= 0; i-- {
+		if calls[i].Method == method {
+			return calls[i], true
+		}
+	}
+	return ViewerCall{}, false
+}
+
+// ViewerDone reports whether the stub RPViewer finished its scripted behaviour
+func (s *Server) ViewerDone() bool {
+	_, ok := s.ViewerCall(viewerDoneMethod)
+	return ok
+}
+
+// viewerCallsHandler receives calls reported by the stub RPViewer
+func (s *Server) viewerCallsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var call ViewerCall
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&call); err != nil {
+		http.Error(w, "Invalid call", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.viewerCalls = append(s.viewerCalls, call)
+	s.mu.Unlock()
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// viewerStub renders the stub rpviewer.js for a request, reporting calls back to the host it was loaded from
+func (s *Server) viewerStub(r *http.Request) string {
+	scheme := "https"
+	if r.TLS == nil {
+		scheme = "http"
+	}
+	behavior, _ := json.Marshal(map[string]interface{}{
+		"loginResult":      s.opts.Viewer.LoginResult,
+		"loginDelayMs":     s.opts.Viewer.LoginDelay.Milliseconds(),
+		"terminate":        s.opts.Viewer.Terminate,
+		"terminateReason":  s.opts.Viewer.TerminateReason,
+		"terminateAfterMs": s.opts.Viewer.TerminateAfter.Milliseconds(),
+		"reportURL":        fmt.Sprintf("%s://%s/__viewer/calls", scheme, r.Host),
+	})
+	return fmt.Sprintf(viewerStubSource, behavior)
+}
+
+// viewerStubSource is a stand-in for the XCC's RPViewer. It records every call and
+// plays the scripted ViewerBehavior instead of opening a WebSocket to the RP port.
+const viewerStubSource = `// lenovoconsoletest stub RPViewer
+(function() {
+    const behavior = %s;
+
+    function report(method, args) {
+        const call = {method: method, args: Array.prototype.slice.call(args || [])};
+        (window.lenovoconsoletestCalls = window.lenovoconsoletestCalls || []).push(call);
+        return fetch(behavior.reportURL, {
+            method: 'POST',
+            mode: 'no-cors',
+            headers: {'Content-Type': 'text/plain'},
+            body: JSON.stringify(call)
+        }).catch(function(error) {
+            console.log('lenovoconsoletest: could not report ' + method + ':', error);
+        });
+    }
+
+    function finish() {
+        report('done', []).then(function() {
+            window.lenovoconsoletestDone = true;
+        });
+    }
+
+    function RPViewer(canvasId, errorCallback) {
+        this.callbacks = {};
+        report('RPViewer', [canvasId]);
+    }
+
+    [
+        'setRPWebSocketTimeout', 'setRPServerConfiguration', 'setRPEmbeddedViewerSize',
+        'setRPExclusiveLogin', 'setRPAllowSharingRequests', 'setRPMouseInputSupport',
+        'setRPTouchInputSupport', 'setRPKeyboardInputSupport', 'setRPDebugMode',
+        'setRPDebugLevel', 'setRPMaintainAspectRatio', 'setRPInitialBackgroundColor',
+        'setRPInitialMessageColor', 'setRPKeyboardLanguage', 'setRPSupportReconnect',
+        'setRPLinkInterruptMessageColor', 'setRPLinkInterruptMessage',
+        'setRPReconnectingMessage', 'setRPInitialMessage', 'setRPCredential',
+        'setRPCertFileName', 'disconnectRPViewer', 'sendRPKeyEvent'
+    ].forEach(function(method) {
+        RPViewer.prototype[method] = function() {
+            report(method, arguments);
+        };
+    });
+
+    const callbacks = {
+        registerRPLoginResponseCallback: 'login',
+        registerRPUIInitCallback: 'uiInit',
+        registerRPExitViewerCallback: 'exit',
+        registerRPResolutionCallback: 'resolution',
+        registerRPSessionTerminationCallback: 'terminated'
+    };
+    Object.keys(callbacks).forEach(function(method) {
+        RPViewer.prototype[method] = function(callback) {
+            this.callbacks[callbacks[method]] = callback;
+            report(method, []);
+        };
+    });
+
+    RPViewer.prototype.connectRPViewer = function() {
+        const self = this;
+        report('connectRPViewer', []);
+        setTimeout(function() {
+            if (self.callbacks.uiInit) {
+                self.callbacks.uiInit();
+            }
+            self.callbacks.login(behavior.loginResult, {});
+            if (behavior.loginResult !== 0 || !behavior.terminate) {
+                finish();
+                return;
+            }
+            if (self.callbacks.resolution) {
+                self.callbacks.resolution(1024, 768);
+            }
+            setTimeout(function() {
+                self.callbacks.terminated(behavior.terminateReason);
+                finish();
+            }, behavior.terminateAfterMs);
+        }, behavior.loginDelayMs);
+    };
+
+    window.RPViewer = RPViewer;
+})();
+`
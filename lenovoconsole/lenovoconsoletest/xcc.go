@@ -0,0 +1,392 @@
+// Package lenovoconsoletest provides a fake Lenovo XCC for testing code that uses lenovoconsole.
+//
+// The fake serves the endpoints the library talks to: the web login page, the rp_port
+// provider API, the RPViewer SDK under /SDK_Pilot4/ and a minimal Redfish tree.
+// Authentication failures, slow responses and certificate problems can be switched on
+// to exercise error paths.
+//
+// The fake's rpviewer.js is a stub that records the calls made by the console page and
+// plays scripted login results and session terminations. RunPage loads a console page
+// against it in a headless engine.
+//
+// NewSOCKSProxy stands in for a SOCKS5 bastion in front of the BMC network.
+package lenovoconsoletest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default credentials and RP port of a fake XCC
+const (
+	DefaultUsername = "USERID"
+	DefaultPassword = "PASSW0RD"
+	DefaultRPPort   = 3900
+)
+
+// SDKAssets lists the RPViewer SDK files served under /SDK_Pilot4/
+var SDKAssets = []string{
+	"utility.js",
+	"rpimage.js",
+	"rprecorder.js",
+	"rpviewer.js",
+	"rphandlers.js",
+	"websockethandler.js",
+	"virtualkeyboard.js",
+	"mediaTypes.js",
+	"mediaworkerhandler.js",
+	"offscreenworker.js",
+	"mouseworker.js",
+}
+
+// Options configures a fake XCC. The zero value is a healthy XCC with default credentials.
+type Options struct {
+	Username string // Accepted username (default: DefaultUsername)
+	Password string // Accepted password (default: DefaultPassword)
+	RPPort   int    // Port reported by /api/providers/rp_port (default: DefaultRPPort)
+
+	// AuthFailures rejects this many authenticated requests with 401 before valid
+	// credentials are accepted; -1 rejects every request
+	AuthFailures int
+
+	Latency  time.Duration     // Delay before every response
+	TLS      TLSQuirk          // Certificate and protocol behaviour
+	NoRPPort bool              // Answer 404 for the rp_port API, like older firmware
+	Assets   map[string]string // SDK file contents by name, replacing the default stubs
+	Viewer   ViewerBehavior    // Scripted responses of the stub RPViewer served as rpviewer.js
+
+	IMM2       bool   // Behave like an IMM2: SDK under /designs/imm/SDK_Pilot4/ and no rp_port API
+	IPv6       bool   // Listen on [::1] instead of 127.0.0.1; NewServer panics if IPv6 is unavailable
+	PowerState string // Initial Redfish PowerState of the server (default: "On")
+
+	// Identity reported by Redfish for the server and the XCC
+	HostName     string // ComputerSystem HostName (default: "fake-xcc-host")
+	Model        string // ComputerSystem Model (default: "ThinkSystem SR650 V2")
+	SerialNumber string // ComputerSystem SerialNumber (default: "J900TEST")
+	Health       string // ComputerSystem Status.Health (default: "OK")
+	Firmware     string // Manager FirmwareVersion (default: "TGBT99Z")
+}
+
+// RecordedRequest is a request received by the fake XCC
+type RecordedRequest struct {
+	Method   string
+	Path     string
+	Username string // Basic auth username, if any
+}
+
+// Server is a fake XCC listening on a local port
+type Server struct {
+	// Addr is the host:port of the fake, usable wherever the library expects a BMC address
+	Addr string
+	// URL is the base URL of the fake, e.g. https://127.0.0.1:43127
+	URL string
+
+	opts   Options
+	server *httptest.Server
+
+	mu           sync.Mutex
+	authFailures int
+	rpPort       int
+	rpPortErrors int
+	requests     []RecordedRequest
+	viewerCalls  []ViewerCall
+	powerState   string
+	resets       []string
+}
+
+// NewServer starts a fake XCC. Callers should Close it when done.
+func NewServer(opts Options) *Server {
+	if opts.Username == "" {
+		opts.Username = DefaultUsername
+	}
+	if opts.Password == "" {
+		opts.Password = DefaultPassword
+	}
+	if opts.RPPort == 0 {
+		opts.RPPort = DefaultRPPort
+	}
+	if opts.PowerState == "" {
+		opts.PowerState = "On"
+	}
+	if opts.IMM2 {
+		opts.NoRPPort = true
+	}
+	if opts.HostName == "" {
+		opts.HostName = "fake-xcc-host"
+	}
+	if opts.Model == "" {
+		opts.Model = "ThinkSystem SR650 V2"
+	}
+	if opts.SerialNumber == "" {
+		opts.SerialNumber = "J900TEST"
+	}
+	if opts.Health == "" {
+		opts.Health = "OK"
+	}
+	if opts.Firmware == "" {
+		opts.Firmware = "TGBT99Z"
+	}
+
+	s := &Server{opts: opts, authFailures: opts.AuthFailures, powerState: opts.PowerState, rpPort: opts.RPPort}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/providers/rp_port", s.rpPortHandler)
+	if opts.IMM2 {
+		mux.HandleFunc("/designs/imm/SDK_Pilot4/", s.assetHandler)
+	} else {
+		mux.HandleFunc("/SDK_Pilot4/", s.assetHandler)
+	}
+	for _, name := range SDKAssets {
+		// The viewer also requests workers and helpers from the web root
+		mux.HandleFunc("/"+name, s.assetHandler)
+	}
+	mux.HandleFunc("/redfish/v1/", s.redfishHandler)
+	mux.HandleFunc("/", s.loginPageHandler)
+	mux.HandleFunc("/__viewer/calls", s.viewerCallsHandler)
+
+	s.server = httptest.NewUnstartedServer(s.record(mux))
+	// TLS quirks make handshakes fail on purpose; keep them out of test output
+	s.server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	if opts.IPv6 {
+		l, err := net.Listen("tcp6", "[::1]:0")
+		if err != nil {
+			panic("lenovoconsoletest: cannot listen on IPv6 loopback: " + err.Error())
+		}
+		s.server.Listener.Close()
+		s.server.Listener = l
+	}
+
+	if opts.TLS == TLSPlainHTTP {
+		s.server.Start()
+	} else {
+		s.server.TLS = opts.TLS.config()
+		s.server.StartTLS()
+	}
+
+	s.URL = s.server.URL
+	s.Addr = s.server.Listener.Addr().String()
+	return s
+}
+
+// Close shuts down the fake XCC
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Host returns the host part of Addr
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr)
+	return host
+}
+
+// Port returns the port part of Addr
+func (s *Server) Port() int {
+	return s.server.Listener.Addr().(*net.TCPAddr).Port
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RecordedRequest(nil), s.requests...)
+}
+
+// Requested reports whether the fake received a request for path
+func (s *Server) Requested(path string) bool {
+	for _, r := range s.Requests() {
+		if r.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// record logs every request and applies the configured latency
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, _, _ := r.BasicAuth()
+		s.mu.Lock()
+		s.requests = append(s.requests, RecordedRequest{Method: r.Method, Path: r.URL.Path, Username: username})
+		s.mu.Unlock()
+
+		if s.opts.Latency > 0 {
+			select {
+			case <-time.After(s.opts.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorize checks basic auth credentials and writes a 401 response if they are rejected
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+
+	s.mu.Lock()
+	reject := s.authFailures != 0
+	if s.authFailures > 0 {
+		s.authFailures--
+	}
+	s.mu.Unlock()
+
+	if reject || !ok || username != s.opts.Username || password != s.opts.Password {
+		w.Header().Set("WWW-Authenticate", `Basic realm="XCC"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// SetRPPort changes the port reported by the rp_port API, as after the XCC's remote
+// presence settings change
+func (s *Server) SetRPPort(port int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rpPort = port
+}
+
+// FailRPPort makes the next n rp_port requests answer 503, as while the XCC's web
+// services start after a reboot
+func (s *Server) FailRPPort(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rpPortErrors = n
+}
+
+func (s *Server) rpPortHandler(w http.ResponseWriter, r *http.Request) {
+	if s.opts.NoRPPort {
+		http.NotFound(w, r)
+		return
+	}
+	if !s.authorize(w, r) {
+		return
+	}
+	s.mu.Lock()
+	port, failing := s.rpPort, s.rpPortErrors > 0
+	if failing {
+		s.rpPortErrors--
+	}
+	s.mu.Unlock()
+	if failing {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, map[string]int{"port": port})
+}
+
+func (s *Server) assetHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	body, ok := s.opts.Assets[name]
+	if !ok && name == "rpviewer.js" {
+		body, ok = s.viewerStub(r), true
+	}
+	if !ok {
+		for _, asset := range SDKAssets {
+			if asset == name {
+				body, ok = fmt.Sprintf("// lenovoconsoletest stub for %s\n", name), true
+				break
+			}
+		}
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/javascript")
+	w.Write([]byte(body))
+}
+
+// loginPageHandler serves a stand-in for the web login page, whose title identifies the BMC
+func (s *Server) loginPageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	title := "XClarity Controller"
+	if s.opts.IMM2 {
+		title = "Integrated Management Module II"
+	}
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><title>%s</title></head><body>Log in</body></html>\n", title)
+}
+
+func (s *Server) redfishHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	if path == "/redfish/v1" {
+		// The service root is readable without credentials
+		writeJSON(w, map[string]interface{}{
+			"@odata.id":      "/redfish/v1",
+			"RedfishVersion": "1.8.0",
+			"Vendor":         "Lenovo",
+			"Product":        s.opts.Model,
+			"Managers":       map[string]string{"@odata.id": "/redfish/v1/Managers"},
+			"Systems":        map[string]string{"@odata.id": "/redfish/v1/Systems"},
+			"SessionService": map[string]string{"@odata.id": "/redfish/v1/SessionService"},
+		})
+		return
+	}
+	if !s.authorize(w, r) {
+		return
+	}
+
+	switch path {
+	case "/redfish/v1/Managers":
+		writeJSON(w, collection("/redfish/v1/Managers/1"))
+	case "/redfish/v1/Managers/1":
+		writeJSON(w, map[string]interface{}{
+			"@odata.id":       "/redfish/v1/Managers/1",
+			"Id":              "1",
+			"Model":           "Lenovo XClarity Controller",
+			"FirmwareVersion": s.opts.Firmware,
+			"Status":          map[string]string{"State": "Enabled", "Health": "OK"},
+		})
+	case "/redfish/v1/SessionService/Sessions":
+		writeJSON(w, collection())
+	case "/redfish/v1/Systems":
+		writeJSON(w, collection("/redfish/v1/Systems/1"))
+	case "/redfish/v1/Systems/1":
+		s.mu.Lock()
+		state := s.powerState
+		s.mu.Unlock()
+		writeJSON(w, map[string]interface{}{
+			"@odata.id":    "/redfish/v1/Systems/1",
+			"Id":           "1",
+			"HostName":     s.opts.HostName,
+			"Manufacturer": "Lenovo",
+			"Model":        s.opts.Model,
+			"SerialNumber": s.opts.SerialNumber,
+			"BiosVersion":  "TEE170J-3.10",
+			"PowerState":   state,
+			"Status":       map[string]string{"State": "Enabled", "Health": s.opts.Health, "HealthRollup": s.opts.Health},
+		})
+	case "/redfish/v1/Systems/1/Actions/ComputerSystem.Reset":
+		s.resetHandler(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// collection builds a Redfish resource collection listing paths
+func collection(paths ...string) map[string]interface{} {
+	members := make([]map[string]string, 0, len(paths))
+	for _, p := range paths {
+		members = append(members, map[string]string{"@odata.id": p})
+	}
+	return map[string]interface{}{"Members": members, "Members@odata.count": len(members)}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
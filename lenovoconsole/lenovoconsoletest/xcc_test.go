@@ -0,0 +1,126 @@
+package lenovoconsoletest
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func insecureClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		Timeout:   5 * time.Second,
+	}
+}
+
+func get(t *testing.T, client *http.Client, url, username, password string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	return client.Do(req)
+}
+
+func TestAuthFailures(t *testing.T) {
+	xcc := NewServer(Options{AuthFailures: 2})
+	defer xcc.Close()
+
+	client := insecureClient()
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusOK}
+	for i, status := range want {
+		resp, err := get(t, client, xcc.URL+"/api/providers/rp_port", DefaultUsername, DefaultPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("request %d: status = %d, want %d", i+1, resp.StatusCode, status)
+		}
+	}
+
+	resp, err := get(t, client, xcc.URL+"/api/providers/rp_port", DefaultUsername, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong password: status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	xcc := NewServer(Options{Latency: 300 * time.Millisecond})
+	defer xcc.Close()
+
+	client := insecureClient()
+	client.Timeout = 100 * time.Millisecond
+	if _, err := get(t, client, xcc.URL+"/SDK_Pilot4/rpviewer.js", "", ""); err == nil {
+		t.Fatal("expected a timeout from a slow fake")
+	}
+
+	client.Timeout = 5 * time.Second
+	resp, err := get(t, client, xcc.URL+"/SDK_Pilot4/rpviewer.js", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestTLSQuirks(t *testing.T) {
+	tests := []struct {
+		quirk      TLSQuirk
+		verified   string // error expected from a verifying client, "" for success
+		insecureOK bool
+	}{
+		{TLSSelfSigned, "certificate", true},
+		{TLSExpired, "certificate", true},
+		{TLSWrongHost, "certificate", true},
+		{TLSLegacy, "protocol version", false},
+	}
+	for _, tt := range tests {
+		xcc := NewServer(Options{TLS: tt.quirk})
+
+		_, err := get(t, &http.Client{Timeout: 5 * time.Second}, xcc.URL+"/redfish/v1/", "", "")
+		if err == nil || !strings.Contains(err.Error(), tt.verified) {
+			t.Errorf("quirk %d: verifying client error = %v, want %q", tt.quirk, err, tt.verified)
+		}
+
+		resp, err := get(t, insecureClient(), xcc.URL+"/redfish/v1/", "", "")
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != tt.insecureOK {
+			t.Errorf("quirk %d: insecure client error = %v", tt.quirk, err)
+		}
+		xcc.Close()
+	}
+
+	xcc := NewServer(Options{TLS: TLSExpired})
+	defer xcc.Close()
+	if cert := xcc.Certificate(); cert == nil || !cert.NotAfter.Before(time.Now()) {
+		t.Error("TLSExpired certificate is not expired")
+	}
+}
+
+func TestPlainHTTP(t *testing.T) {
+	xcc := NewServer(Options{TLS: TLSPlainHTTP})
+	defer xcc.Close()
+
+	if !strings.HasPrefix(xcc.URL, "http://") {
+		t.Fatalf("URL = %s, want http://", xcc.URL)
+	}
+	if xcc.Certificate() != nil {
+		t.Error("plain HTTP fake has a certificate")
+	}
+	resp, err := get(t, http.DefaultClient, xcc.URL+"/redfish/v1/", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,100 @@
+package lenovoconsole
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Session limits reported to the page in "limit" commands
+const (
+	limitIdle       = "idle"
+	limitMaxSession = "max-session"
+)
+
+// limitMessages describe each session limit
+var limitMessages = map[string]string{
+	limitIdle:       "Idle timeout",
+	limitMaxSession: "Maximum session duration",
+}
+
+// defaultLimitWarning is how long before a limit the page is warned
+const defaultLimitWarning = time.Minute
+
+// enforceLimits stops the console when the idle timeout or the maximum session
+// duration is reached, warning open pages beforehand
+func (c *Console) enforceLimits() {
+	warning := c.config.LimitWarning
+	if warning <= 0 {
+		warning = defaultLimitWarning
+	}
+	started := time.Now()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	warned := ""
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.stopCh:
+			return
+		}
+
+		limit, remaining := c.nextLimit(started)
+		switch {
+		case remaining <= 0:
+			message := limitMessages[limit] + " reached"
+			c.emit(Event{Type: EventLimitReached, Message: message})
+			if c.sendCommand(pageCommand{Name: "shutdown", Message: "Console closed: " + message}) > 0 {
+				select {
+				case <-time.After(pageShutdownGrace):
+				case <-c.stopCh:
+					return
+				}
+			}
+			fmt.Printf("Stopping console for BMC %s: %s\n", c.config.BMCIP, message)
+			c.Stop()
+			return
+
+		case remaining <= warning:
+			if warned != limit {
+				warned = limit
+				message := fmt.Sprintf("%s in %s", limitMessages[limit], remaining.Round(time.Second))
+				c.emit(Event{Type: EventLimitWarning, Message: message})
+				c.sendCommand(pageCommand{Name: "limit", Reason: limit,
+					Message: limitMessages[limit] + ": the console will close",
+					Seconds: int(remaining.Round(time.Second) / time.Second)})
+			}
+
+		case warned != "":
+			// Activity resumed; clear the banner
+			warned = ""
+			c.sendCommand(pageCommand{Name: "limit"})
+		}
+	}
+}
+
+// nextLimit returns the session limit that will be hit first and the time left until it
+func (c *Console) nextLimit(started time.Time) (string, time.Duration) {
+	limit, remaining := "", time.Duration(1<<63-1)
+	if c.config.IdleTimeout > 0 {
+		if left := time.Until(c.LastActivity().Add(c.config.IdleTimeout)); left < remaining {
+			limit, remaining = limitIdle, left
+		}
+	}
+	if c.config.MaxSessionDuration > 0 {
+		if left := time.Until(started.Add(c.config.MaxSessionDuration)); left < remaining {
+			limit, remaining = limitMaxSession, left
+		}
+	}
+	return limit, remaining
+}
+
+// heartbeatHandler records viewer input reported by the console page
+func (c *Console) heartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.pagePost(w, r, "") {
+		return
+	}
+	c.touch()
+	w.WriteHeader(http.StatusNoContent)
+}
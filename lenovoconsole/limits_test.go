@@ -0,0 +1,93 @@
+package lenovoconsole
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// nextCommand returns the next command sent to a page, failing after timeout
+func nextCommand(t *testing.T, page chan pageCommand, timeout time.Duration) pageCommand {
+	t.Helper()
+	select {
+	case cmd := <-page:
+		return cmd
+	case <-time.After(timeout):
+		t.Fatal("no command sent to the page")
+		return pageCommand{}
+	}
+}
+
+func TestMaxSessionDuration(t *testing.T) {
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", MaxSessionDuration: 2 * time.Second, LimitWarning: 1500 * time.Millisecond})
+	events, cancel := c.Subscribe()
+	defer cancel()
+	page := make(chan pageCommand, 8)
+	c.pages[page] = false
+	started := time.Now()
+	go c.enforceLimits()
+
+	banner := nextCommand(t, page, 3*time.Second)
+	if banner.Name != "limit" || banner.Reason != limitMaxSession || banner.Seconds < 1 || banner.Seconds > 2 {
+		t.Errorf("banner command = %+v", banner)
+	}
+	// Input does not extend the maximum session duration
+	c.heartbeatHandler(httptest.NewRecorder(), pageRequest(c, "/api/heartbeat", "", nil))
+
+	shutdown := nextCommand(t, page, 3*time.Second)
+	if shutdown.Name != "shutdown" || shutdown.Message != "Console closed: Maximum session duration reached" {
+		t.Errorf("shutdown command = %+v", shutdown)
+	}
+	select {
+	case <-c.Stopped():
+	case <-time.After(3 * time.Second):
+		t.Fatal("console not stopped at the maximum session duration")
+	}
+	if lifetime := time.Since(started); lifetime < 2*time.Second {
+		t.Errorf("console stopped after %v", lifetime)
+	}
+
+	for _, want := range []EventType{EventLimitWarning, EventLimitReached} {
+		if e := <-events; e.Type != want {
+			t.Errorf("event %+v, want %s", e, want)
+		}
+	}
+}
+
+func TestIdleTimeoutHeartbeat(t *testing.T) {
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", IdleTimeout: 3 * time.Second, LimitWarning: 1500 * time.Millisecond})
+	page := make(chan pageCommand, 8)
+	c.pages[page] = false
+	c.touch() // as Start does
+	go c.enforceLimits()
+	defer c.Stop()
+
+	if banner := nextCommand(t, page, 3*time.Second); banner.Name != "limit" || banner.Reason != limitIdle {
+		t.Fatalf("banner command = %+v", banner)
+	}
+	rec := httptest.NewRecorder()
+	c.heartbeatHandler(rec, pageRequest(c, "/api/heartbeat", "", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("heartbeat: status %d", rec.Code)
+	}
+	// The heartbeat counts as input, so the banner is cleared and the console kept
+	if cleared := nextCommand(t, page, 3*time.Second); cleared.Name != "limit" || cleared.Reason != "" {
+		t.Errorf("command after the heartbeat = %+v", cleared)
+	}
+	if !running(c) {
+		t.Error("console stopped despite the heartbeat")
+	}
+
+	if cmd := nextCommand(t, page, 3*time.Second); cmd.Name != "limit" || cmd.Reason != limitIdle {
+		t.Errorf("second banner = %+v", cmd)
+	}
+	if cmd := nextCommand(t, page, 3*time.Second); cmd.Name != "shutdown" {
+		t.Errorf("command at the idle timeout = %+v", cmd)
+	}
+	select {
+	case <-c.Stopped():
+	case <-time.After(3 * time.Second):
+		t.Fatal("idle console not stopped")
+	}
+}
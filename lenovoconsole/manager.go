@@ -0,0 +1,625 @@
+package lenovoconsole
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RegistryEntry describes a console registered with a Manager
+type RegistryEntry struct {
+	Name     string   `json:"name"`               // Unique name used in URLs (defaults to the BMC address)
+	BMCIP    string   `json:"bmc_ip"`             // IP address of the BMC/XCC
+	Username string   `json:"username"`           // Username for authentication
+	Password string   `json:"password"`           // Password for authentication
+	RPPort   int      `json:"rp_port,omitempty"`  // Remote Presence port (0 to query the BMC)
+	Provider string   `json:"provider,omitempty"` // BMC family, e.g. "xcc" or "imm2" (default: xcc)
+	Tags     []string `json:"tags,omitempty"`     // Labels for filtering the console wall, e.g. "rack12"
+}
+
+// ManagerConfig configures a Manager
+type ManagerConfig struct {
+	RegistryPath string        // JSON file the registry is persisted to (empty keeps it in memory)
+	IdleTimeout  time.Duration // Stop consoles with no open pages for this long (0 disables)
+	PortMin      int           // First local port for console servers (0 for auto-assign)
+	PortMax      int           // Last local port for console servers
+	ListenHost   string        // Address console servers listen on, normally the host of the index address (empty for all interfaces)
+	APIToken     string        // Token POST and DELETE requests to the management API must send in X-Console-Token (empty generates one, see APIToken)
+	WallMaxTiles int           // Most thumbnails the console wall shows, each holding a viewer session on its BMC (0 for 12)
+	Console      ConsoleConfig // Settings shared by all consoles; connection fields come from the entry
+}
+
+// Manager keeps a persistent registry of consoles, starts them on first access
+// and stops them again when they have been idle for IdleTimeout
+type Manager struct {
+	config   ManagerConfig
+	apiToken string
+
+	mu      sync.Mutex
+	entries map[string]RegistryEntry
+	slots   map[string]*managedConsole
+	done    chan struct{}
+	stopped sync.Once
+}
+
+// managedConsole holds the running console for a registry entry, if any
+type managedConsole struct {
+	mu       sync.Mutex
+	console  *Console
+	starting *consoleStart // Start in progress, see Open
+	port     int           // Port reserved from the configured range; guarded by Manager.mu
+	info     *BMCInfo      // Server identity last read by a console for this entry
+}
+
+// consoleStart is a console being started by Open. The slot is not locked while the
+// console connects to its BMC, so concurrent Opens wait for this start instead.
+type consoleStart struct {
+	done     chan struct{} // Closed once console and err are set
+	console  *Console
+	err      error
+	canceled bool // The entry was replaced or removed, or the manager shut down
+}
+
+// ConsoleStatus is a registry entry as reported by the management API, without its password
+type ConsoleStatus struct {
+	Name         string     `json:"name"`
+	BMCIP        string     `json:"bmc_ip"`
+	Username     string     `json:"username"`
+	Running      bool       `json:"running"`
+	Port         int        `json:"port,omitempty"`
+	LastActivity *time.Time `json:"last_activity,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	Info         *BMCInfo   `json:"info,omitempty"` // Last known server identity, once its console has read it
+}
+
+// validEntryName restricts names to characters that are safe in URL paths
+var validEntryName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// NewManager creates a Manager and loads its registry file, if it exists
+func NewManager(config ManagerConfig) (*Manager, error) {
+	if config.PortMin > 0 && config.PortMax < config.PortMin {
+		return nil, fmt.Errorf("invalid port range %d-%d", config.PortMin, config.PortMax)
+	}
+
+	m := &Manager{
+		config:   config,
+		apiToken: config.APIToken,
+		entries:  make(map[string]RegistryEntry),
+		slots:    make(map[string]*managedConsole),
+		done:     make(chan struct{}),
+	}
+	if m.apiToken == "" {
+		m.apiToken = newPageToken()
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	if config.IdleTimeout > 0 {
+		go m.reapIdle()
+	}
+	return m, nil
+}
+
+// APIToken returns the token that changes through the management API must carry
+func (m *Manager) APIToken() string {
+	return m.apiToken
+}
+
+// Add registers a console, replacing any entry with the same name, and persists the registry.
+// A running console for a replaced entry is stopped so the next access uses the new settings.
+func (m *Manager) Add(entry RegistryEntry) error {
+	if entry.BMCIP != "" {
+		addr, err := ParseBMCAddress(entry.BMCIP)
+		if err != nil {
+			return err
+		}
+		entry.BMCIP = addr.String()
+	}
+	if entry.Name == "" {
+		// IPv6 addresses and ports contain characters that are not valid in names
+		entry.Name = unsafeFileChars.ReplaceAllString(entry.BMCIP, "-")
+	}
+	if !validEntryName.MatchString(entry.Name) {
+		return fmt.Errorf("invalid console name %q", entry.Name)
+	}
+	if entry.BMCIP == "" || entry.Username == "" {
+		return fmt.Errorf("console %s: BMC address and username are required", entry.Name)
+	}
+	if _, err := ProviderByName(entry.Provider); err != nil {
+		return fmt.Errorf("console %s: %v", entry.Name, err)
+	}
+
+	m.mu.Lock()
+	m.entries[entry.Name] = entry
+	slot := m.slots[entry.Name]
+	err := m.saveLocked()
+	m.mu.Unlock()
+
+	if slot != nil {
+		m.stopSlot(slot)
+	}
+	return err
+}
+
+// Remove unregisters a console, stopping it if it is running
+func (m *Manager) Remove(name string) error {
+	m.mu.Lock()
+	if _, ok := m.entries[name]; !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("console %s not found", name)
+	}
+	delete(m.entries, name)
+	slot := m.slots[name]
+	delete(m.slots, name)
+	err := m.saveLocked()
+	m.mu.Unlock()
+
+	if slot != nil {
+		m.stopSlot(slot)
+	}
+	return err
+}
+
+// Entries returns the registered consoles sorted by name
+func (m *Manager) Entries() []RegistryEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make([]RegistryEntry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// Status reports every registered console and whether it is running
+func (m *Manager) Status() []ConsoleStatus {
+	var statuses []ConsoleStatus
+	for _, e := range m.Entries() {
+		status := ConsoleStatus{Name: e.Name, BMCIP: e.BMCIP, Username: e.Username, Tags: e.Tags}
+
+		m.mu.Lock()
+		slot := m.slots[e.Name]
+		m.mu.Unlock()
+		if slot != nil {
+			slot.mu.Lock()
+			if slot.console != nil && running(slot.console) {
+				status.Running = true
+				status.Port = slot.console.GetPort()
+				last := slot.console.LastActivity()
+				status.LastActivity = &last
+			}
+			if slot.console != nil && slot.console.CachedInfo() != nil {
+				slot.info = slot.console.CachedInfo()
+			}
+			status.Info = slot.info
+			slot.mu.Unlock()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Open returns the running console for name, starting it first if necessary.
+// Concurrent calls for the same console share one start.
+func (m *Manager) Open(name string) (*Console, error) {
+	m.mu.Lock()
+	entry, ok := m.entries[name]
+	if !ok {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("console %s not found", name)
+	}
+	slot := m.slots[name]
+	if slot == nil {
+		slot = &managedConsole{}
+		m.slots[name] = slot
+	}
+	m.mu.Unlock()
+
+	slot.mu.Lock()
+	if slot.console != nil {
+		if c := slot.console; running(c) {
+			slot.mu.Unlock()
+			return c, nil
+		}
+		// Stopped by its own session limits; start a fresh one
+		slot.console = nil
+		m.releasePort(slot)
+	}
+	if start := slot.starting; start != nil {
+		slot.mu.Unlock()
+		<-start.done
+		return start.console, start.err
+	}
+	start := &consoleStart{done: make(chan struct{})}
+	slot.starting = start
+	slot.mu.Unlock()
+
+	console, err := m.startConsole(name, entry, slot)
+
+	slot.mu.Lock()
+	slot.starting = nil
+	if err == nil && start.canceled {
+		console.Stop()
+		m.releasePort(slot)
+		console, err = nil, fmt.Errorf("console %s was changed or removed while starting", name)
+	}
+	if err == nil {
+		slot.console = console
+	}
+	start.console, start.err = console, err
+	close(start.done)
+	slot.mu.Unlock()
+	return console, err
+}
+
+// startConsole creates, initializes and starts the console for entry. It connects to
+// the BMC, so the slot must not be locked.
+func (m *Manager) startConsole(name string, entry RegistryEntry, slot *managedConsole) (*Console, error) {
+	config := m.config.Console
+	config.BMCIP = entry.BMCIP
+	config.Username = entry.Username
+	config.Password = entry.Password
+	config.RPPort = entry.RPPort
+	config.NoBrowser = true
+	config.ListenHost = m.config.ListenHost
+	if entry.Provider != "" {
+		provider, err := ProviderByName(entry.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("console %s: %v", name, err)
+		}
+		config.Provider = provider
+	}
+	if m.config.PortMin > 0 {
+		port, err := m.allocatePort(slot)
+		if err != nil {
+			return nil, err
+		}
+		config.ServerPort = port
+	}
+
+	console := NewConsole(config)
+	if err := console.Initialize(); err != nil {
+		m.releasePort(slot)
+		return nil, fmt.Errorf("console %s: %v", name, err)
+	}
+	if err := console.Start(); err != nil {
+		m.releasePort(slot)
+		return nil, fmt.Errorf("console %s: %v", name, err)
+	}
+	fmt.Printf("Started console %s for BMC %s on port %d\n", name, entry.BMCIP, console.GetPort())
+	return console, nil
+}
+
+// Shutdown stops every running console and the idle reaper
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.stopped.Do(func() { close(m.done) })
+
+	m.mu.Lock()
+	slots := make([]*managedConsole, 0, len(m.slots))
+	for _, slot := range m.slots {
+		slots = append(slots, slot)
+	}
+	m.mu.Unlock()
+
+	var firstErr error
+	for _, slot := range slots {
+		slot.mu.Lock()
+		if slot.starting != nil {
+			slot.starting.canceled = true
+		}
+		if slot.console != nil {
+			if err := slot.console.Shutdown(ctx); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			slot.console = nil
+			m.releasePort(slot)
+		}
+		slot.mu.Unlock()
+	}
+	return firstErr
+}
+
+// stopSlot stops the slot's console, if it is running or starting
+func (m *Manager) stopSlot(slot *managedConsole) {
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	if slot.starting != nil {
+		slot.starting.canceled = true
+	}
+	if slot.console != nil {
+		slot.console.Stop()
+		slot.console = nil
+		m.releasePort(slot)
+	}
+}
+
+// reapIdle periodically stops consoles that have no open pages and no recent requests
+func (m *Manager) reapIdle() {
+	interval := m.config.IdleTimeout / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-m.done:
+			return
+		}
+
+		m.mu.Lock()
+		slots := make(map[string]*managedConsole, len(m.slots))
+		for name, slot := range m.slots {
+			slots[name] = slot
+		}
+		m.mu.Unlock()
+
+		for name, slot := range slots {
+			slot.mu.Lock()
+			c := slot.console
+			if c != nil && !running(c) {
+				slot.console = nil
+				m.releasePort(slot)
+			} else if c != nil && c.ConnectedPages() == 0 && time.Since(c.LastActivity()) > m.config.IdleTimeout {
+				fmt.Printf("Stopping idle console %s\n", name)
+				c.Stop()
+				slot.console = nil
+				m.releasePort(slot)
+			}
+			slot.mu.Unlock()
+		}
+	}
+}
+
+// running reports whether c has not been stopped
+func running(c *Console) bool {
+	select {
+	case <-c.Stopped():
+		return false
+	default:
+		return true
+	}
+}
+
+// allocatePort reserves the first free port in the configured range for slot
+func (m *Manager) allocatePort(slot *managedConsole) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	used := make(map[int]bool)
+	for _, s := range m.slots {
+		if s.port != 0 {
+			used[s.port] = true
+		}
+	}
+	for port := m.config.PortMin; port <= m.config.PortMax; port++ {
+		if used[port] {
+			continue
+		}
+		l, err := net.Listen("tcp", net.JoinHostPort(m.config.ListenHost, strconv.Itoa(port)))
+		if err != nil {
+			continue
+		}
+		l.Close()
+		slot.port = port
+		return port, nil
+	}
+	return 0, fmt.Errorf("no free port in range %d-%d", m.config.PortMin, m.config.PortMax)
+}
+
+// releasePort returns the slot's reserved port to the range
+func (m *Manager) releasePort(slot *managedConsole) {
+	m.mu.Lock()
+	slot.port = 0
+	m.mu.Unlock()
+}
+
+// load reads the registry file
+func (m *Manager) load() error {
+	if m.config.RegistryPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(m.config.RegistryPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read registry: %v", err)
+	}
+
+	var registry struct {
+		Consoles []RegistryEntry `json:"consoles"`
+	}
+	if err := json.Unmarshal(data, &registry); err != nil {
+		return fmt.Errorf("invalid registry %s: %v", m.config.RegistryPath, err)
+	}
+	for _, e := range registry.Consoles {
+		if e.Name == "" {
+			e.Name = unsafeFileChars.ReplaceAllString(e.BMCIP, "-")
+		}
+		m.entries[e.Name] = e
+	}
+	return nil
+}
+
+// saveLocked atomically writes the registry file. m.mu must be held.
+func (m *Manager) saveLocked() error {
+	if m.config.RegistryPath == "" {
+		return nil
+	}
+
+	var registry struct {
+		Consoles []RegistryEntry `json:"consoles"`
+	}
+	for _, e := range m.entries {
+		registry.Consoles = append(registry.Consoles, e)
+	}
+	sort.Slice(registry.Consoles, func(i, j int) bool { return registry.Consoles[i].Name < registry.Consoles[j].Name })
+
+	data, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(m.config.RegistryPath)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create registry directory: %v", err)
+	}
+	// The registry holds BMC passwords, so it is only readable by the owner
+	tmp, err := os.CreateTemp(dir, ".registry-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write registry: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write registry: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write registry: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), m.config.RegistryPath); err != nil {
+		return fmt.Errorf("failed to write registry: %v", err)
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler for the console index, lazy console access and the management API:
+//
+//	GET    /                     index page listing registered consoles
+//	GET    /consoles/{name}      start the console if needed and redirect to it
+//	GET    /wall                 thumbnail grid of consoles, filtered by ?hosts= and ?tags=
+//	GET    /api/consoles         list consoles as JSON
+//	POST   /api/consoles         register or replace a console (RegistryEntry JSON)
+//	DELETE /api/consoles/{name}  unregister a console
+//
+// POST and DELETE requests must carry the APIToken in the X-Console-Token header, which
+// cross-site forms cannot set, and POST bodies must be application/json.
+func (m *Manager) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", m.indexHandler)
+	mux.HandleFunc("/consoles/", m.openHandler)
+	mux.HandleFunc("/wall", m.wallHandler)
+	mux.HandleFunc("/api/consoles", m.apiHandler)
+	mux.HandleFunc("/api/consoles/", m.apiHandler)
+	return mux
+}
+
+// indexTemplate renders the list of registered consoles
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <title>Lenovo XCC Remote Consoles</title>
+    <style>
+        body { font-family: Arial, sans-serif; margin: 20px; }
+        table { border-collapse: collapse; }
+        th, td { text-align: left; padding: 6px 14px; border-bottom: 1px solid #ddd; }
+    </style>
+</head>
+<body>
+    <h2>Lenovo XCC Remote Consoles</h2>
+    <p><a href="/wall">Console wall</a></p>
+    <table>
+        <tr><th>Name</th><th>BMC</th><th>Server</th><th>Firmware</th><th>Power</th><th>Health</th><th>Tags</th><th>Status</th></tr>
+        {{range .}}
+        <tr>
+            <td><a href="/consoles/{{.Name}}" target="_blank">{{.Name}}</a></td>
+            <td>{{.BMCIP}}</td>
+            {{with .Info}}<td>{{.HostName}} {{.Model}} {{.SerialNumber}}</td><td>{{.FirmwareVersion}}</td><td>{{.PowerState}}</td><td>{{.Health}}</td>
+            {{else}}<td colspan="4">not yet identified</td>{{end}}
+            <td>{{range .Tags}}<a href="/wall?tags={{.}}">{{.}}</a> {{end}}</td>
+            <td>{{if .Running}}running on port {{.Port}}{{else}}stopped{{end}}</td>
+        </tr>
+        {{else}}
+        <tr><td colspan="8">No consoles registered</td></tr>
+        {{end}}
+    </table>
+</body>
+</html>`))
+
+func (m *Manager) indexHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	indexTemplate.Execute(w, m.Status())
+}
+
+func (m *Manager) openHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/consoles/"), "/")
+	console, err := m.Open(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(r.Host); err == nil {
+		host = h
+	}
+	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		host = "[" + host + "]"
+	}
+	target := fmt.Sprintf("http://%s:%d/", host, console.GetPort())
+	if r.URL.RawQuery != "" {
+		// Page options such as ?thumbnail= from the console wall
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (m *Manager) apiHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/consoles"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m.Status())
+
+	case r.Method == http.MethodPost && name == "":
+		if !checkToken(w, r, m.apiToken, "application/json") {
+			return
+		}
+		var entry RegistryEntry
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&entry); err != nil {
+			http.Error(w, "Invalid console entry", http.StatusBadRequest)
+			return
+		}
+		if err := m.Add(entry); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodDelete && name != "":
+		if !checkToken(w, r, m.apiToken, "") {
+			return
+		}
+		if err := m.Remove(name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
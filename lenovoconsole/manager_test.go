@@ -0,0 +1,225 @@
+package lenovoconsole
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+// registerFake adds a registry entry for a fake XCC to m
+func registerFake(t *testing.T, m *Manager, name string, xcc *lenovoconsoletest.Server) {
+	t.Helper()
+	err := m.Add(RegistryEntry{
+		Name:     name,
+		BMCIP:    xcc.Addr,
+		Username: lenovoconsoletest.DefaultUsername,
+		Password: lenovoconsoletest.DefaultPassword,
+	})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+}
+
+func TestManagerOpenConcurrent(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{Latency: 200 * time.Millisecond})
+	defer xcc.Close()
+	m, err := NewManager(ManagerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Shutdown(context.Background())
+	registerFake(t, m, "lab1", xcc)
+
+	opened := make(chan *Console, 4)
+	for i := 0; i < cap(opened); i++ {
+		go func() {
+			c, err := m.Open("lab1")
+			if err != nil {
+				t.Errorf("Open: %v", err)
+			}
+			opened <- c
+		}()
+	}
+	// The BMC is still answering; the registry must not wait for it
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	if status := m.Status(); len(status) != 1 || status[0].Running {
+		t.Errorf("Status while starting = %+v", status)
+	}
+	if waited := time.Since(start); waited > 100*time.Millisecond {
+		t.Errorf("Status waited %v for the starting console", waited)
+	}
+
+	first := <-opened
+	for i := 1; i < cap(opened); i++ {
+		if c := <-opened; c != first {
+			t.Error("concurrent Opens started more than one console")
+		}
+	}
+	if status := m.Status(); !status[0].Running {
+		t.Errorf("Status after Open = %+v", status)
+	}
+
+	// Removing the entry while its console starts discards the console
+	registerFake(t, m, "lab2", xcc)
+	result := make(chan error, 1)
+	go func() {
+		_, err := m.Open("lab2")
+		result <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := m.Remove("lab2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-result; err == nil {
+		t.Error("Open succeeded for a console removed while starting")
+	}
+}
+
+func TestManagerRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "consoles.json")
+	m, err := NewManager(ManagerConfig{RegistryPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []RegistryEntry{
+		{Name: "lab1", BMCIP: "10.0.0.1", Username: "USERID", Password: "PASSW0RD", Tags: []string{"rack12", "pxe"}},
+		{BMCIP: "fd00::1", Username: "admin", Password: "secret", RPPort: 3901, Provider: ProviderIMM2},
+	}
+	for _, e := range entries {
+		if err := m.Add(e); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if err := m.Add(RegistryEntry{Name: "gone", BMCIP: "10.0.0.9", Username: "USERID"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("gone"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("registry file: %v, %v", info, err)
+	}
+
+	loaded, err := NewManager(ManagerConfig{RegistryPath: path})
+	if err != nil {
+		t.Fatalf("loading the registry: %v", err)
+	}
+	// Names default to the BMC address with unsafe characters replaced
+	entries[1].Name = "fd00-1"
+	want := []RegistryEntry{entries[1], entries[0]}
+	if got := loaded.Entries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded entries = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestManagerIdleStop(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer xcc.Close()
+	m, err := NewManager(ManagerConfig{IdleTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Shutdown(context.Background())
+	registerFake(t, m, "idle", xcc)
+	registerFake(t, m, "watched", xcc)
+
+	idle, err := m.Open("idle")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	watched, err := m.Open("watched")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	// An open page keeps a console running however long it has been idle
+	watched.mu.Lock()
+	watched.pages[make(chan pageCommand, 8)] = false
+	watched.mu.Unlock()
+
+	select {
+	case <-idle.Stopped():
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle console was not stopped")
+	}
+	if !running(watched) {
+		t.Error("console with an open page was stopped")
+	}
+	for _, status := range m.Status() {
+		if status.Running != (status.Name == "watched") {
+			t.Errorf("status after the idle stop = %+v", status)
+		}
+	}
+}
+
+func TestManagerAPIToken(t *testing.T) {
+	m, err := NewManager(ManagerConfig{APIToken: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := `{"name":"lab1","bmc_ip":"10.0.0.1","username":"USERID","password":"PASSW0RD"}`
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		token       string
+		contentType string
+		want        int
+	}{
+		{"cross-site form", "POST", "/api/consoles", "", "text/plain", http.StatusForbidden},
+		{"wrong token", "POST", "/api/consoles", "guess", "application/json", http.StatusForbidden},
+		{"form content type", "POST", "/api/consoles", "s3cret", "text/plain", http.StatusUnsupportedMediaType},
+		{"register", "POST", "/api/consoles", "s3cret", "application/json; charset=utf-8", http.StatusCreated},
+		{"remove without token", "DELETE", "/api/consoles/lab1", "", "", http.StatusForbidden},
+		{"remove", "DELETE", "/api/consoles/lab1", "s3cret", "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(entry))
+		if tt.token != "" {
+			req.Header.Set(pageTokenHeader, tt.token)
+		}
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		rec := httptest.NewRecorder()
+		m.Handler().ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if registered := len(m.Entries()) == 1; registered != (tt.name == "register" || tt.name == "remove without token") {
+			t.Errorf("%s: entries = %+v", tt.name, m.Entries())
+		}
+	}
+
+	if generated, err := NewManager(ManagerConfig{}); err != nil || len(generated.APIToken()) < 32 {
+		t.Errorf("generated API token = %q, %v", generated.APIToken(), err)
+	}
+}
+
+func TestManagerListenHost(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer xcc.Close()
+	m, err := NewManager(ManagerConfig{ListenHost: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Shutdown(context.Background())
+	registerFake(t, m, "lab1", xcc)
+
+	c, err := m.Open("lab1")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if host, _, _ := net.SplitHostPort(c.server.Addr); host != "127.0.0.1" {
+		t.Errorf("console server listens on %q, want the manager's host", c.server.Addr)
+	}
+}
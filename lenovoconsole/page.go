@@ -0,0 +1,103 @@
+package lenovoconsole
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Built-in page layouts accepted in TemplateConfig.Layout
+const (
+	LayoutToolbar = "toolbar" // toolbar with reconnect, certificate help, paste and fullscreen (default)
+	LayoutEmbed   = "embed"   // console canvas only, for embedding in another page
+)
+
+// DefaultTemplateName is the file read from TemplateConfig.FS when Name is empty
+const DefaultTemplateName = "console.html"
+
+// TemplateConfig selects the HTML page served for a console.
+// A custom template is an html/template document executed with TemplateData.
+// It is parsed together with the built-in blocks, of which it must render
+// {{template "viewer" .}} and must contain a <canvas id="kvmCanvas">.
+// The "styles", "certInstructions", "sessionPanel" and "pastePanel" blocks, and elements
+// with the ids "status", "certInstructions", "pastePanel" and "toolbar", are optional.
+type TemplateConfig struct {
+	Layout string // Built-in layout: "toolbar" (default) or "embed"
+	File   string // Path to a custom template file, overrides Layout
+	FS     fs.FS  // File system holding a custom template, overrides Layout and File
+	Name   string // Template file name within FS (default: console.html)
+}
+
+// TemplateData is the data contract available to console page templates
+type TemplateData struct {
+	Title       string // Page title, e.g. "Lenovo XCC Remote Console - 10.0.0.1"
+	BMCIP       string // BMC address, normalized by ParseBMCAddress
+	BMCHost     string // BMC host as written in a URL, e.g. "[fd00::1]" for IPv6
+	BMCOrigin   string // HTTPS origin of the BMC, e.g. "https://[fd00::1]:8443"
+	RPPort      int    // Remote Presence port
+	BMCUsername string // BMC user the viewer logs in as
+	BMCPassword string // BMC password, only safe to render inside the "viewer" block
+
+	Viewer       ViewerOptions // RPViewer settings, rendered as a JSON object in the "viewer" block
+	Scripts      []string      // Viewer SDK script paths on the BMC, from the console's Provider
+	ViewerScript template.JS   // Provider script that creates the viewer, rendered in the "viewer" block
+	PageToken    string        // Secret the "viewer" block sends with the page's POST requests
+}
+
+// pageTemplate loads and parses the configured page template
+func (c *Console) pageTemplate() (*template.Template, error) {
+	tc := c.config.Template
+
+	var source, name string
+	switch {
+	case tc.FS != nil:
+		name = tc.Name
+		if name == "" {
+			name = DefaultTemplateName
+		}
+		b, err := fs.ReadFile(tc.FS, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %v", name, err)
+		}
+		source = string(b)
+	case tc.File != "":
+		name = tc.File
+		b, err := os.ReadFile(tc.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %v", name, err)
+		}
+		source = string(b)
+	default:
+		switch tc.Layout {
+		case "", LayoutToolbar:
+			name, source = LayoutToolbar, toolbarLayout
+		case LayoutEmbed:
+			name, source = LayoutEmbed, embedLayout
+		default:
+			return nil, fmt.Errorf("unknown layout %q", tc.Layout)
+		}
+	}
+
+	tmpl, err := template.New("partials").Parse(consolePartials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %v", err)
+	}
+	page, err := tmpl.New(name).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", name, err)
+	}
+	return page, nil
+}
+
+// validatePage checks rendered HTML against the template data contract
+func validatePage(html string) error {
+	if !strings.Contains(html, "function initializeViewer()") {
+		return fmt.Errorf(`template does not render the viewer script; add {{template "viewer" .}}`)
+	}
+	if !strings.Contains(html, `id="kvmCanvas"`) {
+		return fmt.Errorf(`template has no <canvas id="kvmCanvas"> element`)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package lenovoconsole
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+// runConsolePage starts a console against xcc, runs its page in a headless engine and
+// returns the events the console emitted
+func runConsolePage(t *testing.T, xcc *lenovoconsoletest.Server, config ConsoleConfig) []Event {
+	t.Helper()
+	return runConsolePageAt(t, xcc, config, "/")
+}
+
+// runConsolePageAt is runConsolePage for a page path with options, e.g. "/?thumbnail=2"
+func runConsolePageAt(t *testing.T, xcc *lenovoconsoletest.Server, config ConsoleConfig, path string) []Event {
+	t.Helper()
+	if _, _, err := lenovoconsoletest.FindEngine(); errors.Is(err, lenovoconsoletest.ErrNoEngine) {
+		t.Skip(err)
+	}
+
+	config.BMCIP = xcc.Addr
+	config.Username = lenovoconsoletest.DefaultUsername
+	config.Password = lenovoconsoletest.DefaultPassword
+	c := NewConsole(config)
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	events, cancel := c.Subscribe()
+	defer cancel()
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer c.Stop()
+
+	ctx, stop := context.WithTimeout(context.Background(), 30*time.Second)
+	defer stop()
+	engine, err := lenovoconsoletest.RunPage(ctx, strings.TrimSuffix(c.GetURL(), "/")+path)
+	if err != nil {
+		t.Fatalf("RunPage: %v", err)
+	}
+	if !xcc.ViewerDone() {
+		t.Fatalf("%s: stub viewer did not finish", engine)
+	}
+
+	var got []Event
+	for {
+		select {
+		case e := <-events:
+			got = append(got, e)
+		default:
+			return got
+		}
+	}
+}
+
+func findEvent(events []Event, eventType EventType) (Event, bool) {
+	for _, e := range events {
+		if e.Type == eventType {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+func TestPageConfiguresViewer(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{RPPort: 3911})
+	defer xcc.Close()
+
+	viewer := DefaultViewerOptions()
+	viewer.KeyboardLanguage = "de"
+	viewer.WebSocketTimeout = 42
+	events := runConsolePage(t, xcc, ConsoleConfig{Viewer: &viewer})
+
+	call, ok := xcc.ViewerCall("setRPServerConfiguration")
+	if !ok || call.StringArg(0) != xcc.Host() || call.IntArg(1) != 3911 {
+		t.Errorf("setRPServerConfiguration = %+v, want %s, 3911", call.Args, xcc.Host())
+	}
+	call, ok = xcc.ViewerCall("setRPCredential")
+	if !ok || call.StringArg(0) != lenovoconsoletest.DefaultUsername || call.StringArg(1) != lenovoconsoletest.DefaultPassword {
+		t.Errorf("setRPCredential = %+v", call.Args)
+	}
+	if call, _ := xcc.ViewerCall("setRPKeyboardLanguage"); call.StringArg(0) != "de" {
+		t.Errorf("setRPKeyboardLanguage = %+v, want de", call.Args)
+	}
+	if call, _ := xcc.ViewerCall("setRPWebSocketTimeout"); call.IntArg(0) != 42 {
+		t.Errorf("setRPWebSocketTimeout = %+v, want 42", call.Args)
+	}
+	if _, ok := xcc.ViewerCall("connectRPViewer"); !ok {
+		t.Error("page did not connect the viewer")
+	}
+
+	e, ok := findEvent(events, EventLoginResult)
+	if !ok || e.Code != 0 {
+		t.Errorf("login event = %+v, want code 0", e)
+	}
+}
+
+func TestPageIPv6(t *testing.T) {
+	if l, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	} else {
+		l.Close()
+	}
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{IPv6: true, RPPort: 3911})
+	defer xcc.Close()
+	runConsolePage(t, xcc, ConsoleConfig{})
+
+	// The viewer builds its WebSocket URL from the host, so IPv6 must be in brackets
+	call, ok := xcc.ViewerCall("setRPServerConfiguration")
+	if !ok || call.StringArg(0) != "[::1]" || call.IntArg(1) != 3911 {
+		t.Errorf("setRPServerConfiguration = %+v, want [::1], 3911", call.Args)
+	}
+}
+
+func TestPageReportsViewerResults(t *testing.T) {
+	tests := []struct {
+		name      string
+		behavior  lenovoconsoletest.ViewerBehavior
+		eventType EventType
+		code      int
+		message   string
+	}{
+		{"invalid password", lenovoconsoletest.ViewerBehavior{LoginResult: 3}, EventLoginResult, 3, "Invalid password"},
+		{"certificate not verified", lenovoconsoletest.ViewerBehavior{LoginResult: 102}, EventLoginResult, 102, "Certificate not verified"},
+		{
+			"reboot",
+			lenovoconsoletest.ViewerBehavior{Terminate: true, TerminateReason: 3, TerminateAfter: 50 * time.Millisecond},
+			EventSessionTerminated, 3, "Reboot",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{Viewer: tt.behavior})
+			defer xcc.Close()
+
+			events := runConsolePage(t, xcc, ConsoleConfig{})
+			e, ok := findEvent(events, tt.eventType)
+			if !ok {
+				t.Fatalf("no %s event in %+v", tt.eventType, events)
+			}
+			if e.Code != tt.code || e.Message != tt.message {
+				t.Errorf("%s event = %d %q, want %d %q", tt.eventType, e.Code, e.Message, tt.code, tt.message)
+			}
+		})
+	}
+}
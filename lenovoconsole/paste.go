@@ -0,0 +1,178 @@
+package lenovoconsole
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Paste defaults and limits
+const (
+	defaultPasteRate    = 20        // key presses per second
+	defaultPasteConfirm = 200       // characters before the page asks for confirmation
+	maxPasteLength      = 16 * 1024 // characters accepted in one paste
+)
+
+// Paste types text on the remote host. The text is translated into key presses for the
+// viewer's keyboard language and typed by one open console page at ConsoleConfig.PasteRate.
+// It returns once the page has received the keys, not when typing has finished.
+func (c *Console) Paste(text string) error {
+	keys, err := c.pasteKeystrokes(text)
+	if err != nil {
+		return err
+	}
+	return c.typeKeys(AuditPaste, utf8.RuneCountInString(text), keys, "")
+}
+
+// SendKeys types a KeySequence such as "{F1}" or "root{Enter}" on the remote host, in
+// the viewer's keyboard language, through one open console page like Paste
+func (c *Console) SendKeys(sequence string) error {
+	keys, err := c.keySequence(sequence)
+	if err != nil {
+		return err
+	}
+	return c.typeKeys(AuditSendKeys, len(keys), keys, "")
+}
+
+// keySequence translates a key sequence for the console's keyboard language
+func (c *Console) keySequence(sequence string) ([]Keystroke, error) {
+	viewer, err := c.viewerOptions()
+	if err != nil {
+		return nil, err
+	}
+	return KeySequence(sequence, viewer.KeyboardLanguage)
+}
+
+// typeKeys has one open console page type keys at the paste rate, and audits the input
+// as action with its length. A non-empty id asks the page to confirm the typing, see
+// typeKeysAndWait.
+func (c *Console) typeKeys(action AuditAction, length int, keys []Keystroke, id string) error {
+	var err error
+	if !c.sendCommandToOne(pageCommand{Name: "paste", Keys: keys, Rate: c.pasteRate(), ID: id}) {
+		err = fmt.Errorf("no console page is open to type into")
+	}
+	c.audit(AuditRecord{Action: action, Length: length, Result: auditResult(err)})
+	return err
+}
+
+// typeKeysAndWait is typeKeys that returns once the page has typed the keys, or with
+// the reason it could not
+func (c *Console) typeKeysAndWait(ctx context.Context, action AuditAction, length int, keys []Keystroke) error {
+	ch := make(chan error, 1)
+	c.mu.Lock()
+	c.typingSeq++
+	id := strconv.Itoa(c.typingSeq)
+	c.typing[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.typing, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.typeKeys(action, length, keys, id); err != nil {
+		return err
+	}
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("typing not confirmed: %v", ctx.Err())
+	case <-c.stopCh:
+		return fmt.Errorf("console stopped")
+	}
+}
+
+// pasteKeystrokes validates and translates text for the console's keyboard language
+func (c *Console) pasteKeystrokes(text string) ([]Keystroke, error) {
+	if n := utf8.RuneCountInString(text); n > maxPasteLength {
+		return nil, fmt.Errorf("paste of %d characters exceeds the limit of %d", n, maxPasteLength)
+	}
+	viewer, err := c.viewerOptions()
+	if err != nil {
+		return nil, err
+	}
+	return Keystrokes(text, viewer.KeyboardLanguage)
+}
+
+func (c *Console) pasteRate() int {
+	if c.config.PasteRate > 0 {
+		return c.config.PasteRate
+	}
+	return defaultPasteRate
+}
+
+func (c *Console) pasteConfirm() int {
+	if c.config.PasteConfirm > 0 {
+		return c.config.PasteConfirm
+	}
+	return defaultPasteConfirm
+}
+
+// pasteHandler translates text from the page's paste box into key presses for the page
+// to type. Pastes longer than ConsoleConfig.PasteConfirm are only translated once the
+// page confirms them.
+func (c *Console) pasteHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.pagePost(w, r, "application/json") {
+		return
+	}
+	var req struct {
+		Text      string `json:"text"`
+		Confirmed bool   `json:"confirmed"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*maxPasteLength+1024)).Decode(&req); err != nil {
+		http.Error(w, "Invalid paste request", http.StatusBadRequest)
+		return
+	}
+
+	var resp struct {
+		Confirm bool        `json:"confirm,omitempty"`
+		Length  int         `json:"length"`
+		Keys    []Keystroke `json:"keys,omitempty"`
+		Rate    int         `json:"rate,omitempty"`
+	}
+	resp.Length = utf8.RuneCountInString(req.Text)
+	keys, err := c.pasteKeystrokes(req.Text)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if resp.Length > c.pasteConfirm() && !req.Confirmed {
+		resp.Confirm = true
+	} else {
+		resp.Keys, resp.Rate = keys, c.pasteRate()
+		c.auditRequest(r, AuditRecord{Action: AuditPaste, Length: resp.Length, Result: "ok"})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// typedHandler receives the page's confirmation that it typed the keys of a
+// typeKeysAndWait request, or an error query parameter if it could not
+func (c *Console) typedHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.pagePost(w, r, "") {
+		return
+	}
+	var err error
+	if msg := r.URL.Query().Get("error"); msg != "" {
+		err = fmt.Errorf("page could not type the keys: %s", msg)
+	}
+
+	c.mu.Lock()
+	ch, ok := c.typing[r.URL.Query().Get("id")]
+	c.mu.Unlock()
+	if !ok {
+		http.Error(w, "Unknown typing request", http.StatusNotFound)
+		return
+	}
+	select {
+	case ch <- err:
+	default: // already answered
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,278 @@
+package lenovoconsole
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeystrokes(t *testing.T) {
+	tests := []struct {
+		text, language string
+		want           string // code with + for Shift and ^ for AltGr
+	}{
+		{"aZ1!", "en", "KeyA KeyZ+ Digit1 Digit1+"},
+		{"a b\r\nc\td", "en-US", "KeyA Space KeyB Enter KeyC Tab KeyD"},
+		{"zy@", "de", "KeyY KeyZ KeyQ^"},
+		{"^", "de", "Backquote Space"},
+		{"a1é", "fr", "KeyQ Digit1+ Digit2"},
+	}
+	for _, tt := range tests {
+		keys, err := Keystrokes(tt.text, tt.language)
+		if err != nil {
+			t.Errorf("Keystrokes(%q, %s): %v", tt.text, tt.language, err)
+			continue
+		}
+		var got []string
+		for _, k := range keys {
+			s := k.Code
+			if k.Shift {
+				s += "+"
+			}
+			if k.AltGr {
+				s += "^"
+			}
+			got = append(got, s)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("Keystrokes(%q, %s) = %s, want %s", tt.text, tt.language, strings.Join(got, " "), tt.want)
+		}
+	}
+
+	if _, err := Keystrokes("ok ü", "en"); err == nil || !strings.Contains(err.Error(), `'ü'`) {
+		t.Errorf("untypeable character: err = %v", err)
+	}
+	if _, err := Keystrokes("x", "tlh"); err == nil {
+		t.Error("unknown layout accepted")
+	}
+}
+
+func TestKeySequence(t *testing.T) {
+	keys, err := KeySequence("{F1}ab{{{Ctrl+Alt+Del}{down}{Shift+Tab}{ctrl+c}", "en")
+	if err != nil {
+		t.Fatalf("KeySequence: %v", err)
+	}
+	var got []string
+	for _, k := range keys {
+		s := k.Code
+		if k.Ctrl {
+			s = "Ctrl+" + s
+		}
+		if k.Alt {
+			s = "Alt+" + s
+		}
+		if k.Shift {
+			s = "Shift+" + s
+		}
+		got = append(got, s)
+	}
+	want := "F1 KeyA KeyB Shift+BracketLeft Alt+Ctrl+Delete ArrowDown Shift+Tab Ctrl+KeyC"
+	if strings.Join(got, " ") != want {
+		t.Errorf("KeySequence = %s, want %s", strings.Join(got, " "), want)
+	}
+	if keys[0].KeyCode != 112 || keys[0].Key != "F1" {
+		t.Errorf("F1 = %+v", keys[0])
+	}
+
+	for _, bad := range []string{"{F1", "{Hyper+A}", "{Launch}"} {
+		if _, err := KeySequence(bad, "en"); err == nil {
+			t.Errorf("KeySequence(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestPaste(t *testing.T) {
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", PasteRate: 5})
+	if err := c.Paste("root"); err == nil {
+		t.Error("Paste succeeded without an open page")
+	}
+
+	page := make(chan pageCommand, 1)
+	c.pages[page] = false
+	if err := c.Paste("root\n"); err != nil {
+		t.Fatalf("Paste: %v", err)
+	}
+	cmd := <-page
+	if cmd.Name != "paste" || cmd.Rate != 5 || len(cmd.Keys) != 5 || cmd.Keys[4].Code != "Enter" {
+		t.Errorf("paste command = %+v", cmd)
+	}
+	if err := c.Paste(strings.Repeat("x", maxPasteLength+1)); err == nil {
+		t.Error("oversized paste accepted")
+	}
+}
+
+func TestTypeKeysAndWait(t *testing.T) {
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1"})
+	keys := []Keystroke{{Key: "a", Code: "KeyA"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.typeKeysAndWait(ctx, AuditSendKeys, 1, keys); err == nil {
+		t.Error("typeKeysAndWait succeeded without an open page")
+	}
+
+	page := newFakeScreenPage(c, nil)
+	defer page.close()
+	if err := c.typeKeysAndWait(ctx, AuditSendKeys, 1, keys); err != nil {
+		t.Fatalf("typeKeysAndWait: %v", err)
+	}
+	page.mu.Lock()
+	page.typeError = "The viewer is not connected"
+	page.mu.Unlock()
+	if err := c.typeKeysAndWait(ctx, AuditSendKeys, 1, keys); err == nil || !strings.Contains(err.Error(), "The viewer is not connected") {
+		t.Errorf("typeKeysAndWait error = %v", err)
+	}
+
+	// A page that never confirms leaves the wait to the context
+	silent := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1"})
+	commands := make(chan pageCommand, 1)
+	silent.pages[commands] = false
+	short, cancelShort := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelShort()
+	if err := silent.typeKeysAndWait(short, AuditSendKeys, 1, keys); err == nil || !strings.Contains(err.Error(), "not confirmed") {
+		t.Errorf("typeKeysAndWait error = %v for a silent page", err)
+	}
+	if cmd := <-commands; cmd.ID == "" {
+		t.Errorf("paste command = %+v, want a request id", cmd)
+	}
+
+	rec := httptest.NewRecorder()
+	c.typedHandler(rec, pageRequest(c, "/api/typed?id=unknown", "", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("confirmation of an unknown request: status %d", rec.Code)
+	}
+}
+
+func TestInputSkipsThumbnails(t *testing.T) {
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1"})
+	server := httptest.NewServer(http.HandlerFunc(c.controlHandler))
+	t.Cleanup(server.Close) // after the streams below are closed
+
+	// subscribe opens a control stream and returns the names of the events it receives
+	subscribe := func(query string) <-chan string {
+		resp, err := http.Get(server.URL + "/api/control" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		names := make(chan string, 64)
+		go func() {
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+					names <- name
+				}
+			}
+		}()
+		return names
+	}
+	thumbnail := subscribe("?thumbnail")
+	normal := subscribe("")
+	for deadline := time.Now().Add(5 * time.Second); c.ConnectedPages() < 2; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("control streams did not connect")
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := c.Paste("ls"); err != nil {
+			t.Fatalf("Paste: %v", err)
+		}
+		if name := <-normal; name != "paste" {
+			t.Fatalf("normal page got %q", name)
+		}
+	}
+	// Commands for every page still reach the thumbnail, and nothing came before them
+	c.sendCommand(pageCommand{Name: "status", Message: "hello"})
+	select {
+	case name := <-thumbnail:
+		if name != "status" {
+			t.Errorf("thumbnail got %q before the status command", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("thumbnail did not receive the status command")
+	}
+}
+
+func TestPasteHandler(t *testing.T) {
+	c, records := auditedConsole(ConsoleConfig{BMCIP: "10.0.0.1", PasteConfirm: 4})
+	post := func(body string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		c.pasteHandler(rec, pageRequest(c, "/api/paste", "application/json", strings.NewReader(body)))
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	if code, resp := post(`{"text":"ls"}`); code != http.StatusOK || len(resp["keys"].([]interface{})) != 2 || resp["rate"] != float64(defaultPasteRate) {
+		t.Errorf("short paste: %d %v", code, resp)
+	}
+	if code, resp := post(`{"text":"reboot"}`); code != http.StatusOK || resp["confirm"] != true || resp["keys"] != nil {
+		t.Errorf("large paste without confirmation: %d %v", code, resp)
+	}
+	if code, resp := post(`{"text":"reboot","confirmed":true}`); code != http.StatusOK || len(resp["keys"].([]interface{})) != 6 {
+		t.Errorf("confirmed paste: %d %v", code, resp)
+	}
+	if code, _ := post(`{"text":"naïve"}`); code != http.StatusBadRequest {
+		t.Errorf("untypeable paste: status %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	c.pasteHandler(rec, httptest.NewRequest("GET", "/api/paste", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	c.pasteHandler(rec, httptest.NewRequest("POST", "/api/paste", strings.NewReader(`{"text":"ls"}`)))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST without the page token: status %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	c.pasteHandler(rec, pageRequest(c, "/api/paste", "text/plain", strings.NewReader(`{"text":"ls"}`)))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("POST as text/plain: status %d", rec.Code)
+	}
+
+	// Only the pastes handed to the page are audited, with their length but not their text
+	got := records()
+	if len(got) != 2 || got[0].Action != AuditPaste || got[0].Length != 2 || got[1].Length != 6 || got[0].Client == "" {
+		t.Errorf("audit records = %+v", got)
+	}
+}
+
+func TestPasteAudit(t *testing.T) {
+	c, records := auditedConsole(ConsoleConfig{BMCIP: "10.0.0.1"})
+	if err := c.Paste("secret"); err == nil {
+		t.Error("Paste succeeded without an open page")
+	}
+	commands := make(chan pageCommand, 2)
+	c.pages[commands] = false
+	if err := c.Paste("hunter2"); err != nil {
+		t.Fatalf("Paste: %v", err)
+	}
+	if err := c.SendKeys("root{Enter}"); err != nil {
+		t.Fatalf("SendKeys: %v", err)
+	}
+
+	want := []AuditRecord{
+		{Action: AuditPaste, Length: 6, Result: "failed: no console page is open to type into"},
+		{Action: AuditPaste, Length: 7, Result: "ok"},
+		{Action: AuditSendKeys, Length: 5, Result: "ok"},
+	}
+	got := records()
+	if len(got) != len(want) {
+		t.Fatalf("audit records = %+v", got)
+	}
+	for i, rec := range got {
+		if rec.Action != want[i].Action || rec.Length != want[i].Length || rec.Result != want[i].Result {
+			t.Errorf("record %d = %+v, want %+v", i, rec, want[i])
+		}
+		if line, _ := json.Marshal(rec); strings.Contains(string(line), "secret") || strings.Contains(string(line), "hunter2") {
+			t.Errorf("record %d holds the typed text: %s", i, line)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package lenovoconsole
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// browserHandoffGrace is how soon after launch a browser may exit without being treated as closed.
+// Browsers that find an existing instance forward the URL to it and exit immediately.
+const browserHandoffGrace = 3 * time.Second
+
+// browserTerminateTimeout bounds how long Stop waits for owned browsers to exit
+const browserTerminateTimeout = 5 * time.Second
+
+// browserProcess is a browser launched and owned by a Console
+type browserProcess struct {
+	cmd       *exec.Cmd
+	started   time.Time
+	done      chan struct{}
+	handedOff bool // exited within browserHandoffGrace, window belongs to another instance
+}
+
+// ownsBrowser reports whether cmd runs a browser on the console's isolated profile.
+// Only such a browser belongs to the console alone: system openers, and browsers on the
+// shared profile, may hand the window to a process that other consoles use as well.
+func (c *Console) ownsBrowser(cmd *exec.Cmd) bool {
+	c.mu.Lock()
+	profile := c.profileDir
+	c.mu.Unlock()
+	if profile == "" {
+		return false
+	}
+	for _, arg := range cmd.Args[1:] {
+		if strings.Contains(arg, profile) {
+			return true
+		}
+	}
+	return false
+}
+
+// launchBrowser starts cmd and, if the console owns the resulting process, tracks it
+func (c *Console) launchBrowser(cmd *exec.Cmd) error {
+	owned := c.ownsBrowser(cmd)
+	if owned {
+		setProcessGroup(cmd)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	p := &browserProcess{cmd: cmd, started: time.Now(), done: make(chan struct{})}
+	if !owned {
+		go func() {
+			cmd.Wait()
+			close(p.done)
+		}()
+		return nil
+	}
+
+	c.mu.Lock()
+	c.browsers = append(c.browsers, p)
+	c.mu.Unlock()
+
+	go c.watchBrowser(p)
+	return nil
+}
+
+// watchBrowser waits for an owned browser to exit and reports when the last one closes
+func (c *Console) watchBrowser(p *browserProcess) {
+	err := p.cmd.Wait()
+	close(p.done)
+
+	c.mu.Lock()
+	if time.Since(p.started) < browserHandoffGrace && err == nil {
+		p.handedOff = true
+	}
+	stopping := c.stopping
+	running := c.runningBrowsersLocked()
+	c.mu.Unlock()
+
+	if stopping || running > 0 || p.handedOff {
+		return
+	}
+
+	fmt.Printf("Browser for BMC %s was closed\n", c.config.BMCIP)
+
+	// Stop before signalling so that receivers observe a fully stopped console
+	if c.config.StopOnBrowserClose {
+		if err := c.Stop(); err != nil {
+			fmt.Printf("Failed to stop console for BMC %s: %v\n", c.config.BMCIP, err)
+		}
+	}
+	c.closeOnce.Do(func() { close(c.browserClosed) })
+}
+
+// runningBrowsersLocked counts owned browsers that have not exited. c.mu must be held.
+func (c *Console) runningBrowsersLocked() int {
+	n := 0
+	for _, b := range c.browsers {
+		select {
+		case <-b.done:
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// terminateBrowsers stops every browser process this console launched and still owns
+func (c *Console) terminateBrowsers() error {
+	c.mu.Lock()
+	browsers := c.browsers
+	c.browsers = nil
+	c.mu.Unlock()
+
+	var firstErr error
+	for _, b := range browsers {
+		select {
+		case <-b.done:
+			continue
+		default:
+		}
+
+		if err := terminateProcess(b.cmd); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to terminate browser (pid %d): %v", b.cmd.Process.Pid, err)
+		}
+
+		select {
+		case <-b.done:
+		case <-time.After(browserTerminateTimeout):
+			b.cmd.Process.Kill()
+			<-b.done
+		}
+	}
+	return firstErr
+}
+
+// BrowserClosed returns a channel that is closed when the user closes the browser
+// launched by OpenInBrowser. Only browsers on an isolated profile are watched, so it
+// never fires without BrowserConfig.IsolatedProfile, for browsers started through the
+// system default opener, or for browsers terminated by Stop.
+func (c *Console) BrowserClosed() <-chan struct{} {
+	return c.browserClosed
+}
@@ -0,0 +1,96 @@
+//go:build !windows
+
+package lenovoconsole
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// launchFakeBrowser starts a long-running stand-in for a browser through the console's
+// custom browser command, with the profile in its arguments
+func launchFakeBrowser(t *testing.T, c *Console) *exec.Cmd {
+	t.Helper()
+	cmd, err := c.getBrowserCommand("http://localhost:1")
+	if err != nil {
+		t.Fatalf("getBrowserCommand: %v", err)
+	}
+	if err := c.launchBrowser(cmd); err != nil {
+		t.Fatalf("launchBrowser: %v", err)
+	}
+	t.Cleanup(func() { cmd.Process.Kill() })
+	return cmd
+}
+
+// processRunning reports whether the process with pid still exists
+func processRunning(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
+
+func TestStopTerminatesOwnedBrowsers(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	tests := []struct {
+		name     string
+		isolated bool
+		owned    bool
+	}{
+		{"isolated profile", true, true},
+		// Other consoles' windows may be open in a browser on the shared profile
+		{"shared profile", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConsole(ConsoleConfig{
+				BMCIP:   "10.0.0.1",
+				Browser: BrowserConfig{Command: `sh -c "exec sleep 30" {profile}`, IsolatedProfile: tt.isolated},
+			})
+			cmd := launchFakeBrowser(t, c)
+
+			c.mu.Lock()
+			tracked := len(c.browsers)
+			c.mu.Unlock()
+			if owned := tracked == 1; owned != tt.owned {
+				t.Errorf("tracked browsers = %d, want owned %v", tracked, tt.owned)
+			}
+
+			if err := c.Stop(); err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
+			if running := processRunning(cmd.Process.Pid); running == tt.owned {
+				t.Errorf("browser running after Stop = %v, want %v", running, !tt.owned)
+			}
+		})
+	}
+}
+
+func TestBrowserClosed(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	c := NewConsole(ConsoleConfig{
+		BMCIP:   "10.0.0.1",
+		Browser: BrowserConfig{Command: `sh -c "exec sleep 30" {profile}`, IsolatedProfile: true},
+	})
+	defer c.Stop()
+	cmd := launchFakeBrowser(t, c)
+
+	// Browsers that exit right after launch have handed the window to another instance
+	time.Sleep(browserHandoffGrace + 100*time.Millisecond)
+	select {
+	case <-c.BrowserClosed():
+		t.Fatal("BrowserClosed fired while the browser runs")
+	default:
+	}
+
+	// The user closes the window
+	cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-c.BrowserClosed():
+	case <-time.After(5 * time.Second):
+		t.Fatal("BrowserClosed did not fire")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package lenovoconsole
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the browser in its own process group so its helper processes can be signalled together
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminateProcess asks the browser's process group to exit
+func terminateProcess(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
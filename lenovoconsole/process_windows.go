@@ -0,0 +1,16 @@
+//go:build windows
+
+package lenovoconsole
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup is a no-op on Windows; taskkill /T walks the process tree instead
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcess ends the browser and all of its child processes
+func terminateProcess(cmd *exec.Cmd) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
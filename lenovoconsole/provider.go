@@ -0,0 +1,186 @@
+package lenovoconsole
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Provider adapts the console to a family of Lenovo BMCs. It knows how to discover the
+// remote presence port, where the viewer SDK lives, how the console page creates the
+// viewer, what the page is called and how to change the server's power state.
+type Provider interface {
+	// Name is the short identifier used in configuration, e.g. "xcc"
+	Name() string
+	// Title is the product name shown in the console page title
+	Title() string
+	// RPPort discovers the remote presence port, reporting an error when the BMC does not answer
+	RPPort(bmcIP, username, password string) (int, error)
+	// SDKScripts lists the URL paths of the viewer SDK scripts on the BMC, in load order
+	SDKScripts() []string
+	// ViewerScript is JavaScript rendered into the console page's "viewer" block. It
+	// defines createViewer(canvasId, config, viewerOptions, callbacks), called once the
+	// SDK scripts have loaded, which returns a viewer with the RPViewer connect,
+	// disconnect and resize methods and calls callbacks.error, login, uiInit, exit,
+	// resolution and terminated; sdkScriptFallback(path), which returns another path
+	// to try when an SDK script fails to load, or null; and sendKeystroke(viewer,
+	// keystroke), which types a Keystroke (as JSON) through the viewer's keyboard API
+	// and returns false if it has none.
+	ViewerScript() string
+	// ProxyPaths lists the paths the local server proxies to the BMC, such as web workers
+	// the viewer loads relative to the console page
+	ProxyPaths() []string
+	// Power performs a power action on the managed server
+	Power(bmcIP, username, password string, action PowerAction) error
+	// PowerState reports the managed server's power state, e.g. "On" or "Off"
+	PowerState(bmcIP, username, password string) (string, error)
+}
+
+// Names of the built-in providers
+const (
+	ProviderXCC  = "xcc"  // Lenovo XClarity Controller (ThinkSystem)
+	ProviderIMM2 = "imm2" // Integrated Management Module II (System x)
+)
+
+// providers holds the built-in providers by name
+var providers = map[string]Provider{
+	ProviderXCC:  xccProvider{},
+	ProviderIMM2: imm2Provider{},
+}
+
+// ProviderByName returns a built-in provider. An empty name selects the XCC provider.
+func ProviderByName(name string) (Provider, error) {
+	if name == "" {
+		name = ProviderXCC
+	}
+	p, ok := providers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (available: %s)", name, strings.Join(ProviderNames(), ", "))
+	}
+	return p, nil
+}
+
+// ProviderNames lists the built-in providers
+func ProviderNames() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// provider returns the console's provider, defaulting to XCC
+func (c *Console) provider() Provider {
+	return providerFor(c.config.Provider, c.dialer)
+}
+
+// dialingProvider is implemented by the built-in providers, which reach the BMC through
+// the Dialer for ConsoleConfig.Proxy
+type dialingProvider interface {
+	withDialer(d Dialer) Provider
+}
+
+// providerFor returns p, or the XCC provider if nil, connecting through d where it can.
+// Custom providers make their own connections.
+func providerFor(p Provider, d Dialer) Provider {
+	if p == nil {
+		p = xccProvider{}
+	}
+	if dp, ok := p.(dialingProvider); ok {
+		return dp.withDialer(d)
+	}
+	return p
+}
+
+// PowerAction is a Redfish ComputerSystem.Reset type
+type PowerAction string
+
+// Power actions accepted by Provider.Power
+const (
+	PowerOn       PowerAction = "On"               // Power on
+	PowerOff      PowerAction = "ForceOff"         // Cut power immediately
+	PowerShutdown PowerAction = "GracefulShutdown" // Ask the OS to shut down
+	PowerRestart  PowerAction = "GracefulRestart"  // Ask the OS to restart
+	PowerReset    PowerAction = "ForceRestart"     // Reset immediately
+	PowerCycle    PowerAction = "PowerCycle"       // Power off, then on again
+)
+
+// PowerActions lists the supported power actions
+var PowerActions = []PowerAction{PowerOn, PowerOff, PowerShutdown, PowerRestart, PowerReset, PowerCycle}
+
+// ParsePowerAction accepts a PowerAction value or a short alias such as "off" or "cycle"
+func ParsePowerAction(s string) (PowerAction, error) {
+	aliases := map[string]PowerAction{
+		"on":       PowerOn,
+		"off":      PowerOff,
+		"shutdown": PowerShutdown,
+		"restart":  PowerRestart,
+		"reboot":   PowerRestart,
+		"reset":    PowerReset,
+		"cycle":    PowerCycle,
+	}
+	if a, ok := aliases[strings.ToLower(s)]; ok {
+		return a, nil
+	}
+	for _, a := range PowerActions {
+		if strings.EqualFold(s, string(a)) {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unknown power action %q", s)
+}
+
+// Power performs a power action on the console's server
+func (c *Console) Power(action PowerAction) error {
+	err := c.provider().Power(c.config.BMCIP, c.config.Username, c.config.Password, action)
+	c.audit(AuditRecord{Action: AuditPower, BMCUser: c.config.Username, Detail: string(action), Result: auditResult(err)})
+	return err
+}
+
+// PowerState reports the power state of the console's server
+func (c *Console) PowerState() (string, error) {
+	return c.provider().PowerState(c.config.BMCIP, c.config.Username, c.config.Password)
+}
+
+// redfishSystem returns the path of the first ComputerSystem on the BMC
+func redfishSystem(rf *redfishClient) (string, error) {
+	systems, err := rf.members("/redfish/v1/Systems")
+	if err != nil {
+		return "", err
+	}
+	if len(systems) == 0 {
+		return "", fmt.Errorf("BMC reports no computer systems")
+	}
+	return systems[0], nil
+}
+
+// redfishPower performs a ComputerSystem.Reset action
+func redfishPower(d Dialer, bmcIP, username, password string, action PowerAction) error {
+	rf := newRedfishClient(d, bmcIP, username, password)
+	system, err := redfishSystem(rf)
+	if err != nil {
+		return err
+	}
+	body := map[string]string{"ResetType": string(action)}
+	if err := rf.post(system+"/Actions/ComputerSystem.Reset", body); err != nil {
+		return fmt.Errorf("power %s failed: %v", action, err)
+	}
+	return nil
+}
+
+// redfishPowerState reads the PowerState of the first ComputerSystem
+func redfishPowerState(d Dialer, bmcIP, username, password string) (string, error) {
+	rf := newRedfishClient(d, bmcIP, username, password)
+	system, err := redfishSystem(rf)
+	if err != nil {
+		return "", err
+	}
+	var s struct {
+		PowerState string `json:"PowerState"`
+	}
+	if err := rf.get(system, &s); err != nil {
+		return "", err
+	}
+	return s.PowerState, nil
+}
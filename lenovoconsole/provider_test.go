@@ -0,0 +1,195 @@
+package lenovoconsole
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+func TestProviderByName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"", ProviderXCC, false},
+		{"xcc", ProviderXCC, false},
+		{"IMM2", ProviderIMM2, false},
+		{"ilo", "", true},
+	}
+	for _, tt := range tests {
+		p, err := ProviderByName(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ProviderByName(%q) succeeded, want error", tt.name)
+			}
+			continue
+		}
+		if err != nil || p.Name() != tt.want {
+			t.Errorf("ProviderByName(%q) = %v, %v, want %s", tt.name, p, err, tt.want)
+		}
+	}
+}
+
+func TestParsePowerAction(t *testing.T) {
+	tests := map[string]PowerAction{
+		"on":               PowerOn,
+		"OFF":              PowerOff,
+		"cycle":            PowerCycle,
+		"reboot":           PowerRestart,
+		"GracefulShutdown": PowerShutdown,
+		"forcerestart":     PowerReset,
+	}
+	for input, want := range tests {
+		if got, err := ParsePowerAction(input); err != nil || got != want {
+			t.Errorf("ParsePowerAction(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParsePowerAction("explode"); err == nil {
+		t.Error("ParsePowerAction accepted an unknown action")
+	}
+}
+
+func TestIMM2Provider(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{IMM2: true})
+	defer xcc.Close()
+
+	c := NewConsole(ConsoleConfig{
+		BMCIP:    xcc.Addr,
+		Username: lenovoconsoletest.DefaultUsername,
+		Password: lenovoconsoletest.DefaultPassword,
+		Provider: imm2Provider{},
+	})
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if c.config.RPPort != imm2DefaultRPPort {
+		t.Errorf("RPPort = %d, want %d", c.config.RPPort, imm2DefaultRPPort)
+	}
+	for _, want := range []string{
+		"<title>Lenovo IMM2 Remote Console - ",
+		`"/designs/imm/SDK_Pilot4/rpviewer.js"`,
+	} {
+		if !strings.Contains(c.consoleHTML, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/designs/imm/SDK_Pilot4/utility.js", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "stub for utility.js") {
+		t.Errorf("proxied IMM2 asset: HTTP %d %q", rec.Code, rec.Body.String())
+	}
+	if xcc.Requested("/api/providers/rp_port") {
+		t.Error("IMM2 provider queried the XCC rp_port API")
+	}
+}
+
+// renamedProvider is a custom provider for XCC-compatible BMCs
+type renamedProvider struct{ Provider }
+
+func (renamedProvider) Name() string { return "oem-xcc" }
+
+func TestCheckRPPortAPI(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{RPPort: 3912})
+	defer xcc.Close()
+
+	// The preflight check asks the provider, not its name, for the port. IMM2 runs
+	// first, so the API must still be unrequested after it.
+	for _, tt := range []struct {
+		provider Provider
+		port     int
+	}{
+		{imm2Provider{}, imm2DefaultRPPort},
+		{renamedProvider{xccProvider{}}, 3912},
+	} {
+		report := Check(ConsoleConfig{
+			BMCIP:    xcc.Addr,
+			Username: lenovoconsoletest.DefaultUsername,
+			Password: lenovoconsoletest.DefaultPassword,
+			Provider: tt.provider,
+		}, 5*time.Second)
+		for _, r := range report.Results {
+			if r.Name == "rp-port-api" && (!r.Passed || r.Detail != fmt.Sprintf("port %d", tt.port)) {
+				t.Errorf("%s: rp-port-api result %+v", tt.provider.Name(), r)
+			}
+		}
+		if report.RPPort != tt.port {
+			t.Errorf("%s: RP port = %d, want %d", tt.provider.Name(), report.RPPort, tt.port)
+		}
+		if queried := xcc.Requested("/api/providers/rp_port"); queried != (tt.port == 3912) {
+			t.Errorf("%s: rp_port API queried = %v", tt.provider.Name(), queried)
+		}
+	}
+}
+
+func TestIMM2Page(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{IMM2: true})
+	defer xcc.Close()
+
+	events := runConsolePage(t, xcc, ConsoleConfig{Provider: imm2Provider{}})
+	if e, ok := findEvent(events, EventLoginResult); !ok || e.Code != 0 {
+		t.Errorf("login event = %+v, want code 0", e)
+	}
+	if !xcc.Requested("/designs/imm/SDK_Pilot4/rpviewer.js") {
+		t.Error("page did not load the SDK from /designs/imm")
+	}
+}
+
+func TestPower(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer xcc.Close()
+
+	c := NewConsole(ConsoleConfig{
+		BMCIP:    xcc.Addr,
+		Username: lenovoconsoletest.DefaultUsername,
+		Password: lenovoconsoletest.DefaultPassword,
+	})
+	if err := c.Power(PowerOff); err != nil {
+		t.Fatalf("Power(off): %v", err)
+	}
+	if state, err := c.PowerState(); err != nil || state != "Off" {
+		t.Errorf("PowerState = %q, %v, want Off", state, err)
+	}
+	if err := c.Power(PowerCycle); err != nil {
+		t.Fatalf("Power(cycle): %v", err)
+	}
+	if got := strings.Join(xcc.Resets(), ","); got != "ForceOff,PowerCycle" {
+		t.Errorf("resets = %s, want ForceOff,PowerCycle", got)
+	}
+
+	c.config.Password = "wrong"
+	if err := c.Power(PowerOn); err == nil {
+		t.Error("Power succeeded with a wrong password")
+	}
+}
+
+func TestViewerScriptSendKeystroke(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not installed")
+	}
+	// Types Shift+A on a viewer recording its keyboard API calls, and on one without it
+	const harness = `
+const calls = [];
+const viewer = {sendRPKeyEvent: function() { calls.push(Array.prototype.slice.call(arguments)); }};
+const typed = sendKeystroke(viewer, {code: 'KeyA', key: 'A', keyCode: 65, shift: true});
+console.log(JSON.stringify({typed: typed, calls: calls, withoutAPI: sendKeystroke({}, {code: 'KeyA', keyCode: 65})}));
+`
+	want := `{"typed":true,"calls":[[16,"ShiftLeft",true],[65,"KeyA",true],[65,"KeyA",false],[16,"ShiftLeft",false]],"withoutAPI":false}`
+	for _, p := range []Provider{xccProvider{}, imm2Provider{}} {
+		out, err := exec.Command(node, "-e", p.ViewerScript()+harness).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", p.Name(), err, out)
+		}
+		if got := strings.TrimSpace(string(out)); got != want {
+			t.Errorf("%s: %s\nwant %s", p.Name(), got, want)
+		}
+	}
+}
@@ -0,0 +1,599 @@
+package lenovoconsole
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Dialer opens TCP connections to BMCs, directly or through an upstream proxy
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// proxyDialTimeout bounds connecting to the proxy itself
+const proxyDialTimeout = 30 * time.Second
+
+// bmcIdleConnTimeout closes kept-alive BMC connections, and the ssh processes behind
+// them for ssh:// proxies, once unused for this long
+const bmcIdleConnTimeout = 30 * time.Second
+
+// sshCommand is the OpenSSH client used for ssh:// proxies
+var sshCommand = "ssh"
+
+// NewDialer returns the dialer for ConsoleConfig.Proxy: direct connections for "",
+// otherwise connections tunnelled through one of
+//
+//	socks5://[user:password@]host[:port]   SOCKS5 proxy (port 1080 by default)
+//	http://[user:password@]host[:port]     HTTP proxy supporting CONNECT (port 8080 by default)
+//	ssh://[user@]host[:port]               SSH jump host, through "ssh -W"
+//
+// Host names of BMCs are resolved by the proxy. The ssh client runs in batch mode, so
+// the jump host must accept a key from the agent or ~/.ssh; other settings, such as
+// ControlMaster to share one SSH connection, come from ~/.ssh/config.
+func NewDialer(proxy string) (Dialer, error) {
+	proxy = strings.TrimSpace(proxy)
+	if proxy == "" {
+		return &net.Dialer{}, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Hostname() == "" || (u.Path != "" && u.Path != "/") {
+		return nil, fmt.Errorf("invalid proxy %q: want socks5://, http:// or ssh:// and a host", proxy)
+	}
+	forward := &net.Dialer{Timeout: proxyDialTimeout}
+	switch strings.ToLower(u.Scheme) {
+	case "socks5", "socks5h":
+		return &socks5Dialer{proxy: proxyHostPort(u, "1080"), user: u.User, forward: forward}, nil
+	case "http":
+		return &connectDialer{proxy: proxyHostPort(u, "8080"), user: u.User, forward: forward}, nil
+	case "ssh":
+		if _, ok := u.User.Password(); ok {
+			return nil, fmt.Errorf("invalid proxy %q: ssh:// takes no password, use a key", proxy)
+		}
+		target := u.Hostname()
+		if u.User != nil {
+			target = u.User.Username() + "@" + target
+		}
+		return &sshDialer{target: target, port: u.Port()}, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q (want socks5, http or ssh)", u.Scheme)
+}
+
+// proxyHostPort returns the proxy's address, adding the scheme's default port
+func proxyHostPort(u *url.URL, defaultPort string) string {
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// errDialer fails every connection with the reason a proxy setting is unusable
+type errDialer struct{ err error }
+
+func (d errDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return nil, d.err
+}
+
+// dialerFor returns the dialer for a proxy setting; an invalid setting yields a dialer
+// that reports why, so that requests fail rather than bypass the proxy. HTTP requests
+// through the dialer share one transport, see bmcTransport.
+func dialerFor(proxy string) Dialer {
+	d, err := NewDialer(proxy)
+	if err != nil {
+		d = errDialer{err}
+	}
+	td := &transportDialer{Dialer: d}
+	td.transport = newBMCTransport(d)
+	return td
+}
+
+// transportDialer is a Dialer with the HTTP transport used for every BMC request
+// through it, so that connections are kept alive and reused between requests
+type transportDialer struct {
+	Dialer
+	transport *http.Transport
+}
+
+// directTransport serves BMC requests without a dialer
+var directTransport = newBMCTransport(nil)
+
+// dialTimeout connects through d, giving up after timeout
+func dialTimeout(d Dialer, address string, timeout time.Duration) (net.Conn, error) {
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, "tcp", address)
+}
+
+// dialReachable connects to address through d within timeout and closes the connection
+// again. Connections through an SSH jump host are confirmed first, see sshConn.confirm.
+func dialReachable(d Dialer, address string, timeout time.Duration) error {
+	start := time.Now()
+	conn, err := dialTimeout(d, address, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if sc, ok := conn.(*sshConn); ok {
+		return sc.confirm(timeout - time.Since(start))
+	}
+	return nil
+}
+
+// dialTLS connects through d and completes a TLS handshake within timeout
+func dialTLS(d Dialer, address string, timeout time.Duration, config *tls.Config) (*tls.Conn, error) {
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	raw, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if config.ServerName == "" {
+		host, _, _ := net.SplitHostPort(address)
+		config = config.Clone()
+		config.ServerName = host
+	}
+	conn := tls.Client(raw, config)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// bmcTransport returns the HTTP transport for BMC requests that connects through d
+// (directly if nil) and accepts the BMC's self-signed certificate. Dialers from
+// dialerFor and nil share one transport; other dialers get a new one.
+func bmcTransport(d Dialer) *http.Transport {
+	switch d := d.(type) {
+	case nil:
+		return directTransport
+	case *transportDialer:
+		return d.transport
+	}
+	return newBMCTransport(d)
+}
+
+// newBMCTransport creates a transport for bmcTransport
+func newBMCTransport(d Dialer) *http.Transport {
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		IdleConnTimeout: bmcIdleConnTimeout,
+	}
+	if d != nil {
+		tr.DialContext = d.DialContext
+	}
+	return tr
+}
+
+// withDeadline applies ctx's deadline to conn for a proxy handshake and closes conn if
+// ctx ends first. The returned function must be called once the handshake is done.
+func withDeadline(ctx context.Context, conn net.Conn) func() {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		conn.SetDeadline(time.Time{})
+	}
+}
+
+// socks5Dialer connects through a SOCKS5 proxy (RFC 1928) with optional username and
+// password authentication (RFC 1929)
+type socks5Dialer struct {
+	proxy   string
+	user    *url.Userinfo
+	forward *net.Dialer
+}
+
+// socks5Errors describes SOCKS5 reply codes
+var socks5Errors = map[byte]string{
+	1: "general server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+func (d *socks5Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, "tcp", d.proxy)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS5 proxy %s: %v", d.proxy, err)
+	}
+	done := withDeadline(ctx, conn)
+	err = d.handshake(conn, address)
+	done()
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("SOCKS5 proxy %s: connect to %s: %v", d.proxy, address, err)
+	}
+	return conn, nil
+}
+
+func (d *socks5Dialer) handshake(conn net.Conn, address string) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("bad port %q", portStr)
+	}
+
+	methods := []byte{0x00}
+	if d.user != nil {
+		methods = []byte{0x02, 0x00}
+	}
+	if _, err := conn.Write(append([]byte{5, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != 5 {
+		return fmt.Errorf("not a SOCKS5 proxy")
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if d.user == nil {
+			return fmt.Errorf("proxy requires a username and password")
+		}
+		username := d.user.Username()
+		password, _ := d.user.Password()
+		if len(username) > 255 || len(password) > 255 {
+			return fmt.Errorf("username or password too long")
+		}
+		auth := []byte{1, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply[:]); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return fmt.Errorf("authentication failed")
+		}
+	default:
+		return fmt.Errorf("no acceptable authentication method")
+	}
+
+	req := []byte{5, 1, 0}
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		// Zones are local to this machine and mean nothing to the proxy
+		host = host[:i]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(append(req, 1), ip4...)
+		} else {
+			req = append(append(req, 4), ip...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("host name too long")
+		}
+		req = append(append(req, 3, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		if msg, ok := socks5Errors[head[1]]; ok {
+			return errors.New(msg)
+		}
+		return fmt.Errorf("proxy error %d", head[1])
+	}
+	// Skip the bound address and port
+	var skip int
+	switch head[3] {
+	case 1:
+		skip = net.IPv4len + 2
+	case 4:
+		skip = net.IPv6len + 2
+	case 3:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return err
+		}
+		skip = int(n[0]) + 2
+	default:
+		return fmt.Errorf("bad reply address type %d", head[3])
+	}
+	_, err = io.CopyN(io.Discard, conn, int64(skip))
+	return err
+}
+
+// connectDialer connects through an HTTP proxy with the CONNECT method
+type connectDialer struct {
+	proxy   string
+	user    *url.Userinfo
+	forward *net.Dialer
+}
+
+func (d *connectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, "tcp", d.proxy)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP proxy %s: %v", d.proxy, err)
+	}
+	done := withDeadline(ctx, conn)
+	br, err := d.connect(conn, address)
+	done()
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("HTTP proxy %s: connect to %s: %v", d.proxy, address, err)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+func (d *connectDialer) connect(conn net.Conn, address string) (*bufio.Reader, error) {
+	var req bytes.Buffer
+	fmt.Fprintf(&req, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n", address, address)
+	if d.user != nil {
+		password, _ := d.user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.user.Username() + ":" + password))
+		fmt.Fprintf(&req, "Proxy-Authorization: Basic %s\r\n", credentials)
+	}
+	req.WriteString("\r\n")
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: "CONNECT"})
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy refused: %s", resp.Status)
+	}
+	return br, nil
+}
+
+// bufferedConn is a connection whose first bytes were read ahead into r
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// sshDialer connects through an SSH jump host by running "ssh -W" for each connection
+type sshDialer struct {
+	target string // [user@]host
+	port   string
+}
+
+// DialContext starts "ssh -W" and returns its standard input and output as the
+// connection. ssh stays silent until the target speaks, which TLS servers never do
+// first, so the connection is returned before the jump host has reached address. With
+// ExitOnForwardFailure, ssh exits when it cannot, and reads then return its message;
+// reachability checks confirm the connection first, see dialReachable.
+func (d *sshDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	args := []string{"-W", address, "-o", "BatchMode=yes", "-o", "ExitOnForwardFailure=yes"}
+	timeout := proxyDialTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if secs := int(timeout.Seconds()); secs > 0 {
+		args = append(args, "-o", "ConnectTimeout="+strconv.Itoa(secs))
+	}
+	if d.port != "" {
+		args = append(args, "-p", d.port)
+	}
+	args = append(args, d.target)
+
+	// os.Pipe, unlike Cmd.StdinPipe, gives pipes that support deadlines
+	var pipes [6]*os.File
+	for i := 0; i < len(pipes); i += 2 {
+		r, w, err := os.Pipe()
+		if err != nil {
+			for _, f := range pipes[:i] {
+				f.Close()
+			}
+			return nil, err
+		}
+		pipes[i], pipes[i+1] = r, w
+	}
+	stdinR, stdinW, stdoutR, stdoutW, stderrR, stderrW := pipes[0], pipes[1], pipes[2], pipes[3], pipes[4], pipes[5]
+
+	conn := &sshConn{
+		stdin:      stdinW,
+		stdout:     stdoutR,
+		target:     d.target,
+		address:    address,
+		exited:     make(chan struct{}),
+		stderrDone: make(chan struct{}),
+	}
+	conn.cmd = exec.Command(sshCommand, args...)
+	conn.cmd.Stdin, conn.cmd.Stdout, conn.cmd.Stderr = stdinR, stdoutW, stderrW
+	err := conn.cmd.Start()
+	stdinR.Close()
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		stdinW.Close()
+		stdoutR.Close()
+		stderrR.Close()
+		return nil, fmt.Errorf("SSH jump host %s: %v", d.target, err)
+	}
+	go func() {
+		conn.cmd.Wait()
+		close(conn.exited)
+	}()
+	// Drain standard error separately from Wait, which would otherwise wait for a
+	// ControlMaster that ssh leaves running with the pipe open
+	go func() {
+		defer close(conn.stderrDone)
+		defer stderrR.Close()
+		io.Copy(&conn.stderr, stderrR)
+	}()
+	return conn, nil
+}
+
+// sshConn is a connection carried over the standard input and output of "ssh -W"
+type sshConn struct {
+	cmd        *exec.Cmd
+	stdin      *os.File
+	stdout     *os.File
+	stderr     lockedBuffer // ssh's messages, reported when it fails
+	target     string
+	address    string
+	exited     chan struct{}
+	stderrDone chan struct{}
+	received   atomic.Bool // the target has sent data
+	once       sync.Once
+}
+
+// confirm checks that the jump host has connected to the target. It starts a TLS
+// handshake, which the HTTPS and RP ports of BMCs answer, and waits for the first byte
+// of the reply; if ssh exits instead, its failure is returned. The connection cannot
+// be used for anything else afterwards.
+func (c *sshConn) confirm(timeout time.Duration) error {
+	c.SetDeadline(time.Now().Add(timeout))
+	err := tls.Client(c, &tls.Config{InsecureSkipVerify: true}).Handshake()
+	if c.received.Load() {
+		return nil
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		err = fmt.Errorf("SSH jump host %s: connect to %s: no answer", c.target, c.address)
+	}
+	return err
+}
+
+// failure describes why ssh exited
+func (c *sshConn) failure() error {
+	msg := strings.TrimSpace(c.stderr.String())
+	if msg == "" {
+		msg = c.cmd.ProcessState.String()
+	}
+	return fmt.Errorf("SSH jump host %s: connect to %s: %s", c.target, c.address, msg)
+}
+
+func (c *sshConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if n > 0 {
+		c.received.Store(true)
+	}
+	if err == io.EOF {
+		// ssh closes its output when the forward fails or the jump host drops the connection
+		select {
+		case <-c.exited:
+			<-c.stderrDone
+			if !c.cmd.ProcessState.Success() && strings.TrimSpace(c.stderr.String()) != "" {
+				return n, c.failure()
+			}
+		case <-time.After(time.Second):
+		}
+	}
+	return n, err
+}
+
+func (c *sshConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+func (c *sshConn) Close() error {
+	c.once.Do(func() {
+		c.stdin.Close()
+		c.stdout.Close()
+		c.cmd.Process.Kill()
+	})
+	return nil
+}
+
+func (c *sshConn) LocalAddr() net.Addr  { return sshAddr("ssh") }
+func (c *sshConn) RemoteAddr() net.Addr { return sshAddr(c.address) }
+
+func (c *sshConn) SetDeadline(t time.Time) error {
+	c.stdin.SetWriteDeadline(t)
+	return c.stdout.SetReadDeadline(t)
+}
+
+func (c *sshConn) SetReadDeadline(t time.Time) error  { return c.stdout.SetReadDeadline(t) }
+func (c *sshConn) SetWriteDeadline(t time.Time) error { return c.stdin.SetWriteDeadline(t) }
+
+// sshAddr is the address of one end of an sshConn
+type sshAddr string
+
+func (a sshAddr) Network() string { return "ssh" }
+func (a sshAddr) String() string  { return string(a) }
+
+// lockedBuffer is a bytes.Buffer safe for a writing process and a reading caller
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.buf.Len() > 4096 {
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,108 @@
+package lenovoconsole
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// redfishTimeout bounds each Redfish request made by the library
+const redfishTimeout = 15 * time.Second
+
+// redfishClient is a minimal Redfish client using HTTP basic authentication
+type redfishClient struct {
+	bmcIP    string
+	username string
+	password string
+	http     *http.Client
+}
+
+// newRedfishClient creates a Redfish client for the given BMC that connects through d
+// (directly if nil)
+func newRedfishClient(d Dialer, bmcIP, username, password string) *redfishClient {
+	return &redfishClient{
+		bmcIP:    bmcIP,
+		username: username,
+		password: password,
+		http: &http.Client{
+			Transport: bmcTransport(d),
+			Timeout:   redfishTimeout,
+		},
+	}
+}
+
+// get fetches a Redfish resource and decodes the JSON body into v
+func (r *redfishClient) get(path string, v interface{}) error {
+	req, err := http.NewRequest("GET", bmcURL(r.bmcIP, path), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(r.username, r.password)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("GET %s: unexpected HTTP %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("GET %s: invalid response: %v", path, err)
+	}
+	return nil
+}
+
+// post sends body as JSON to a Redfish action or collection
+func (r *redfishClient) post(path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", bmcURL(r.bmcIP, path), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(r.username, r.password)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
+		return nil
+	}
+	return fmt.Errorf("POST %s: unexpected HTTP %d", path, resp.StatusCode)
+}
+
+// redfishCollection is the common shape of a Redfish resource collection
+type redfishCollection struct {
+	Members []struct {
+		ODataID string `json:"@odata.id"`
+	} `json:"Members"`
+}
+
+// members returns the resource paths listed in a collection
+func (r *redfishClient) members(path string) ([]string, error) {
+	var coll redfishCollection
+	if err := r.get(path, &coll); err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(coll.Members))
+	for _, m := range coll.Members {
+		paths = append(paths, m.ODataID)
+	}
+	return paths, nil
+}
@@ -0,0 +1,205 @@
+package lenovoconsole
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Screenshot limits and defaults
+const (
+	maxScreenshotSize     = 32 << 20 // bytes accepted for one posted screenshot
+	defaultScreenInterval = 10 * time.Second
+	screenshotTimeout     = 30 * time.Second // capture time allowed to GET /api/screenshot
+)
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// OCRFunc recognizes the text in a PNG screenshot
+type OCRFunc func(ctx context.Context, png []byte) (string, error)
+
+// ScreenWatch periodically captures the console screen, recognizes its text and emits
+// EventScreenMatch when one of the patterns appears. A pattern fires again only after it
+// has disappeared from the screen.
+type ScreenWatch struct {
+	Patterns []*regexp.Regexp // Expressions searched for in the screen text; none disables the watch
+	Interval time.Duration    // Time between captures (default: 10s)
+	OCR      OCRFunc          // Text recognizer (nil for TesseractOCR)
+}
+
+// TesseractOCR recognizes text with the tesseract command line tool, which must be
+// installed and on the PATH
+func TesseractOCR(ctx context.Context, png []byte) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "tesseract", "stdin", "stdout")
+	cmd.Stdin = bytes.NewReader(png)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("tesseract failed: %v: %s", err, msg)
+		}
+		return "", fmt.Errorf("tesseract failed: %v", err)
+	}
+	return string(out), nil
+}
+
+// screenshotResult is a capture posted back by the console page
+type screenshotResult struct {
+	png []byte
+	err error
+}
+
+// Screenshot captures the console screen as PNG. One open console page exports its
+// viewer canvas and posts the image back, so a page must be open and connected.
+func (c *Console) Screenshot(ctx context.Context) ([]byte, error) {
+	png, err := c.capture(ctx)
+	c.audit(AuditRecord{Action: AuditScreenshot, Result: auditResult(err)})
+	return png, err
+}
+
+// capture is Screenshot without an audit record, for the repeated captures of screen
+// watches and scripts
+func (c *Console) capture(ctx context.Context) ([]byte, error) {
+	ch := make(chan screenshotResult, 1)
+	c.mu.Lock()
+	c.screenshotSeq++
+	id := strconv.Itoa(c.screenshotSeq)
+	c.screenshots[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.screenshots, id)
+		c.mu.Unlock()
+	}()
+
+	if !c.sendCommandToOne(pageCommand{Name: "screenshot", ID: id}) {
+		return nil, fmt.Errorf("no console page is open to capture")
+	}
+	select {
+	case result := <-ch:
+		return result.png, result.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("screenshot not received: %v", ctx.Err())
+	case <-c.stopCh:
+		return nil, fmt.Errorf("console stopped")
+	}
+}
+
+// screenshotHandler captures the screen for GET requests and receives the page's
+// capture, as a PNG body or an error query parameter, for POST requests. Both need
+// the console token, since the screen may show anything the server displays.
+func (c *Console) screenshotHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !checkToken(w, r, c.pageToken, "") {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), screenshotTimeout)
+		defer cancel()
+		png, err := c.capture(ctx)
+		c.auditRequest(r, AuditRecord{Action: AuditScreenshot, Result: auditResult(err)})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(png)
+	case http.MethodPost:
+		if !c.pagePost(w, r, "") {
+			return
+		}
+		var result screenshotResult
+		if msg := r.URL.Query().Get("error"); msg != "" {
+			result.err = fmt.Errorf("page could not capture the screen: %s", msg)
+		} else {
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxScreenshotSize))
+			if err != nil || !bytes.HasPrefix(data, pngSignature) {
+				http.Error(w, "Invalid screenshot", http.StatusBadRequest)
+				return
+			}
+			result.png = data
+		}
+
+		c.mu.Lock()
+		ch, ok := c.screenshots[r.URL.Query().Get("id")]
+		c.mu.Unlock()
+		if !ok {
+			http.Error(w, "Unknown screenshot request", http.StatusNotFound)
+			return
+		}
+		select {
+		case ch <- result:
+		default: // another page already answered
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// watchScreen runs the screen watch until the console stops
+func (c *Console) watchScreen() {
+	watch := c.config.ScreenWatch
+	interval := watch.Interval
+	if interval <= 0 {
+		interval = defaultScreenInterval
+	}
+	ocr := watch.OCR
+	if ocr == nil {
+		ocr = TesseractOCR
+	}
+
+	matched := make([]bool, len(watch.Patterns))
+	var lastErr string
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.stopCh:
+			return
+		}
+		if c.ConnectedPages() == 0 {
+			continue
+		}
+
+		text, err := c.screenText(interval, ocr)
+		if err != nil {
+			// Report a failing recognizer once rather than on every capture
+			if err.Error() != lastErr {
+				fmt.Printf("Screen watch for BMC %s: %v\n", c.config.BMCIP, err)
+				lastErr = err.Error()
+			}
+			continue
+		}
+		lastErr = ""
+		for i, pattern := range watch.Patterns {
+			match := pattern.FindString(text)
+			if match != "" && !matched[i] {
+				c.emit(Event{Type: EventScreenMatch, Message: match, Pattern: pattern.String()})
+			}
+			matched[i] = match != ""
+		}
+	}
+}
+
+// screenText captures the screen and recognizes its text within timeout
+func (c *Console) screenText(timeout time.Duration, ocr OCRFunc) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	png, err := c.capture(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ocr(ctx, png)
+}
@@ -0,0 +1,222 @@
+package lenovoconsole
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// screenFixtures are the stored screenshots and the text an OCR engine reads from them
+var screenFixtures = map[string]string{
+	"post-f1.png":      "System POST complete\nF1 Setup  F2 Diagnostics\n\nPress F1 to continue",
+	"kernel-panic.png": "Kernel panic - not syncing: VFS: Unable to mount root fs on unknown-block(0,0)",
+}
+
+func readScreen(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "screens", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// fixtureOCR recognizes the stored screenshots by their content
+func fixtureOCR(t *testing.T) OCRFunc {
+	texts := make(map[string]string)
+	for name, text := range screenFixtures {
+		texts[string(readScreen(t, name))] = text
+	}
+	return func(ctx context.Context, png []byte) (string, error) {
+		return texts[string(png)], nil
+	}
+}
+
+// fakeScreenPage registers a console page that answers screenshot commands by posting
+// the current screen, like the page's captureScreen, and records typed keys, confirming
+// them like the page's reportTyped
+type fakeScreenPage struct {
+	mu        sync.Mutex
+	screen    []byte
+	typed     [][]Keystroke
+	onKeys    func([]Keystroke) // called for every paste command
+	typeError string            // reported instead of confirming typed keys
+	done      chan struct{}
+}
+
+func newFakeScreenPage(c *Console, screen []byte) *fakeScreenPage {
+	p := &fakeScreenPage{screen: screen, done: make(chan struct{})}
+	commands := make(chan pageCommand, 8)
+	c.mu.Lock()
+	c.pages[commands] = false
+	c.mu.Unlock()
+	go func() {
+		for {
+			select {
+			case cmd := <-commands:
+				if cmd.Name == "paste" {
+					p.mu.Lock()
+					p.typed = append(p.typed, cmd.Keys)
+					onKeys, typeError := p.onKeys, p.typeError
+					p.mu.Unlock()
+					if onKeys != nil {
+						onKeys(cmd.Keys)
+					}
+					if cmd.ID != "" {
+						target := "/api/typed?id=" + cmd.ID
+						if typeError != "" {
+							target += "&error=" + url.QueryEscape(typeError)
+						}
+						c.typedHandler(httptest.NewRecorder(), pageRequest(c, target, "", nil))
+					}
+				}
+				if cmd.Name != "screenshot" {
+					continue
+				}
+				p.mu.Lock()
+				body := p.screen
+				p.mu.Unlock()
+				c.screenshotHandler(httptest.NewRecorder(),
+					pageRequest(c, "/api/screenshot?id="+cmd.ID, "image/png", bytes.NewReader(body)))
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *fakeScreenPage) show(screen []byte) {
+	p.mu.Lock()
+	p.screen = screen
+	p.mu.Unlock()
+}
+
+func (p *fakeScreenPage) close() { close(p.done) }
+
+func TestScreenshot(t *testing.T) {
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.Screenshot(ctx); err == nil {
+		t.Error("Screenshot succeeded without an open page")
+	}
+
+	fixture := readScreen(t, "post-f1.png")
+	page := newFakeScreenPage(c, fixture)
+	defer page.close()
+	shot, err := c.Screenshot(ctx)
+	if err != nil {
+		t.Fatalf("Screenshot: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(shot))
+	if err != nil || !bytes.Equal(shot, fixture) {
+		t.Fatalf("Screenshot returned %d bytes, decode error %v", len(shot), err)
+	}
+	if img.Bounds().Dx() != 320 || img.Bounds().Dy() != 200 {
+		t.Errorf("screenshot size %v", img.Bounds())
+	}
+
+	c.setupHandlers()
+	rec := httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/screenshot", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("GET /api/screenshot without the token: HTTP %d", rec.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/api/screenshot", nil)
+	req.Header.Set(pageTokenHeader, c.Token())
+	rec = httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" || !bytes.Equal(rec.Body.Bytes(), fixture) {
+		t.Errorf("GET /api/screenshot: HTTP %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestScreenshotHandlerErrors(t *testing.T) {
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1"})
+	commands := make(chan pageCommand, 1)
+	c.pages[commands] = false
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := c.Screenshot(context.Background())
+		result <- err
+	}()
+	cmd := <-commands
+
+	post := func(query, body string) int {
+		rec := httptest.NewRecorder()
+		c.screenshotHandler(rec, pageRequest(c, "/api/screenshot?"+query, "image/png", strings.NewReader(body)))
+		return rec.Code
+	}
+	if code := post("id="+cmd.ID, "GIF89a"); code != http.StatusBadRequest {
+		t.Errorf("non-PNG body: status %d", code)
+	}
+	if code := post("id=unknown", string(pngSignature)); code != http.StatusNotFound {
+		t.Errorf("unknown request: status %d", code)
+	}
+	if code := post("id="+cmd.ID+"&error=tainted+canvas", ""); code != http.StatusNoContent {
+		t.Errorf("page error: status %d", code)
+	}
+	if err := <-result; err == nil || !strings.Contains(err.Error(), "tainted canvas") {
+		t.Errorf("Screenshot error = %v", err)
+	}
+}
+
+func TestScreenWatch(t *testing.T) {
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", ScreenWatch: ScreenWatch{
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`Kernel panic[^\n]*`), regexp.MustCompile(`Press F1`)},
+		Interval: 20 * time.Millisecond,
+		OCR:      fixtureOCR(t),
+	}})
+	events, cancel := c.Subscribe()
+	defer cancel()
+
+	page := newFakeScreenPage(c, readScreen(t, "post-f1.png"))
+	defer page.close()
+	go c.watchScreen()
+	defer c.Stop()
+
+	next := func() Event {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("no screen match")
+			return Event{}
+		}
+	}
+
+	e := next()
+	if e.Type != EventScreenMatch || e.Pattern != "Press F1" || e.Message != "Press F1" {
+		t.Fatalf("first event = %+v", e)
+	}
+	page.show(readScreen(t, "kernel-panic.png"))
+	e = next()
+	if e.Pattern != "Kernel panic[^\\n]*" || !strings.HasPrefix(e.Message, "Kernel panic - not syncing") {
+		t.Fatalf("second event = %+v", e)
+	}
+
+	// A pattern that stays on screen fires once; it fires again after it disappears
+	page.show(readScreen(t, "post-f1.png"))
+	if e = next(); e.Pattern != "Press F1" {
+		t.Fatalf("third event = %+v", e)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event %+v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,413 @@
+package lenovoconsole
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Script step actions
+const (
+	StepWaitForText = "wait-for-text" // Wait until a regular expression appears on screen
+	StepSendKeys    = "send-keys"     // Type a KeySequence
+	StepSleep       = "sleep"         // Pause for a duration
+	StepPower       = "power"         // Run a PowerAction; power-cycle is short for power: cycle
+	StepScreenshot  = "screenshot"    // Capture the screen under a label
+)
+
+// Script defaults
+const (
+	defaultScriptPoll = 2 * time.Second
+	defaultScriptWait = 5 * time.Minute
+	stepScreenTimeout = 15 * time.Second // capture time allowed for the screenshot after each step
+	typingTimeout     = 15 * time.Second // confirmation time allowed beyond typing at the paste rate
+)
+
+// Script is an expect-style sequence of console steps, usually written in YAML:
+//
+//	name: Enter BIOS setup
+//	steps:
+//	  - power-cycle: true
+//	  - wait-for-text: Press F1
+//	    timeout: 10m
+//	  - send-keys: "{F1}"
+//	  - screenshot: setup
+type Script struct {
+	Name    string        `yaml:"name" json:"name,omitempty"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout,omitempty"` // Limit for the whole script (0 for none)
+	Steps   []ScriptStep  `yaml:"steps" json:"steps"`
+}
+
+// ScriptStep is one step of a Script. Exactly one action field is set.
+type ScriptStep struct {
+	Name string `yaml:"name" json:"name,omitempty"` // Optional label for the report
+
+	WaitForText string        `yaml:"wait-for-text" json:"wait_for_text,omitempty"` // Regular expression
+	SendKeys    string        `yaml:"send-keys" json:"send_keys,omitempty"`         // Text and {Key} names, see KeySequence
+	Sleep       time.Duration `yaml:"sleep" json:"sleep,omitempty"`
+	Power       string        `yaml:"power" json:"power,omitempty"` // Power action, see ParsePowerAction
+	PowerCycle  bool          `yaml:"power-cycle" json:"power_cycle,omitempty"`
+	Screenshot  string        `yaml:"screenshot" json:"screenshot,omitempty"` // Label of the screenshot
+
+	Timeout time.Duration `yaml:"timeout" json:"timeout,omitempty"` // wait-for-text limit (default: 5m)
+}
+
+// Action returns the step's action and its argument, or an error unless exactly one
+// action is set
+func (s ScriptStep) Action() (action, argument string, err error) {
+	set := 0
+	add := func(ok bool, a, arg string) {
+		if ok {
+			set++
+			action, argument = a, arg
+		}
+	}
+	add(s.WaitForText != "", StepWaitForText, s.WaitForText)
+	add(s.SendKeys != "", StepSendKeys, s.SendKeys)
+	add(s.Sleep != 0, StepSleep, s.Sleep.String())
+	add(s.Power != "", StepPower, s.Power)
+	add(s.PowerCycle, StepPower, string(PowerCycle))
+	add(s.Screenshot != "", StepScreenshot, s.Screenshot)
+	switch set {
+	case 0:
+		return "", "", fmt.Errorf("no action")
+	case 1:
+		return action, argument, nil
+	}
+	return "", "", fmt.Errorf("more than one action")
+}
+
+// ParseScript reads a YAML script and validates its steps
+func ParseScript(data []byte) (*Script, error) {
+	var script Script
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&script); err != nil {
+		return nil, fmt.Errorf("invalid script: %v", err)
+	}
+	if err := script.Validate(); err != nil {
+		return nil, err
+	}
+	return &script, nil
+}
+
+// LoadScript reads and validates a YAML script file
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	script, err := ParseScript(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if script.Name == "" {
+		script.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return script, nil
+}
+
+// Validate checks every step's action and argument
+func (s *Script) Validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("script has no steps")
+	}
+	for i, step := range s.Steps {
+		action, argument, err := step.Action()
+		if err == nil {
+			switch action {
+			case StepWaitForText:
+				_, err = regexp.Compile(argument)
+			case StepSleep:
+				if step.Sleep < 0 {
+					err = fmt.Errorf("negative duration")
+				}
+			case StepPower:
+				_, err = ParsePowerAction(argument)
+			}
+		}
+		if err == nil && step.Timeout != 0 && action != StepWaitForText {
+			err = fmt.Errorf("timeout only applies to wait-for-text")
+		}
+		if err != nil {
+			return fmt.Errorf("step %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// ScriptOptions controls how RunScript drives the console
+type ScriptOptions struct {
+	OCR          OCRFunc                // Text recognizer for wait-for-text (nil for TesseractOCR)
+	PollInterval time.Duration          // Time between screen captures while waiting for text (default: 2s)
+	Progress     func(ScriptStepResult) // Called after each step
+}
+
+// ScriptStepResult is the outcome of one script step
+type ScriptStepResult struct {
+	Step       int    `json:"step"` // 1-based position in the script
+	Name       string `json:"name,omitempty"`
+	Action     string `json:"action"`
+	Argument   string `json:"argument,omitempty"`
+	Passed     bool   `json:"passed"`
+	Detail     string `json:"detail,omitempty"`
+	Text       string `json:"text,omitempty"` // Screen text last recognized by wait-for-text
+	DurationMS int64  `json:"duration_ms"`
+
+	Screenshot      []byte `json:"-"`                          // PNG of the screen after the step
+	ScreenshotFile  string `json:"screenshot,omitempty"`       // Set by WriteDir
+	ScreenshotError string `json:"screenshot_error,omitempty"` // Why no screenshot was taken
+}
+
+// ScriptReport is the result of running a Script
+type ScriptReport struct {
+	Script   string             `json:"script"`
+	BMCIP    string             `json:"bmc_ip"`
+	Started  time.Time          `json:"started"`
+	Finished time.Time          `json:"finished"`
+	Passed   bool               `json:"passed"`
+	Steps    []ScriptStepResult `json:"steps"`
+}
+
+// RunScript runs the steps of a script in order through an open console page and stops
+// at the first failing step. The screen is captured after every step for the report.
+// An error is returned only if ctx ends; failed steps are recorded in the report.
+func (c *Console) RunScript(ctx context.Context, script *Script, opts ScriptOptions) (*ScriptReport, error) {
+	if opts.OCR == nil {
+		opts.OCR = TesseractOCR
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultScriptPoll
+	}
+	if script.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, script.Timeout)
+		defer cancel()
+	}
+
+	report := &ScriptReport{Script: script.Name, BMCIP: c.config.BMCIP, Started: time.Now(), Passed: true}
+	c.audit(AuditRecord{Action: AuditScript, Detail: script.Name, Result: "started"})
+	defer func() {
+		report.Finished = time.Now()
+		c.audit(AuditRecord{Action: AuditScript, Detail: script.Name, Result: report.outcome()})
+	}()
+	for i, step := range script.Steps {
+		action, argument, err := step.Action()
+		result := ScriptStepResult{Step: i + 1, Name: step.Name, Action: action, Argument: argument}
+		start := time.Now()
+		if err == nil {
+			err = c.runStep(ctx, step, action, argument, opts, &result)
+		}
+		result.DurationMS = time.Since(start).Milliseconds()
+		result.Passed = err == nil
+		if err != nil {
+			result.Detail = err.Error()
+			report.Passed = false
+		}
+
+		if result.Screenshot == nil && result.ScreenshotError == "" {
+			shotCtx, cancel := context.WithTimeout(context.Background(), stepScreenTimeout)
+			if result.Screenshot, err = c.capture(shotCtx); err != nil {
+				result.ScreenshotError = err.Error()
+			}
+			cancel()
+		}
+		report.Steps = append(report.Steps, result)
+		if opts.Progress != nil {
+			opts.Progress(result)
+		}
+		if !result.Passed {
+			break
+		}
+	}
+	return report, ctx.Err()
+}
+
+// outcome summarizes the report for the audit log
+func (r *ScriptReport) outcome() string {
+	for _, step := range r.Steps {
+		if !step.Passed {
+			return fmt.Sprintf("failed at step %d: %s", step.Step, step.Detail)
+		}
+	}
+	return "passed"
+}
+
+// runStep performs one step, recording its detail and any screenshot it took in result
+func (c *Console) runStep(ctx context.Context, step ScriptStep, action, argument string, opts ScriptOptions, result *ScriptStepResult) error {
+	switch action {
+	case StepWaitForText:
+		return c.waitForText(ctx, step, opts, result)
+
+	case StepSendKeys:
+		keys, err := c.keySequence(argument)
+		if err != nil {
+			return err
+		}
+		// Wait until the page has typed them, allowing for a page that never answers
+		typeCtx, cancel := context.WithTimeout(ctx, time.Duration(len(keys))*time.Second/time.Duration(c.pasteRate())+typingTimeout)
+		defer cancel()
+		if err := c.typeKeysAndWait(typeCtx, AuditSendKeys, len(keys), keys); err != nil {
+			return err
+		}
+		result.Detail = fmt.Sprintf("%d key presses", len(keys))
+		return nil
+
+	case StepSleep:
+		return sleepContext(ctx, step.Sleep)
+
+	case StepPower:
+		power, err := ParsePowerAction(argument)
+		if err != nil {
+			return err
+		}
+		if err := c.Power(power); err != nil {
+			return err
+		}
+		result.Detail = "requested " + string(power)
+		return nil
+
+	case StepScreenshot:
+		png, err := c.Screenshot(ctx)
+		if err != nil {
+			result.ScreenshotError = err.Error()
+			return err
+		}
+		result.Screenshot = png
+		return nil
+	}
+	return fmt.Errorf("unknown action %q", action)
+}
+
+// waitForText captures and recognizes the screen until the step's pattern appears or its
+// timeout passes. Capture and recognition errors are retried until then.
+func (c *Console) waitForText(ctx context.Context, step ScriptStep, opts ScriptOptions, result *ScriptStepResult) error {
+	pattern, err := regexp.Compile(step.WaitForText)
+	if err != nil {
+		return err
+	}
+	timeout := step.Timeout
+	if timeout <= 0 {
+		timeout = defaultScriptWait
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lastErr error
+	for {
+		png, err := c.capture(ctx)
+		if err == nil {
+			result.Screenshot = png
+			var text string
+			if text, err = opts.OCR(ctx, png); err == nil {
+				result.Text = text
+				if match := pattern.FindString(text); match != "" {
+					result.Detail = "matched " + match
+					return nil
+				}
+			}
+		}
+		if err != nil {
+			lastErr = err
+		}
+
+		if sleepContext(ctx, opts.PollInterval) != nil {
+			if lastErr != nil && result.Screenshot == nil {
+				return fmt.Errorf("%q did not appear within %s: %v", step.WaitForText, timeout, lastErr)
+			}
+			return fmt.Errorf("%q did not appear within %s", step.WaitForText, timeout)
+		}
+	}
+}
+
+// sleepContext waits for d or until ctx ends
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// WriteDir writes the report to dir as report.json and report.html, with each step's
+// screenshot in a numbered PNG file
+func (r *ScriptReport) WriteDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i := range r.Steps {
+		step := &r.Steps[i]
+		if step.Screenshot == nil {
+			continue
+		}
+		label := step.Name
+		if step.Action == StepScreenshot {
+			label = step.Argument
+		}
+		if label == "" {
+			label = step.Action
+		}
+		step.ScreenshotFile = fmt.Sprintf("%02d-%s.png", step.Step, strings.Trim(unsafeFileChars.ReplaceAllString(label, "-"), "-"))
+		if err := os.WriteFile(filepath.Join(dir, step.ScreenshotFile), step.Screenshot, 0644); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "report.json"), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	var page bytes.Buffer
+	if err := scriptReportTemplate.Execute(&page, r); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "report.html"), page.Bytes(), 0644)
+}
+
+// scriptReportTemplate renders a report with each step's screenshot
+var scriptReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <title>{{.Script}} on {{.BMCIP}}: {{if .Passed}}passed{{else}}failed{{end}}</title>
+    <style>
+        body { font-family: Arial, sans-serif; margin: 20px; }
+        .step { border-top: 1px solid #ccc; padding: 10px 0; }
+        .passed { color: #2a7a2a; }
+        .failed { color: #b00020; }
+        pre { background: #f4f4f4; padding: 6px; white-space: pre-wrap; max-height: 200px; overflow: auto; }
+        img { max-width: 640px; border: 1px solid #333; }
+    </style>
+</head>
+<body>
+    <h1>{{.Script}} <span class="{{if .Passed}}passed">passed{{else}}failed">failed{{end}}</span></h1>
+    <p>{{.BMCIP}} &middot; {{.Started.Format "2006-01-02 15:04:05"}} to {{.Finished.Format "15:04:05"}}</p>
+    {{range .Steps}}
+    <div class="step">
+        <h3>{{.Step}}. {{if .Name}}{{.Name}}: {{end}}{{.Action}} <code>{{.Argument}}</code>
+            <span class="{{if .Passed}}passed">ok{{else}}failed">failed{{end}}</span> ({{.DurationMS}} ms)</h3>
+        {{if .Detail}}<p>{{.Detail}}</p>{{end}}
+        {{if .Text}}<pre>{{.Text}}</pre>{{end}}
+        {{if .ScreenshotFile}}<a href="{{.ScreenshotFile}}"><img src="{{.ScreenshotFile}}" alt="Screen after step {{.Step}}"></a>{{end}}
+        {{if .ScreenshotError}}<p>No screenshot: {{.ScreenshotError}}</p>{{end}}
+    </div>
+    {{end}}
+</body>
+</html>`))
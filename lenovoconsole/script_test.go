@@ -0,0 +1,148 @@
+package lenovoconsole
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+const biosScript = `
+name: Enter setup
+timeout: 1m
+steps:
+  - power-cycle: true
+  - wait-for-text: Press F1
+    timeout: 5s
+  - name: open setup
+    send-keys: "{F1}"
+  - wait-for-text: Kernel panic[^\n]*
+  - sleep: 10ms
+  - screenshot: panic screen
+`
+
+func TestParseScript(t *testing.T) {
+	script, err := ParseScript([]byte(biosScript))
+	if err != nil {
+		t.Fatalf("ParseScript: %v", err)
+	}
+	if script.Name != "Enter setup" || script.Timeout != time.Minute || len(script.Steps) != 6 {
+		t.Fatalf("script = %+v", script)
+	}
+	if script.Steps[1].Timeout != 5*time.Second || script.Steps[4].Sleep != 10*time.Millisecond {
+		t.Errorf("durations not decoded: %+v", script.Steps)
+	}
+	if action, arg, _ := script.Steps[0].Action(); action != StepPower || arg != string(PowerCycle) {
+		t.Errorf("power-cycle step = %s %s", action, arg)
+	}
+
+	invalid := map[string]string{
+		"empty":         "name: nothing\n",
+		"no action":     "steps:\n  - name: idle\n",
+		"two actions":   "steps:\n  - sleep: 1s\n    send-keys: x\n",
+		"unknown field": "steps:\n  - click: here\n",
+		"bad pattern":   "steps:\n  - wait-for-text: \"(\"\n",
+		"bad power":     "steps:\n  - power: explode\n",
+		"stray timeout": "steps:\n  - sleep: 1s\n    timeout: 5s\n",
+	}
+	for name, src := range invalid {
+		if _, err := ParseScript([]byte(src)); err == nil {
+			t.Errorf("%s: script accepted", name)
+		}
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer xcc.Close()
+	c := NewConsole(ConsoleConfig{
+		BMCIP:     xcc.Addr,
+		Username:  lenovoconsoletest.DefaultUsername,
+		Password:  lenovoconsoletest.DefaultPassword,
+		PasteRate: 100,
+	})
+
+	// The page shows the POST screen until F1 is pressed, then the next screen
+	page := newFakeScreenPage(c, readScreen(t, "post-f1.png"))
+	defer page.close()
+	page.mu.Lock()
+	page.onKeys = func(keys []Keystroke) {
+		if len(keys) == 1 && keys[0].Code == "F1" {
+			page.show(readScreen(t, "kernel-panic.png"))
+		}
+	}
+	page.mu.Unlock()
+
+	script, err := ParseScript([]byte(biosScript))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progress []int
+	report, err := c.RunScript(context.Background(), script, ScriptOptions{
+		OCR:          fixtureOCR(t),
+		PollInterval: 10 * time.Millisecond,
+		Progress:     func(r ScriptStepResult) { progress = append(progress, r.Step) },
+	})
+	if err != nil {
+		t.Fatalf("RunScript: %v", err)
+	}
+	if !report.Passed || len(report.Steps) != 6 || len(progress) != 6 {
+		t.Fatalf("report = %+v", report)
+	}
+	if got := strings.Join(xcc.Resets(), ","); got != "PowerCycle" {
+		t.Errorf("resets = %s", got)
+	}
+	if m := report.Steps[3].Detail; !strings.HasPrefix(m, "matched Kernel panic") {
+		t.Errorf("wait-for-text detail = %q", m)
+	}
+	for _, step := range report.Steps {
+		if step.Screenshot == nil {
+			t.Errorf("step %d has no screenshot: %s", step.Step, step.ScreenshotError)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := report.WriteDir(dir); err != nil {
+		t.Fatalf("WriteDir: %v", err)
+	}
+	for _, name := range []string{"report.json", "report.html", "03-open-setup.png", "06-panic-screen.png"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("report file missing: %v", err)
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "report.json"))
+	var decoded ScriptReport
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Steps[5].ScreenshotFile != "06-panic-screen.png" {
+		t.Errorf("report.json = %s", data)
+	}
+}
+
+func TestRunScriptFailure(t *testing.T) {
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1"})
+	page := newFakeScreenPage(c, readScreen(t, "post-f1.png"))
+	defer page.close()
+
+	script, err := ParseScript([]byte("steps:\n  - wait-for-text: \"login:\"\n    timeout: 50ms\n  - send-keys: root{Enter}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := c.RunScript(context.Background(), script, ScriptOptions{OCR: fixtureOCR(t), PollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("RunScript: %v", err)
+	}
+	if report.Passed || len(report.Steps) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	step := report.Steps[0]
+	if step.Passed || !strings.Contains(step.Detail, "did not appear") || !strings.Contains(step.Text, "Press F1") || step.Screenshot == nil {
+		t.Errorf("failed step = %+v", step)
+	}
+	if len(page.typed) != 0 {
+		t.Error("steps after the failure were run")
+	}
+}
@@ -0,0 +1,105 @@
+package lenovoconsole
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// RPSession describes a remote presence (KVM) session that is active on the XCC
+type RPSession struct {
+	ID            string `json:"id"`
+	UserName      string `json:"userName"`
+	ClientAddress string `json:"clientAddress,omitempty"`
+	Created       string `json:"created,omitempty"`
+}
+
+// GetRPSessions lists the remote console sessions currently open on the XCC.
+// Sessions are read from the Redfish SessionService and filtered to the KVM-IP
+// session type, so web UI and Redfish API sessions are not reported.
+func GetRPSessions(bmcIP, username, password string) ([]RPSession, error) {
+	return getRPSessions(nil, bmcIP, username, password)
+}
+
+// getRPSessions is GetRPSessions connecting through d
+func getRPSessions(d Dialer, bmcIP, username, password string) ([]RPSession, error) {
+	rf := newRedfishClient(d, bmcIP, username, password)
+
+	paths, err := rf.members("/redfish/v1/SessionService/Sessions")
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []RPSession{}
+	for _, path := range paths {
+		var s struct {
+			ID                    string `json:"Id"`
+			UserName              string `json:"UserName"`
+			SessionType           string `json:"SessionType"`
+			ClientOriginIPAddress string `json:"ClientOriginIPAddress"`
+			CreatedTime           string `json:"CreatedTime"`
+			Oem                   struct {
+				Lenovo struct {
+					SessionType string `json:"SessionType"`
+					ClientIP    string `json:"ClientIP"`
+				} `json:"Lenovo"`
+			} `json:"Oem"`
+		}
+		if err := rf.get(path, &s); err != nil {
+			// Sessions can end between listing and fetching them
+			continue
+		}
+
+		sessionType := s.SessionType
+		if sessionType == "" {
+			sessionType = s.Oem.Lenovo.SessionType
+		}
+		if !isRPSessionType(sessionType) {
+			continue
+		}
+
+		address := s.ClientOriginIPAddress
+		if address == "" {
+			address = s.Oem.Lenovo.ClientIP
+		}
+		sessions = append(sessions, RPSession{
+			ID:            s.ID,
+			UserName:      s.UserName,
+			ClientAddress: address,
+			Created:       s.CreatedTime,
+		})
+	}
+	return sessions, nil
+}
+
+// isRPSessionType reports whether a Redfish session type denotes a remote console session
+func isRPSessionType(t string) bool {
+	switch strings.ToLower(t) {
+	case "kvmip", "hostconsole", "remotepresence", "remoteconsole":
+		return true
+	}
+	return false
+}
+
+// ActiveSessions lists the remote console sessions currently open on the console's XCC
+func (c *Console) ActiveSessions() ([]RPSession, error) {
+	return getRPSessions(c.dialer, c.config.BMCIP, c.config.Username, c.config.Password)
+}
+
+// sessionsHandler reports active remote console sessions to the page as JSON
+func (c *Console) sessionsHandler(w http.ResponseWriter, r *http.Request) {
+	result := struct {
+		Sessions []RPSession `json:"sessions"`
+		Error    string      `json:"error,omitempty"`
+	}{}
+
+	sessions, err := c.ActiveSessions()
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Sessions = sessions
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(result)
+}
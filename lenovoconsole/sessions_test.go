@@ -0,0 +1,110 @@
+package lenovoconsole
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRedfish serves fixed Redfish bodies by path over TLS; other paths answer 404
+func fakeRedfish(t *testing.T, bodies map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, ok := r.BasicAuth(); !ok || user != "USERID" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, ok := bodies[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetRPSessions(t *testing.T) {
+	server := fakeRedfish(t, map[string]string{
+		"/redfish/v1/SessionService/Sessions": `{"Members": [
+			{"@odata.id": "/redfish/v1/SessionService/Sessions/1"},
+			{"@odata.id": "/redfish/v1/SessionService/Sessions/2"},
+			{"@odata.id": "/redfish/v1/SessionService/Sessions/3"},
+			{"@odata.id": "/redfish/v1/SessionService/Sessions/ended"},
+			{"@odata.id": "/redfish/v1/SessionService/Sessions/5"}]}`,
+		// Standard Redfish session type and address
+		"/redfish/v1/SessionService/Sessions/1": `{"Id": "1", "UserName": "alice", "SessionType": "KVMIP",
+			"ClientOriginIPAddress": "10.0.0.50", "CreatedTime": "2026-10-18T09:00:00+00:00"}`,
+		// Web UI sessions are not remote consoles
+		"/redfish/v1/SessionService/Sessions/2": `{"Id": "2", "UserName": "bob", "SessionType": "WebUI"}`,
+		// Older XCC firmware reports both in the Lenovo OEM section
+		"/redfish/v1/SessionService/Sessions/3": `{"Id": "3", "UserName": "carol",
+			"Oem": {"Lenovo": {"SessionType": "RemoteConsole", "ClientIP": "10.0.0.51"}}}`,
+		"/redfish/v1/SessionService/Sessions/5": `not json`,
+	})
+
+	sessions, err := GetRPSessions(server.Listener.Addr().String(), "USERID", "PASSW0RD")
+	if err != nil {
+		t.Fatalf("GetRPSessions: %v", err)
+	}
+	want := []RPSession{
+		{ID: "1", UserName: "alice", ClientAddress: "10.0.0.50", Created: "2026-10-18T09:00:00+00:00"},
+		{ID: "3", UserName: "carol", ClientAddress: "10.0.0.51"},
+	}
+	if !reflect.DeepEqual(sessions, want) {
+		t.Errorf("sessions = %+v\nwant %+v", sessions, want)
+	}
+}
+
+func TestGetRPSessionsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		bodies   map[string]string
+		username string
+		want     string // substring of the error, empty for no sessions and no error
+	}{
+		{"no sessions", map[string]string{"/redfish/v1/SessionService/Sessions": `{"Members": []}`}, "USERID", ""},
+		{"credentials rejected", map[string]string{"/redfish/v1/SessionService/Sessions": `{"Members": []}`}, "nobody", "unexpected HTTP 401"},
+		{"no session service", nil, "USERID", "unexpected HTTP 404"},
+		{"invalid collection", map[string]string{"/redfish/v1/SessionService/Sessions": `<html>`}, "USERID", "invalid response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeRedfish(t, tt.bodies)
+			sessions, err := GetRPSessions(server.Listener.Addr().String(), tt.username, "PASSW0RD")
+			if tt.want == "" {
+				// An empty list, not nil, so the page receives [] rather than null
+				if err != nil || sessions == nil || len(sessions) != 0 {
+					t.Errorf("GetRPSessions = %#v, %v", sessions, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("GetRPSessions error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionsHandler(t *testing.T) {
+	server := fakeRedfish(t, nil)
+	c := NewConsole(ConsoleConfig{BMCIP: server.Listener.Addr().String(), Username: "USERID", Password: "PASSW0RD"})
+	rec := httptest.NewRecorder()
+	c.sessionsHandler(rec, httptest.NewRequest("GET", "/api/sessions", nil))
+
+	var result struct {
+		Sessions []RPSession `json:"sessions"`
+		Error    string      `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result.Error, "unexpected HTTP 404") || len(result.Sessions) != 0 {
+		t.Errorf("sessions response = %+v", result)
+	}
+}
@@ -0,0 +1,170 @@
+package lenovoconsole
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReconnectPolicy configures the reconnect supervisor. When enabled, the console
+// watches session events posted by the page and, after a termination with one of
+// the listed reasons, waits for the BMC to come back, re-reads the RP port and
+// tells the page to reconnect, backing off between attempts.
+type ReconnectPolicy struct {
+	Enabled      bool          // Run the supervisor
+	Reasons      []int         // Termination reasons that trigger a reconnect (default: timeout, WebSocket error, reboot, upgrade)
+	InitialDelay time.Duration // Delay before the first attempt (default: 5s)
+	MaxDelay     time.Duration // Upper bound for the exponential backoff (default: 2m)
+	MaxAttempts  int           // Attempts before giving up (0 for unlimited)
+	LoginTimeout time.Duration // How long to wait for the page to report a login result (default: 60s)
+}
+
+// defaultReconnectReasons are the termination reasons after which the BMC is expected to return
+var defaultReconnectReasons = []int{1, 2, 3, 4}
+
+// withDefaults fills in zero fields of the policy
+func (p ReconnectPolicy) withDefaults() ReconnectPolicy {
+	if len(p.Reasons) == 0 {
+		p.Reasons = defaultReconnectReasons
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = 5 * time.Second
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 2 * time.Minute
+	}
+	if p.LoginTimeout <= 0 {
+		p.LoginTimeout = 60 * time.Second
+	}
+	return p
+}
+
+// handlePageEvent starts the supervisor when the page reports a recoverable termination
+func (c *Console) handlePageEvent(e Event) {
+	policy := c.config.Reconnect.withDefaults()
+	if !c.config.Reconnect.Enabled || e.Type != EventSessionTerminated {
+		return
+	}
+	for _, reason := range policy.Reasons {
+		if reason == e.Code {
+			go c.superviseReconnect(policy)
+			return
+		}
+	}
+}
+
+// superviseReconnect retries the session with exponential backoff until it succeeds,
+// the attempts run out or the console is stopped
+func (c *Console) superviseReconnect(policy ReconnectPolicy) {
+	c.mu.Lock()
+	if c.reconnecting {
+		c.mu.Unlock()
+		return
+	}
+	c.reconnecting = true
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.reconnecting = false
+		c.mu.Unlock()
+	}()
+
+	events, cancel := c.Subscribe()
+	defer cancel()
+
+	delay := policy.InitialDelay
+	for attempt := 1; policy.MaxAttempts == 0 || attempt <= policy.MaxAttempts; attempt++ {
+		c.emit(Event{Type: EventReconnectAttempt, Attempt: attempt,
+			Message: fmt.Sprintf("Reconnecting in %s", delay)})
+		c.sendCommand(pageCommand{Name: "status",
+			Message: fmt.Sprintf("Waiting for BMC, reconnect attempt %d in %s...", attempt, delay)})
+
+		select {
+		case <-time.After(delay):
+		case <-c.stopCh:
+			return
+		}
+		delay *= 2
+		if delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+
+		retry := func(reason string) {
+			c.emit(Event{Type: EventReconnectRetryFailed, Attempt: attempt, Message: reason})
+		}
+		if !bmcReachable(c.dialer, c.config.BMCIP, 5*time.Second) {
+			retry("BMC not reachable")
+			continue
+		}
+
+		// The RP port may change when the BMC restarts; without it the page would
+		// reconnect to the wrong port, so try again after the backoff
+		port, err := c.DiscoverRPPort()
+		if err != nil {
+			retry(fmt.Sprintf("Could not read the RP port: %v", err))
+			continue
+		}
+		c.SetRPPort(port)
+
+		if c.sendCommand(pageCommand{Name: "reconnect", RPPort: port}) == 0 {
+			// No page is listening; keep waiting for one to appear
+			retry("No console page open")
+			continue
+		}
+
+		if c.awaitLogin(events, policy.LoginTimeout) {
+			c.emit(Event{Type: EventReconnected, Attempt: attempt, Message: "Session restored"})
+			return
+		}
+		retry("Login not confirmed by the page")
+	}
+
+	c.emit(Event{Type: EventReconnectFailed, Message: "Giving up after maximum reconnect attempts"})
+	c.sendCommand(pageCommand{Name: "status", Message: "❌ Could not reconnect to the BMC"})
+}
+
+// awaitLogin waits for the page to report a login result and reports whether it succeeded
+func (c *Console) awaitLogin(events <-chan Event, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case e := <-events:
+			if e.Type == EventLoginResult {
+				return e.Code == 0
+			}
+		case <-deadline:
+			return false
+		case <-c.stopCh:
+			return false
+		}
+	}
+}
+
+// rpPort returns the RP port, which the supervisor changes while the console runs
+func (c *Console) rpPort() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.config.RPPort
+}
+
+// SetRPPort sets the remote presence port the page connects to, such as a default
+// after DiscoverRPPort failed. Once the console is initialized the page is regenerated,
+// so reloads use the new port.
+func (c *Console) SetRPPort(port int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if port == c.config.RPPort {
+		return
+	}
+	c.config.RPPort = port
+	if c.consoleHTML == "" {
+		return
+	}
+	if err := c.generateHTML(); err != nil {
+		fmt.Printf("Failed to regenerate console page for BMC %s: %v\n", c.config.BMCIP, err)
+	}
+}
+
+// bmcReachable reports whether the BMC accepts connections on its HTTPS port
+func bmcReachable(d Dialer, bmcIP string, timeout time.Duration) bool {
+	return dialReachable(d, parseBMCAddressLenient(bmcIP).HostPort(), timeout) == nil
+}
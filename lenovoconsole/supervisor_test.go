@@ -0,0 +1,129 @@
+package lenovoconsole
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+// postPageEvent posts a session event as the console page does
+func postPageEvent(t *testing.T, c *Console, body string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, pageRequest(c, "/api/events", "application/json", strings.NewReader(body)))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("posting %s: status %d", body, rec.Code)
+	}
+}
+
+// awaitEvent returns the first event of type want, failing after timeout
+func awaitEvent(t *testing.T, events <-chan Event, want EventType, timeout time.Duration) Event {
+	t.Helper()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case e := <-events:
+			if e.Type == want {
+				return e
+			}
+		case <-deadline:
+			t.Fatalf("no %s event", want)
+			return Event{}
+		}
+	}
+}
+
+// supervisedConsole initializes a console for xcc with a fast reconnect policy and
+// registers a page listening for its commands
+func supervisedConsole(t *testing.T, xcc *lenovoconsoletest.Server, maxAttempts int) (*Console, chan pageCommand) {
+	t.Helper()
+	c := NewConsole(ConsoleConfig{
+		BMCIP:     xcc.Addr,
+		Username:  lenovoconsoletest.DefaultUsername,
+		Password:  lenovoconsoletest.DefaultPassword,
+		Reconnect: ReconnectPolicy{Enabled: true, InitialDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond, MaxAttempts: maxAttempts, LoginTimeout: 5 * time.Second},
+	})
+	if err := c.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	t.Cleanup(func() { c.Stop() })
+	page := make(chan pageCommand, 16)
+	c.mu.Lock()
+	c.pages[page] = false
+	c.mu.Unlock()
+	return c, page
+}
+
+func TestSupervisorRetriesWhenRPPortFails(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{RPPort: 3901})
+	defer xcc.Close()
+	c, page := supervisedConsole(t, xcc, 2)
+	events, cancel := c.Subscribe()
+	defer cancel()
+
+	// The XCC is back on the network, but its rp_port API does not answer yet
+	xcc.FailRPPort(2)
+	postPageEvent(t, c, `{"type":"terminated","code":3}`)
+
+	for i := 0; i < 2; i++ {
+		if e := awaitEvent(t, events, EventReconnectRetryFailed, 5*time.Second); !strings.Contains(e.Message, "RP port") {
+			t.Errorf("retry event = %+v", e)
+		}
+	}
+	awaitEvent(t, events, EventReconnectFailed, 5*time.Second)
+	for len(page) > 0 {
+		if cmd := <-page; cmd.Name == "reconnect" {
+			t.Errorf("page told to reconnect to port %d without an answer from the rp_port API", cmd.RPPort)
+		}
+	}
+	if port := c.rpPort(); port != 3901 {
+		t.Errorf("RP port = %d, want 3901", port)
+	}
+}
+
+func TestSupervisorReconnectsToChangedRPPort(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{RPPort: 3901})
+	defer xcc.Close()
+	c, page := supervisedConsole(t, xcc, 0)
+	if port := c.rpPort(); port != 3901 {
+		t.Fatalf("RP port = %d", port)
+	}
+	events, cancel := c.Subscribe()
+	defer cancel()
+
+	// The XCC reboots with a new RP port, and its rp_port API is not up at first
+	xcc.SetRPPort(3955)
+	xcc.FailRPPort(2)
+	postPageEvent(t, c, `{"type":"terminated","code":3}`)
+
+	for i := 0; i < 2; i++ {
+		if e := awaitEvent(t, events, EventReconnectRetryFailed, 5*time.Second); !strings.Contains(e.Message, "HTTP 503") {
+			t.Errorf("retry event = %+v", e)
+		}
+	}
+	var reconnect pageCommand
+	for reconnect.Name != "reconnect" {
+		reconnect = nextCommand(t, page, 5*time.Second)
+	}
+	if reconnect.RPPort != 3955 {
+		t.Errorf("page told to reconnect to port %d, want 3955", reconnect.RPPort)
+	}
+	postPageEvent(t, c, `{"type":"login","code":0}`)
+	if e := awaitEvent(t, events, EventReconnected, 5*time.Second); e.Attempt != 3 {
+		t.Errorf("reconnected = %+v, want attempt 3", e)
+	}
+
+	// Reloads of the page use the new port
+	if port := c.rpPort(); port != 3955 {
+		t.Errorf("RP port after the reconnect = %d", port)
+	}
+	rec := httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rec.Body.String(), "rpPort:  3955 ") {
+		t.Error("page not regenerated with the new RP port")
+	}
+}
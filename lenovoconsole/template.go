@@ -1,10 +1,11 @@
 package lenovoconsole
 
-// htmlTemplate contains the HTML template for the console viewer
-const htmlTemplate = `<!DOCTYPE html>
-<html>
-<head>
-    <title>Lenovo XCC Remote Console</title>
+// Built-in page templates. Every layout is parsed together with consolePartials,
+// so custom templates can reuse the "styles", "certInstructions", "sessionPanel", "pastePanel"
+// and "viewer" blocks.
+
+// consolePartials holds the blocks shared by all console page layouts
+const consolePartials = `{{define "styles"}}
     <style>
         body {
             margin: 0;
@@ -59,6 +60,11 @@ const htmlTemplate = `<!DOCTYPE html>
         #certInstructions li {
             margin-bottom: 10px;
         }
+        #certFingerprint {
+            font-family: monospace;
+            font-size: 11px;
+            word-break: break-all;
+        }
         #certInstructions button {
             background: #4444ff;
             color: white;
@@ -71,50 +77,184 @@ const htmlTemplate = `<!DOCTYPE html>
         #certInstructions button:hover {
             background: #5555ff;
         }
+        #sessionPanel {
+            position: absolute;
+            top: 50%;
+            left: 50%;
+            transform: translate(-50%, -50%);
+            color: #fff;
+            background: rgba(30,30,40,0.95);
+            padding: 20px;
+            border-radius: 5px;
+            z-index: 1002;
+            min-width: 360px;
+            max-width: 500px;
+            border: 2px solid #aa8833;
+            display: none;
+        }
+        #sessionPanel h3 {
+            margin-top: 0;
+            color: #ffcc66;
+        }
+        #sessionPanel button {
+            background: #665522;
+            color: white;
+            border: none;
+            padding: 8px 15px;
+            border-radius: 3px;
+            cursor: pointer;
+            margin: 10px 5px 0 0;
+        }
+        #sessionPanel button:hover {
+            background: #887733;
+        }
+        #pastePanel {
+            position: absolute;
+            top: 50px;
+            right: 10px;
+            color: #fff;
+            background: rgba(30,30,40,0.95);
+            padding: 15px;
+            border-radius: 5px;
+            z-index: 1001;
+            width: 360px;
+            border: 2px solid #44444f;
+            display: none;
+        }
+        #pastePanel textarea {
+            width: 100%;
+            height: 120px;
+            box-sizing: border-box;
+            font-family: monospace;
+        }
+        #pastePanel button {
+            background: #33333d;
+            color: #ddd;
+            border: 1px solid #44444f;
+            padding: 6px 12px;
+            border-radius: 3px;
+            cursor: pointer;
+            margin: 8px 5px 0 0;
+        }
+        #pasteStatus.error {
+            color: #ff4444;
+        }
     </style>
-</head>
-<body>
-    <div id="status">Initializing console...</div>
-    <canvas id="kvmCanvas"></canvas>
-    
+{{end}}
+
+{{define "certInstructions"}}
     <div id="certInstructions">
         <h3>⚠️ Certificate Issue Detected</h3>
         <p>The BMC server is using a self-signed certificate that needs to be accepted.</p>
+        <p id="certDetails">SHA-256 fingerprint reported by the console server:<br>
+            <span id="certFingerprint">loading...</span></p>
         <p><strong>To fix this issue:</strong></p>
         <ol>
             <li>Click the button below to open the BMC certificate page</li>
             <li>You'll see a browser warning about the certificate</li>
-            <li>Click "Advanced" or "Show Details"</li>
+            <li>Click "Advanced" or "Show Details" and check that the fingerprint matches the one above</li>
             <li>Click "Proceed to {{.BMCIP}}" or "Accept the Risk and Continue"</li>
             <li>Come back to this tab and click "Retry Connection"</li>
         </ol>
         <button onclick="acceptCertificate()">Open BMC Certificate Page</button>
         <button onclick="retryConnection()">Retry Connection</button>
-        <button onclick="document.getElementById('certInstructions').style.display='none'">Close</button>
+        <button onclick="setCertInstructionsVisible(false)">Close</button>
+        <p><a href="cert.pem">Download the certificate</a> to import it into your browser instead.</p>
     </div>
+{{end}}
 
+{{define "sessionPanel"}}
+    <div id="sessionPanel">
+        <h3>Remote Console In Use</h3>
+        <p>These users are connected to {{.BMCIP}}:</p>
+        <ul id="sessionList"></ul>
+        <p id="sessionQueueStatus"></p>
+        <button onclick="requestSharing()">Request Sharing</button>
+        <button onclick="forceExclusive()">Force Exclusive</button>
+        <button onclick="waitInQueue()">Wait Until Free</button>
+        <button onclick="stopQueue(); sessionPanel.style.display='none'">Cancel</button>
+    </div>
+{{end}}
+
+{{define "pastePanel"}}
+    <div id="pastePanel">
+        <textarea id="pasteText" placeholder="Text to type on the remote host" autocomplete="off" spellcheck="false"></textarea>
+        <button onclick="pasteFromBox()">Type</button>
+        <button onclick="setPastePanelVisible(false)">Close</button>
+        <span id="pasteStatus"></span>
+    </div>
+{{end}}
+
+{{define "viewer"}}
     <script>
         // Console configuration
         const config = {
             bmcIP: '{{.BMCIP}}',
+            // Host and HTTPS origin of the BMC as written in URLs, with IPv6 in brackets
+            bmcHost: '{{.BMCHost}}',
+            bmcOrigin: '{{.BMCOrigin}}',
             rpPort: {{.RPPort}},
             bmcUsername: '{{.BMCUsername}}',
-            bmcPassword: '{{.BMCPassword}}'
+            bmcPassword: '{{.BMCPassword}}',
+            // Sent with POST requests so that the server can tell them from forged ones
+            pageToken: '{{.PageToken}}'
         };
 
-        const statusDiv = document.getElementById('status');
+        // RPViewer settings from ConsoleConfig.Viewer, rendered as JSON
+        const viewerOptions = {{.Viewer}};
+
+        // Thumbnail mode, used by the console wall: ?thumbnail=N shows a view-only console
+        // whose picture is redrawn every N seconds (0 for a live picture)
+        const thumbnailParam = new URLSearchParams(window.location.search || '').get('thumbnail');
+        const thumbnailMode = thumbnailParam !== null;
+        if (thumbnailMode) {
+            viewerOptions.mouseInput = false;
+            viewerOptions.touchInput = false;
+            viewerOptions.keyboardInput = false;
+            viewerOptions.exclusiveLogin = false;
+            viewerOptions.sessionPrompt = false;
+        }
+
+        // Layouts may omit the status overlay, the certificate panel or the toolbar
+        const statusDiv = document.getElementById('status') || document.createElement('div');
+        const certPanel = document.getElementById('certInstructions');
+
+        function setCertInstructionsVisible(visible) {
+            if (certPanel) {
+                certPanel.style.display = visible ? 'block' : 'none';
+            }
+            if (visible) {
+                showCertificateFingerprint();
+            }
+        }
+
+        // Ask the Go server for the RP-port certificate so the operator can compare its
+        // fingerprint with the one in the browser warning
+        function showCertificateFingerprint() {
+            const fingerprint = document.getElementById('certFingerprint');
+            if (!fingerprint) {
+                return;
+            }
+            fetch('/api/certificate', {cache: 'no-store'})
+                .then(response => response.ok ? response.json() : Promise.reject(new Error('HTTP ' + response.status)))
+                .then(cert => {
+                    fingerprint.textContent = cert.fingerprint + (cert.trusted ? ' (trusted by the console)' : '');
+                })
+                .catch(error => {
+                    fingerprint.textContent = 'unavailable (' + error.message + ')';
+                });
+        }
+
+        function viewerHeight() {
+            const toolbar = document.getElementById('toolbar');
+            return window.innerHeight - (toolbar ? toolbar.offsetHeight : 0);
+        }
         let scriptsLoaded = 0;
-        const requiredScripts = [
-            '/SDK_Pilot4/utility.js',
-            '/SDK_Pilot4/rpimage.js', 
-            '/SDK_Pilot4/rprecorder.js',
-            '/SDK_Pilot4/rpviewer.js',
-			'/SDK_Pilot4/rphandlers.js',
-			'/SDK_Pilot4/websockethandler.js',
-			'/SDK_Pilot4/virtualkeyboard.js',
-			'/SDK_Pilot4/mediaTypes.js',
-			'/SDK_Pilot4/mediaworkerhandler.js'
-        ];
+        // Viewer SDK scripts on the BMC, listed by the console's provider
+        const requiredScripts = {{.Scripts}};
+
+        // Provider script defining createViewer and sdkScriptFallback
+{{.ViewerScript}}
 
         function updateStatus(message, isError) {
             statusDiv.innerHTML = message;
@@ -125,7 +265,7 @@ const htmlTemplate = `<!DOCTYPE html>
 
         function loadScript(src, callback, errorCallback) {
             const script = document.createElement('script');
-            script.src = 'https://' + config.bmcIP + src;
+            script.src = config.bmcOrigin + src;
             script.onload = callback;
             script.onerror = errorCallback || function() {
                 console.error('Failed to load:', src);
@@ -149,10 +289,16 @@ const htmlTemplate = `<!DOCTYPE html>
                     loadNextScript(index + 1);
                 },
                 function() {
-                    // Try alternative path without /designs/imm
-                    const altPath = requiredScripts[index].replace('/designs/imm', '');
+                    // The provider may know another place for the script
+                    const altPath = sdkScriptFallback(requiredScripts[index]);
+                    if (!altPath) {
+                        updateStatus('❌ ERROR: Could not load ' + scriptName + ' from ' +
+                                   config.bmcOrigin + requiredScripts[index] + '<br><br>' +
+                                   'Please check browser console for details.', true);
+                        return;
+                    }
                     console.log('Trying alternative path:', altPath);
-                    
+
                     loadScript(
                         altPath,
                         function() {
@@ -162,8 +308,8 @@ const htmlTemplate = `<!DOCTYPE html>
                         function() {
                             updateStatus('❌ ERROR: Could not load ' + scriptName + '<br>' +
                                        'Tried paths:<br>' +
-                                       '- https://' + config.bmcIP + requiredScripts[index] + '<br>' +
-                                       '- https://' + config.bmcIP + altPath + '<br><br>' +
+                                       '- ' + config.bmcOrigin + requiredScripts[index] + '<br>' +
+                                       '- ' + config.bmcOrigin + altPath + '<br><br>' +
                                        'Please check browser console for details.', true);
                         }
                     );
@@ -174,90 +320,68 @@ const htmlTemplate = `<!DOCTYPE html>
         updateStatus('⚠️ Loading Lenovo RPViewer libraries...');
         loadNextScript(0);
 
-        // Certificate acceptance handler
-        function handleCertificateAcceptance(viewer) {
-            // Note: Direct iframe approach won't work due to CSP restrictions
-            // The user will need to manually accept the certificate if prompted
-            console.log('Certificate handling will be done through RPViewer dialog');
+        // startThumbnail hides the page chrome and, with a refresh interval, shows a copy of
+        // the console canvas that is only redrawn every interval seconds
+        function startThumbnail(interval) {
+            ['toolbar', 'pastePanel', 'certInstructions'].forEach(id => {
+                const element = document.getElementById(id);
+                if (element) {
+                    element.style.display = 'none';
+                }
+            });
+            if (interval <= 0) {
+                return;
+            }
+            const source = document.getElementById('kvmCanvas');
+            const copy = document.createElement('canvas');
+            copy.id = 'thumbnailCanvas';
+            copy.style.position = 'absolute';
+            copy.style.top = '0';
+            copy.style.left = '0';
+            copy.style.width = '100%';
+            copy.style.height = '100%';
+            copy.style.background = viewerOptions.backgroundColor;
+            document.body.appendChild(copy);
+            source.style.visibility = 'hidden';
+
+            const redraw = function() {
+                if (!source.width || !source.height) {
+                    return;
+                }
+                copy.width = source.width;
+                copy.height = source.height;
+                copy.getContext('2d').drawImage(source, 0, 0);
+            };
+            setInterval(redraw, Math.max(interval, 0.2) * 1000);
         }
 
         function initializeViewer() {
             updateStatus('✓ All libraries loaded. Initializing viewer...');
             
             try {
-                // Check if RPViewer is available
-                if (typeof RPViewer === 'undefined') {
-                    throw new Error('RPViewer class not found. Check that all scripts loaded correctly.');
-                }
+                // The console's provider creates and configures the viewer
+                const viewer = createViewer('kvmCanvas', config, viewerOptions, {
+                    error: viewerAPIErrorCallback,
+                    login: loginResponseCallback,
+                    uiInit: uiInitCallback,
+                    exit: exitViewerCallback,
+                    resolution: resolutionCallback,
+                    terminated: sessionTermCallback
+                });
+                viewer.setRPEmbeddedViewerSize(window.innerWidth, viewerHeight());
 
-                console.log('Initializing RPViewer...');
-                
-                // Initialize RPViewer (based on the RemoteConsoleWindow.js code)
-                const viewer = new RPViewer('kvmCanvas', viewerAPIErrorCallback);
-                
-                console.log('RPViewer created, configuring...');
-                
-                // Configure viewer
-                viewer.setRPWebSocketTimeout(30);
-                
-                // Certificate handling - try without setting cert file
-                // Since the certificate is already accepted, we might not need this
-                // viewer.setRPCertFileName('/cert.pem');
-                
-                // Set server configuration
-                viewer.setRPServerConfiguration(config.bmcIP, config.rpPort);
-                viewer.setRPEmbeddedViewerSize(window.innerWidth, window.innerHeight - 50);
-                
-                // Connection settings - Multi User Mode
-                viewer.setRPExclusiveLogin(false); // Use multi-user mode (non-exclusive)
-                viewer.setRPAllowSharingRequests(true); // Allow sharing requests for multi-user mode
-                
-                // Input support
-                viewer.setRPMouseInputSupport(true);
-                viewer.setRPTouchInputSupport(true);
-                viewer.setRPKeyboardInputSupport(true);
-                
-                // Debug settings
-                viewer.setRPDebugMode(true);
-                viewer.setRPDebugLevel(1);
-                
-                // Display settings
-                viewer.setRPMaintainAspectRatio(true);
-                viewer.setRPInitialBackgroundColor('black');
-                viewer.setRPInitialMessageColor('white');
-                viewer.setRPKeyboardLanguage('en');
-                
-                // Reconnection settings
-                viewer.setRPSupportReconnect(true);
-                viewer.setRPLinkInterruptMessageColor('red');
-                viewer.setRPLinkInterruptMessage('Connection interrupted. Attempting to reconnect...');
-                viewer.setRPReconnectingMessage('Reconnecting to remote console...');
-                viewer.setRPInitialMessage('Connecting to remote console...');
-                
-                // Set credentials (using BMC credentials directly)
-                console.log('Setting credentials with BMC user:', config.bmcUsername);
-                viewer.setRPCredential(config.bmcUsername, config.bmcPassword);
-                
-                // Register callbacks
-                viewer.registerRPLoginResponseCallback(loginResponseCallback);
-                viewer.registerRPUIInitCallback(uiInitCallback);
-                viewer.registerRPExitViewerCallback(exitViewerCallback);
-                viewer.registerRPResolutionCallback(resolutionCallback);
-                viewer.registerRPSessionTerminationCallback(sessionTermCallback);
-                
                 // Store viewer globally for debugging
                 window.rpViewer = viewer;
+                if (thumbnailMode) {
+                    startThumbnail(parseFloat(thumbnailParam) || 0);
+                }
                 
-                // Pre-accept certificate by opening the BMC URL
-                handleCertificateAcceptance(viewer);
-                
-                // Connect after a short delay to allow certificate pre-acceptance
-                updateStatus('Accepting BMC certificate and connecting to ' + config.bmcIP + ':' + config.rpPort + '...');
+                // Connect after a short delay to let the viewer settle
+                updateStatus('Connecting to ' + config.bmcHost + ':' + config.rpPort + '...');
                 console.log('Preparing to connect...');
                 
                 setTimeout(() => {
-                    console.log('Calling connectRPViewer...');
-                    viewer.connectRPViewer();
+                    negotiateSession();
                 }, 1000);
                 
             } catch (error) {
@@ -267,8 +391,253 @@ const htmlTemplate = `<!DOCTYPE html>
             }
         }
 
+        // Report session events to the Go server, which may supervise reconnects
+        function postEvent(type, code) {
+            fetch('/api/events', {
+                method: 'POST',
+                headers: {'Content-Type': 'application/json', 'X-Console-Token': config.pageToken},
+                body: JSON.stringify({type: type, code: code || 0})
+            }).catch(error => console.log('Could not report event:', error));
+        }
+
+        // Heartbeats tell the Go server the console is in use, so that its idle timeout
+        // only counts time without keyboard, mouse or touch input
+        let inputSeen = false;
+        let limitReason = '';
+
+        function sendHeartbeat() {
+            inputSeen = false;
+            fetch('/api/heartbeat', {method: 'POST', headers: {'X-Console-Token': config.pageToken}})
+                .catch(error => console.log('Could not send heartbeat:', error));
+        }
+
+        ['keydown', 'mousedown', 'mousemove', 'wheel', 'touchstart'].forEach(type => {
+            document.addEventListener(type, function() {
+                if (limitReason === 'idle' && !inputSeen) {
+                    // Input during an idle warning keeps the console open right away
+                    sendHeartbeat();
+                }
+                inputSeen = true;
+            }, {capture: true, passive: true});
+        });
+
+        setInterval(function() {
+            if (inputSeen) {
+                sendHeartbeat();
+            }
+        }, 30000);
+
+        // Session limit warning, shown until the limit is hit or activity resumes
+        let limitTimer = null;
+
+        function showLimitBanner(reason, message, seconds) {
+            limitReason = reason || '';
+            clearInterval(limitTimer);
+            let banner = document.getElementById('limitBanner');
+            if (!message) {
+                if (banner) {
+                    banner.style.display = 'none';
+                }
+                return;
+            }
+            if (!banner) {
+                banner = document.createElement('div');
+                banner.id = 'limitBanner';
+                banner.style.cssText = 'position: fixed; left: 0; right: 0; bottom: 0; padding: 10px; ' +
+                    'text-align: center; background: #aa6600; color: #fff; font-family: Arial, sans-serif; z-index: 1003;';
+                document.body.appendChild(banner);
+            }
+            const deadline = Date.now() + seconds * 1000;
+            const render = function() {
+                const left = Math.max(0, Math.round((deadline - Date.now()) / 1000));
+                banner.textContent = '⚠️ ' + message + ' in ' + left + 's' +
+                    (limitReason === 'idle' ? '. Use the console to keep it open.' : '.');
+            };
+            render();
+            limitTimer = setInterval(render, 1000);
+            banner.style.display = 'block';
+        }
+
+        // Paste: text is translated into key presses by the Go server for the remote
+        // keyboard layout, then typed through the provider's sendKeystroke at a limited rate
+        const pasteQueue = [];
+        let pasting = false;
+
+        function setPastePanelVisible(visible) {
+            const panel = document.getElementById('pastePanel');
+            if (panel) {
+                panel.style.display = visible ? 'block' : 'none';
+            }
+        }
+
+        function setPasteStatus(message, isError) {
+            const status = document.getElementById('pasteStatus');
+            if (status) {
+                status.textContent = message;
+                status.className = isError ? 'error' : '';
+            }
+        }
+
+        function pasteFromBox(confirmed) {
+            const box = document.getElementById('pasteText');
+            if (!box || !box.value) {
+                return;
+            }
+            fetch('/api/paste', {
+                method: 'POST',
+                headers: {'Content-Type': 'application/json', 'X-Console-Token': config.pageToken},
+                body: JSON.stringify({text: box.value, confirmed: !!confirmed})
+            })
+                .then(response => response.ok ? response.json() :
+                    response.text().then(text => Promise.reject(new Error(text.trim()))))
+                .then(result => {
+                    if (result.confirm) {
+                        if (window.confirm('Type ' + result.length + ' characters on the remote host?')) {
+                            pasteFromBox(true);
+                        }
+                        return;
+                    }
+                    box.value = '';
+                    typeKeys(result.keys, result.rate);
+                })
+                .catch(error => setPasteStatus(error.message, true));
+        }
+
+        function typeKeys(keys, rate, id) {
+            pasteQueue.push({keys: keys || [], rate: rate || 20, id: id});
+            if (!pasting) {
+                nextPaste();
+            }
+        }
+
+        // reportTyped tells the server that a job with a request id has been typed, or
+        // why it was not
+        function reportTyped(job, error) {
+            if (!job.id) {
+                return;
+            }
+            let url = '/api/typed?id=' + encodeURIComponent(job.id);
+            if (error) {
+                url += '&error=' + encodeURIComponent(error);
+            }
+            fetch(url, {method: 'POST', headers: {'X-Console-Token': config.pageToken}})
+                .catch(e => console.log('Typing result not delivered:', e));
+        }
+
+        function nextPaste() {
+            const job = pasteQueue.shift();
+            if (!job) {
+                pasting = false;
+                setPasteStatus('');
+                return;
+            }
+            pasting = true;
+            let index = 0;
+            const timer = setInterval(function() {
+                if (index >= job.keys.length) {
+                    clearInterval(timer);
+                    reportTyped(job);
+                    nextPaste();
+                    return;
+                }
+                if (!window.rpViewer || !sendKeystroke(window.rpViewer, job.keys[index])) {
+                    clearInterval(timer);
+                    const message = window.rpViewer ? 'This viewer has no keyboard API, cannot type' :
+                        'The viewer is not connected';
+                    reportTyped(job, message);
+                    pasteQueue.forEach(queued => reportTyped(queued, message));
+                    pasteQueue.length = 0;
+                    pasting = false;
+                    setPasteStatus(message, true);
+                    return;
+                }
+                index++;
+                setPasteStatus('Typing ' + index + '/' + job.keys.length + '...');
+            }, 1000 / job.rate);
+        }
+
+        // captureScreen exports the console canvas as PNG and posts it back for the
+        // Screenshot request id
+        function captureScreen(id) {
+            const url = '/api/screenshot?id=' + encodeURIComponent(id);
+            const fail = function(error) {
+                fetch(url + '&error=' + encodeURIComponent(String(error)),
+                    {method: 'POST', headers: {'X-Console-Token': config.pageToken}})
+                    .catch(e => console.log('Screenshot error not delivered:', e));
+            };
+            const canvas = document.getElementById('kvmCanvas');
+            if (!canvas.width || !canvas.height) {
+                fail('the viewer has not drawn the screen yet');
+                return;
+            }
+            try {
+                canvas.toBlob(function(blob) {
+                    if (!blob) {
+                        fail('the canvas could not be exported');
+                        return;
+                    }
+                    fetch(url, {method: 'POST', headers: {'Content-Type': 'image/png', 'X-Console-Token': config.pageToken}, body: blob})
+                        .catch(e => console.log('Screenshot not delivered:', e));
+                }, 'image/png');
+            } catch(e) {
+                fail(e);
+            }
+        }
+
+        // Commands pushed from the Go server
+        if (window.EventSource) {
+            const control = new EventSource(thumbnailMode ? '/api/control?thumbnail' : '/api/control');
+            control.addEventListener('status', function(event) {
+                const command = JSON.parse(event.data);
+                statusDiv.style.display = 'block';
+                updateStatus(command.message);
+            });
+            control.addEventListener('limit', function(event) {
+                const command = JSON.parse(event.data);
+                showLimitBanner(command.reason, command.message, command.seconds);
+            });
+            control.addEventListener('shutdown', function(event) {
+                const command = JSON.parse(event.data);
+                control.close();
+                showLimitBanner();
+                if (window.rpViewer && typeof window.rpViewer.disconnectRPViewer === 'function') {
+                    try {
+                        window.rpViewer.disconnectRPViewer();
+                    } catch(e) {
+                        console.log('Could not disconnect viewer:', e);
+                    }
+                }
+                statusDiv.style.display = 'block';
+                updateStatus(command.message, true);
+            });
+            control.addEventListener('paste', function(event) {
+                const command = JSON.parse(event.data);
+                typeKeys(command.keys, command.rate, command.id);
+            });
+            control.addEventListener('screenshot', function(event) {
+                const command = JSON.parse(event.data);
+                captureScreen(command.id);
+            });
+            control.addEventListener('reconnect', function(event) {
+                const command = JSON.parse(event.data);
+                if (!window.rpViewer) {
+                    location.reload();
+                    return;
+                }
+                if (command.rpPort) {
+                    config.rpPort = command.rpPort;
+                    window.rpViewer.setRPServerConfiguration(config.bmcHost, config.rpPort);
+                }
+                statusDiv.style.display = 'block';
+                statusDiv.className = '';
+                updateStatus('Reconnecting to ' + config.bmcHost + ':' + config.rpPort + '...');
+                connectViewer(viewerOptions.exclusiveLogin);
+            });
+        }
+
         function exitViewerCallback() {
             console.log('Exit viewer callback');
+            postEvent('exit');
             updateStatus('Console session ended', true);
         }
 
@@ -278,6 +647,7 @@ const htmlTemplate = `<!DOCTYPE html>
 
         function sessionTermCallback(reason) {
             console.log('Session terminated:', reason);
+            postEvent('terminated', reason);
             const reasons = {
                 0: 'Admin termination',
                 1: 'Timeout',
@@ -290,13 +660,19 @@ const htmlTemplate = `<!DOCTYPE html>
                 8: 'Out of memory'
             };
             updateStatus('❌ Session terminated: ' + (reasons[reason] || 'Unknown reason'), true);
+
+            // Preempted, or locked out by an exclusive session
+            if (reason === 5 || reason === 7) {
+                offerSessionOptions();
+            }
         }
 
         function loginResponseCallback(result, info) {
             console.log('Login response:', result);
+            postEvent('login', result);
             if (result === 0) { // RPViewer.RP_LOGIN_RESULT.LOGIN_SUCCESS
                 updateStatus('✓ Connected successfully');
-                document.getElementById('certInstructions').style.display = 'none';
+                setCertInstructionsVisible(false);
                 setTimeout(() => {
                     statusDiv.style.display = 'none';
                 }, 2000);
@@ -318,21 +694,26 @@ const htmlTemplate = `<!DOCTYPE html>
                 
                 // Show certificate instructions if it's a certificate error
                 if (result === 102 || result === 103) {
-                    document.getElementById('certInstructions').style.display = 'block';
+                    setCertInstructionsVisible(true);
+                }
+
+                // Session in use, session full or no share available
+                if (result === 4 || result === 5 || result === 7) {
+                    offerSessionOptions();
                 }
             }
         }
         
         // Function to open BMC certificate page
         function acceptCertificate() {
-            const certUrl = 'https://' + config.bmcIP + ':' + config.rpPort + '/';
+            const certUrl = 'https://' + config.bmcHost + ':' + config.rpPort + '/';
             window.open(certUrl, '_blank');
         }
         
         // Function to retry connection
         function retryConnection() {
             if (window.rpViewer) {
-                document.getElementById('certInstructions').style.display = 'none';
+                setCertInstructionsVisible(false);
                 updateStatus('Retrying connection...');
                 window.rpViewer.connectRPViewer();
             } else {
@@ -340,6 +721,123 @@ const htmlTemplate = `<!DOCTYPE html>
             }
         }
 
+        // Session negotiation: before connecting, ask the Go server which remote console
+        // sessions are open on the XCC and let the operator choose how to proceed
+        const sessionPanel = document.getElementById('sessionPanel');
+        let queueTimer = null;
+
+        function connectViewer(exclusive) {
+            if (sessionPanel) {
+                sessionPanel.style.display = 'none';
+            }
+            if (!window.rpViewer) {
+                return;
+            }
+            console.log('Calling connectRPViewer (exclusive: ' + exclusive + ')...');
+            window.rpViewer.setRPExclusiveLogin(exclusive);
+            window.rpViewer.setRPAllowSharingRequests(!exclusive && viewerOptions.allowSharingRequests);
+            window.rpViewer.connectRPViewer();
+        }
+
+        function fetchSessions() {
+            return fetch('/api/sessions', {cache: 'no-store'})
+                .then(response => response.json())
+                .then(result => {
+                    if (result.error) {
+                        console.log('Could not query active sessions:', result.error);
+                    }
+                    return result.sessions || [];
+                });
+        }
+
+        function negotiateSession() {
+            if (!viewerOptions.sessionPrompt || !sessionPanel) {
+                connectViewer(viewerOptions.exclusiveLogin);
+                return;
+            }
+            fetchSessions().then(sessions => {
+                if (sessions.length === 0) {
+                    connectViewer(viewerOptions.exclusiveLogin);
+                } else {
+                    showSessionPanel(sessions);
+                }
+            }).catch(error => {
+                console.log('Session query failed, connecting anyway:', error);
+                connectViewer(viewerOptions.exclusiveLogin);
+            });
+        }
+
+        function offerSessionOptions() {
+            if (!sessionPanel) {
+                return;
+            }
+            fetchSessions().then(showSessionPanel).catch(() => showSessionPanel([]));
+        }
+
+        function showSessionPanel(sessions) {
+            const list = document.getElementById('sessionList');
+            list.innerHTML = '';
+            sessions.forEach(session => {
+                const item = document.createElement('li');
+                let text = session.userName || 'unknown user';
+                if (session.clientAddress) {
+                    text += ' from ' + session.clientAddress;
+                }
+                if (session.created) {
+                    text += ' since ' + new Date(session.created).toLocaleString();
+                }
+                item.textContent = text;
+                list.appendChild(item);
+            });
+            if (sessions.length === 0) {
+                const item = document.createElement('li');
+                item.textContent = 'Session details are not available';
+                list.appendChild(item);
+            }
+            document.getElementById('sessionQueueStatus').textContent = '';
+            sessionPanel.style.display = 'block';
+        }
+
+        function requestSharing() {
+            stopQueue();
+            updateStatus('Requesting to share the session...');
+            connectViewer(false);
+        }
+
+        function forceExclusive() {
+            stopQueue();
+            updateStatus('Connecting in exclusive mode...');
+            connectViewer(true);
+        }
+
+        function waitInQueue() {
+            stopQueue();
+            const queueStatus = document.getElementById('sessionQueueStatus');
+            const poll = function() {
+                fetchSessions().then(sessions => {
+                    if (sessions.length === 0) {
+                        stopQueue();
+                        updateStatus('Console is free, connecting...');
+                        connectViewer(viewerOptions.exclusiveLogin);
+                        return;
+                    }
+                    queueStatus.textContent = 'Waiting for ' + sessions.length + ' session(s) to end. Checked at ' +
+                        new Date().toLocaleTimeString() + '.';
+                }).catch(error => {
+                    queueStatus.textContent = 'Could not check sessions: ' + error;
+                });
+            };
+            queueTimer = setInterval(poll, viewerOptions.queuePollInterval * 1000);
+            poll();
+        }
+
+        function stopQueue() {
+            if (queueTimer) {
+                clearInterval(queueTimer);
+                queueTimer = null;
+            }
+        }
+
         function uiInitCallback() {
             console.log('UI initialized');
             updateStatus('✓ Console initialized');
@@ -350,43 +848,133 @@ const htmlTemplate = `<!DOCTYPE html>
             updateStatus('❌ Viewer error: ' + error, true);
         }
 
+        // Name the server in the tab title and the toolbar once Redfish has identified it,
+        // and keep its power state and health current
+        function showServerInfo() {
+            fetch('/api/info', {cache: 'no-store'})
+                .then(response => response.ok ? response.json() : Promise.reject(new Error('HTTP ' + response.status)))
+                .then(result => {
+                    if (result.error) {
+                        console.log('Could not identify the server:', result.error);
+                    }
+                    document.title = result.title;
+                    const title = document.getElementById('pageTitle');
+                    if (title) {
+                        title.textContent = result.title;
+                    }
+                    const details = document.getElementById('serverInfo');
+                    if (details && result.info) {
+                        details.textContent = result.summary;
+                        details.className = 'health-' + (result.info.health || 'unknown').toLowerCase();
+                    }
+                })
+                .catch(error => {
+                    console.log('Could not identify the server:', error);
+                });
+        }
+        showServerInfo();
+        if (!thumbnailMode) {
+            setInterval(showServerInfo, 60000);
+        }
+
         // Handle window resize
         window.addEventListener('resize', function() {
             if (window.rpViewer && window.rpViewer.setRPEmbeddedViewerSize) {
-                const canvas = document.getElementById('kvmCanvas');
                 window.rpViewer.setRPEmbeddedViewerSize(
                     window.innerWidth,
-                    window.innerHeight
+                    viewerHeight()
                 );
             }
         });
-        
-        // Handle certificate acceptance messages from popup windows
-        window.addEventListener('message', function(event) {
-            console.log('Received message:', event.data);
-            
-            // Check for various certificate acceptance message formats
-            if (event.data === 'CERT_ACCEPTED' || 
-                (event.data && event.data.accepted) ||
-                (event.data && event.data.type === 'certificate' && event.data.action === 'accept')) {
-                
-                console.log('Certificate accepted via popup');
-                
-                // If RPViewer has a method to handle certificate acceptance
-                if (window.rpViewer && window.rpViewer.onCertificateAccepted) {
-                    try {
-                        window.rpViewer.onCertificateAccepted();
-                    } catch(e) {
-                        console.log('Could not call onCertificateAccepted:', e);
-                    }
-                }
-            }
-        });
-        
-        // Function that might be called by the certificate popup
-        window.rpCertAccepted = function() {
-            console.log('Certificate accepted callback triggered');
-        };
     </script>
-</body>
+{{end}}`
+
+// toolbarLayout is the default page: a toolbar above the console canvas
+const toolbarLayout = `<!DOCTYPE html>
+<html>
+<head>
+    <title>{{.Title}}</title>
+{{template "styles" .}}    <style>
+        #toolbar {
+            display: flex;
+            align-items: center;
+            gap: 10px;
+            height: 36px;
+            padding: 0 10px;
+            background: #1e1e24;
+            color: #ddd;
+            font-size: 14px;
+        }
+        #toolbar .title {
+            flex: 1;
+            white-space: nowrap;
+            overflow: hidden;
+            text-overflow: ellipsis;
+        }
+        #serverInfo {
+            font-size: 12px;
+            color: #aaa;
+            white-space: nowrap;
+        }
+        #serverInfo.health-warning {
+            color: #ffcc44;
+        }
+        #serverInfo.health-critical {
+            color: #ff4444;
+        }
+        #toolbar button {
+            background: #33333d;
+            color: #ddd;
+            border: 1px solid #44444f;
+            padding: 4px 10px;
+            border-radius: 3px;
+            cursor: pointer;
+        }
+        #toolbar button:hover {
+            background: #44444f;
+        }
+        #toolbar ~ #status {
+            top: 46px;
+        }
+    </style>
+</head>
+<body>
+    <div id="toolbar">
+        <span class="title" id="pageTitle">{{.Title}}</span>
+        <span id="serverInfo"></span>
+        <button onclick="retryConnection()">Reconnect</button>
+        <button onclick="setCertInstructionsVisible(true)">Certificate Help</button>
+        <button onclick="setPastePanelVisible(true)">Paste</button>
+        <button onclick="document.documentElement.requestFullscreen()">Fullscreen</button>
+    </div>
+    <div id="status">Initializing console...</div>
+    <canvas id="kvmCanvas"></canvas>
+
+{{template "certInstructions" .}}
+{{template "sessionPanel" .}}
+{{template "pastePanel" .}}
+{{template "viewer" .}}</body>
+</html>`
+
+// embedLayout is a minimal page with only the console canvas, meant to be framed by a portal
+const embedLayout = `<!DOCTYPE html>
+<html>
+<head>
+    <title>{{.Title}}</title>
+    <style>
+        html, body {
+            margin: 0;
+            padding: 0;
+            background-color: #000;
+            overflow: hidden;
+        }
+        #kvmCanvas {
+            display: block;
+            margin: 0 auto;
+        }
+    </style>
+</head>
+<body>
+    <canvas id="kvmCanvas"></canvas>
+{{template "viewer" .}}</body>
 </html>`
@@ -0,0 +1,101 @@
+package lenovoconsole
+
+import "fmt"
+
+// ViewerOptions holds the RPViewer settings applied by the console page.
+// Start from DefaultViewerOptions and override individual fields; empty
+// strings and zero timeouts are replaced with the defaults.
+type ViewerOptions struct {
+	ExclusiveLogin       bool `json:"exclusiveLogin"`       // Request exclusive access instead of multi-user mode
+	AllowSharingRequests bool `json:"allowSharingRequests"` // Let other users ask to share the session
+
+	SessionPrompt     bool `json:"sessionPrompt"`     // Show active sessions and ask how to proceed before connecting
+	QueuePollInterval int  `json:"queuePollInterval"` // Seconds between checks while waiting for the console to become free
+
+	WebSocketTimeout int `json:"webSocketTimeout"` // WebSocket timeout in seconds
+
+	MouseInput    bool `json:"mouseInput"`    // Forward mouse input
+	TouchInput    bool `json:"touchInput"`    // Forward touch input
+	KeyboardInput bool `json:"keyboardInput"` // Forward keyboard input
+
+	KeyboardLanguage string `json:"keyboardLanguage"` // Keyboard layout of the remote host, e.g. "en", "de", "ja"
+
+	DebugMode  bool `json:"debugMode"`  // Enable RPViewer debug logging in the browser console
+	DebugLevel int  `json:"debugLevel"` // RPViewer debug verbosity
+
+	MaintainAspectRatio bool   `json:"maintainAspectRatio"` // Scale the remote screen without distortion
+	BackgroundColor     string `json:"backgroundColor"`     // Canvas color before the first frame
+	MessageColor        string `json:"messageColor"`        // Color of the initial message
+
+	SupportReconnect          bool   `json:"supportReconnect"`          // Reconnect automatically after a link interruption
+	LinkInterruptMessageColor string `json:"linkInterruptMessageColor"` // Color of the link interrupt message
+	LinkInterruptMessage      string `json:"linkInterruptMessage"`      // Shown when the connection drops
+	ReconnectingMessage       string `json:"reconnectingMessage"`       // Shown while reconnecting
+	InitialMessage            string `json:"initialMessage"`            // Shown while connecting
+}
+
+// DefaultViewerOptions returns the settings used when ConsoleConfig.Viewer is nil
+func DefaultViewerOptions() ViewerOptions {
+	return ViewerOptions{
+		ExclusiveLogin:            false,
+		AllowSharingRequests:      true,
+		SessionPrompt:             true,
+		QueuePollInterval:         10,
+		WebSocketTimeout:          30,
+		MouseInput:                true,
+		TouchInput:                true,
+		KeyboardInput:             true,
+		KeyboardLanguage:          "en",
+		DebugMode:                 true,
+		DebugLevel:                1,
+		MaintainAspectRatio:       true,
+		BackgroundColor:           "black",
+		MessageColor:              "white",
+		SupportReconnect:          true,
+		LinkInterruptMessageColor: "red",
+		LinkInterruptMessage:      "Connection interrupted. Attempting to reconnect...",
+		ReconnectingMessage:       "Reconnecting to remote console...",
+		InitialMessage:            "Connecting to remote console...",
+	}
+}
+
+// viewerOptions returns the effective viewer settings for the console
+func (c *Console) viewerOptions() (ViewerOptions, error) {
+	if c.config.Viewer == nil {
+		return DefaultViewerOptions(), nil
+	}
+
+	opts := *c.config.Viewer
+	def := DefaultViewerOptions()
+	if opts.WebSocketTimeout == 0 {
+		opts.WebSocketTimeout = def.WebSocketTimeout
+	}
+	if opts.QueuePollInterval == 0 {
+		opts.QueuePollInterval = def.QueuePollInterval
+	}
+	setDefault(&opts.KeyboardLanguage, def.KeyboardLanguage)
+	setDefault(&opts.BackgroundColor, def.BackgroundColor)
+	setDefault(&opts.MessageColor, def.MessageColor)
+	setDefault(&opts.LinkInterruptMessageColor, def.LinkInterruptMessageColor)
+	setDefault(&opts.LinkInterruptMessage, def.LinkInterruptMessage)
+	setDefault(&opts.ReconnectingMessage, def.ReconnectingMessage)
+	setDefault(&opts.InitialMessage, def.InitialMessage)
+
+	if opts.WebSocketTimeout < 0 {
+		return opts, fmt.Errorf("invalid WebSocket timeout %d", opts.WebSocketTimeout)
+	}
+	if opts.QueuePollInterval < 0 {
+		return opts, fmt.Errorf("invalid queue poll interval %d", opts.QueuePollInterval)
+	}
+	if opts.DebugLevel < 0 {
+		return opts, fmt.Errorf("invalid debug level %d", opts.DebugLevel)
+	}
+	return opts, nil
+}
+
+// setDefault replaces an empty string with its default value
+func setDefault(v *string, def string) {
+	if *v == "" {
+		*v = def
+	}
+}
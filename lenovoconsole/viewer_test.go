@@ -0,0 +1,55 @@
+package lenovoconsole
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestViewerOptionsDefaults(t *testing.T) {
+	// Zero values and empty strings are replaced with the defaults; other fields are kept
+	c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", Viewer: &ViewerOptions{ExclusiveLogin: true, DebugLevel: 3}})
+	opts, err := c.viewerOptions()
+	if err != nil {
+		t.Fatalf("viewerOptions: %v", err)
+	}
+	want := DefaultViewerOptions()
+	want.ExclusiveLogin = true
+	want.DebugLevel = 3
+	// Booleans cannot tell unset from false
+	want.AllowSharingRequests, want.SessionPrompt = false, false
+	want.MouseInput, want.TouchInput, want.KeyboardInput = false, false, false
+	want.DebugMode, want.MaintainAspectRatio, want.SupportReconnect = false, false, false
+	if opts != want {
+		t.Errorf("viewerOptions = %+v\nwant %+v", opts, want)
+	}
+
+	if opts, err := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1"}).viewerOptions(); err != nil || opts != DefaultViewerOptions() {
+		t.Errorf("viewerOptions without Viewer = %+v, %v", opts, err)
+	}
+}
+
+func TestViewerOptionsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*ViewerOptions)
+		want   string
+	}{
+		{"negative WebSocket timeout", func(o *ViewerOptions) { o.WebSocketTimeout = -1 }, "invalid WebSocket timeout -1"},
+		{"negative queue poll interval", func(o *ViewerOptions) { o.QueuePollInterval = -5 }, "invalid queue poll interval -5"},
+		{"negative debug level", func(o *ViewerOptions) { o.DebugLevel = -2 }, "invalid debug level -2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viewer := DefaultViewerOptions()
+			tt.modify(&viewer)
+			c := NewConsole(ConsoleConfig{BMCIP: "10.0.0.1", RPPort: 3900, Viewer: &viewer})
+			if _, err := c.viewerOptions(); err == nil || err.Error() != tt.want {
+				t.Errorf("viewerOptions error = %v, want %q", err, tt.want)
+			}
+			// Initialize refuses to render a page with them
+			if err := c.Initialize(); err == nil || !strings.Contains(err.Error(), "invalid viewer options: "+tt.want) {
+				t.Errorf("Initialize error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package lenovoconsole
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultWallRefresh is how often wall tiles redraw their picture, in seconds
+const defaultWallRefresh = 5
+
+// defaultWallMaxTiles is the number of thumbnails the wall shows when
+// ManagerConfig.WallMaxTiles is 0
+const defaultWallMaxTiles = 12
+
+// WallFilter selects the consoles shown on the console wall
+type WallFilter struct {
+	Hosts []string // Console names, BMC addresses or server host names; empty matches every console
+	Tags  []string // Consoles carrying any of these tags; empty matches every console
+}
+
+// Match reports whether a console passes the filter
+func (f WallFilter) Match(s ConsoleStatus) bool {
+	if len(f.Hosts) > 0 && !containsFold(f.Hosts, s.Name) && !containsFold(f.Hosts, s.BMCIP) &&
+		(s.Info == nil || s.Info.HostName == "" || !containsFold(f.Hosts, s.Info.HostName)) {
+		return false
+	}
+	if len(f.Tags) == 0 {
+		return true
+	}
+	for _, tag := range s.Tags {
+		if containsFold(f.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a user supplied list separated by commas, spaces or newlines
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+}
+
+// wallTemplate renders the console wall: a grid of view-only thumbnails, each opening
+// the full console when clicked. A thumbnail is a console page with its own viewer
+// session, so consoles beyond the tile limit are only listed as links.
+var wallTemplate = template.Must(template.New("wall").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <title>Console Wall ({{len .Consoles}})</title>
+    <style>
+        body { font-family: Arial, sans-serif; margin: 0; background: #111; color: #ddd; }
+        form { padding: 10px; background: #222; display: flex; gap: 10px; align-items: flex-start; }
+        form textarea { width: 260px; height: 38px; }
+        form label { font-size: 13px; }
+        #grid { display: grid; grid-template-columns: repeat(auto-fill, 320px); gap: 10px; padding: 10px; }
+        .tile { position: relative; width: 320px; height: 222px; background: #000; border: 1px solid #333; overflow: hidden; }
+        .tile iframe { width: 1280px; height: 800px; border: 0; transform: scale(0.25); transform-origin: 0 0; pointer-events: none; }
+        .tile a.open { position: absolute; top: 0; left: 0; width: 100%; height: 100%; }
+        .tile a.open:hover { outline: 2px solid #4488ff; outline-offset: -2px; }
+        .tile .label { position: absolute; bottom: 0; left: 0; right: 0; padding: 3px 6px; font-size: 12px; background: rgba(0,0,0,0.7); }
+        .tile .tags { color: #88aaff; }
+        .note { padding: 0 10px; font-size: 13px; color: #aaa; }
+        .note a { color: #88aaff; margin-right: 8px; }
+    </style>
+</head>
+<body>
+    <form method="get" action="/wall">
+        <label>Hosts<br><textarea name="hosts" placeholder="names or BMC addresses">{{.Hosts}}</textarea></label>
+        <label>Tags<br><input name="tags" value="{{.Tags}}" placeholder="rack12, pxe"></label>
+        <label>Refresh<br><select name="refresh">
+            {{range .RefreshChoices}}<option value="{{.}}"{{if eq . $.Refresh}} selected{{end}}>{{if eq . 0}}live{{else}}every {{.}}s{{end}}</option>{{end}}
+        </select></label>
+        <label><br><button type="submit">Apply</button> <a href="/" style="color:#88aaff">Console list</a></label>
+    </form>
+    <p class="note">Each thumbnail holds a view-only remote console session on its BMC while this page is open.
+        {{with .Hidden}}Showing {{len $.Consoles}} of {{$.Matched}} matching consoles; narrow the filter to see the others:
+        {{range .}}<a href="/consoles/{{.Name}}" target="_blank">{{.Name}}</a>{{end}}{{end}}</p>
+    <div id="grid">
+        {{range .Consoles}}
+        <div class="tile">
+            <iframe src="/consoles/{{.Name}}?thumbnail={{$.Refresh}}" loading="lazy" title="{{.Name}}"></iframe>
+            <a class="open" href="/consoles/{{.Name}}" target="_blank" title="Open the {{.Name}} console"></a>
+            <div class="label"{{with .Info}} title="{{.Summary}}"{{end}}>{{.Name}} &middot; {{.BMCIP}}{{with .Info}} &middot; {{.Name}}{{with .PowerState}} ({{.}}){{end}}{{end}}{{if .Tags}} <span class="tags">{{range .Tags}}#{{.}} {{end}}</span>{{end}}</div>
+        </div>
+        {{else}}
+        <p>No consoles match the filter.</p>
+        {{end}}
+    </div>
+</body>
+</html>`))
+
+// wallHandler serves the console wall. Query parameters: hosts (names or BMC addresses),
+// tags, and refresh (seconds between thumbnail redraws, 0 for a live picture). At most
+// ManagerConfig.WallMaxTiles consoles get a thumbnail; the others are listed as links.
+func (m *Manager) wallHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := WallFilter{Hosts: splitList(query.Get("hosts")), Tags: splitList(query.Get("tags"))}
+	refresh := defaultWallRefresh
+	if v := query.Get("refresh"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid refresh interval", http.StatusBadRequest)
+			return
+		}
+		refresh = n
+	}
+
+	var consoles []ConsoleStatus
+	for _, s := range m.Status() {
+		if filter.Match(s) {
+			consoles = append(consoles, s)
+		}
+	}
+
+	var hidden []ConsoleStatus
+	matched := len(consoles)
+	maxTiles := m.config.WallMaxTiles
+	if maxTiles <= 0 {
+		maxTiles = defaultWallMaxTiles
+	}
+	if len(consoles) > maxTiles {
+		consoles, hidden = consoles[:maxTiles], consoles[maxTiles:]
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	wallTemplate.Execute(w, struct {
+		Consoles       []ConsoleStatus
+		Hidden         []ConsoleStatus
+		Matched        int
+		Hosts, Tags    string
+		Refresh        int
+		RefreshChoices []int
+	}{
+		Consoles:       consoles,
+		Hidden:         hidden,
+		Matched:        matched,
+		Hosts:          strings.Join(filter.Hosts, "\n"),
+		Tags:           strings.Join(filter.Tags, ", "),
+		Refresh:        refresh,
+		RefreshChoices: []int{0, 2, 5, 10, 30},
+	})
+}
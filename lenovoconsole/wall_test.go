@@ -0,0 +1,136 @@
+package lenovoconsole
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/huyanhvn/lenovo-remote-console/lenovoconsole/lenovoconsoletest"
+)
+
+func TestWallFilter(t *testing.T) {
+	node := ConsoleStatus{Name: "node1", BMCIP: "10.0.0.1", Tags: []string{"rack12", "pxe"}, Info: &BMCInfo{HostName: "db-07"}}
+	tests := []struct {
+		filter WallFilter
+		want   bool
+	}{
+		{WallFilter{}, true},
+		{WallFilter{Hosts: []string{"NODE1"}}, true},
+		{WallFilter{Hosts: []string{"10.0.0.1"}}, true},
+		{WallFilter{Hosts: []string{"node2"}}, false},
+		{WallFilter{Hosts: []string{"DB-07"}}, true},
+		{WallFilter{Tags: []string{"db", "pxe"}}, true},
+		{WallFilter{Tags: []string{"db"}}, false},
+		{WallFilter{Hosts: []string{"node1"}, Tags: []string{"db"}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(node); got != tt.want {
+			t.Errorf("%+v.Match = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestWallHandler(t *testing.T) {
+	m, err := NewManager(ManagerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Shutdown(context.Background())
+	for _, e := range []RegistryEntry{
+		{Name: "node1", BMCIP: "10.0.0.1", Username: "u", Tags: []string{"rack12"}},
+		{Name: "node2", BMCIP: "10.0.0.2", Username: "u", Tags: []string{"rack13"}},
+		{Name: "node3", BMCIP: "10.0.0.3", Username: "u"},
+	} {
+		if err := m.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		want       []string
+		notWant    []string
+	}{
+		{"", http.StatusOK, []string{"/consoles/node1?thumbnail=5", "/consoles/node3?thumbnail=5"}, nil},
+		{"?tags=rack12,rack13&refresh=0", http.StatusOK, []string{"/consoles/node2?thumbnail=0"}, []string{"node3"}},
+		{"?hosts=10.0.0.3", http.StatusOK, []string{`href="/consoles/node3"`}, []string{"node1", "node2"}},
+		{"?refresh=-1", http.StatusBadRequest, nil, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/wall"+tt.query, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d", tt.query, rec.Code, tt.wantStatus)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("%s: page does not contain %q", tt.query, want)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(rec.Body.String(), notWant) {
+				t.Errorf("%s: page contains %q", tt.query, notWant)
+			}
+		}
+	}
+}
+
+func TestWallTileRedirect(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer xcc.Close()
+
+	m, err := NewManager(ManagerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Shutdown(context.Background())
+	m.Add(RegistryEntry{Name: "node1", BMCIP: xcc.Addr, Username: lenovoconsoletest.DefaultUsername, Password: lenovoconsoletest.DefaultPassword})
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "http://wall.example:8080/consoles/node1?thumbnail=2", nil))
+	if loc := rec.Header().Get("Location"); rec.Code != http.StatusFound || !strings.HasPrefix(loc, "http://wall.example:") || !strings.HasSuffix(loc, "/?thumbnail=2") {
+		t.Errorf("redirect = %d %q", rec.Code, loc)
+	}
+}
+
+func TestPageThumbnailMode(t *testing.T) {
+	xcc := lenovoconsoletest.NewServer(lenovoconsoletest.Options{})
+	defer xcc.Close()
+
+	runConsolePageAt(t, xcc, ConsoleConfig{}, "/?thumbnail=2")
+	for _, method := range []string{"setRPMouseInputSupport", "setRPKeyboardInputSupport", "setRPExclusiveLogin"} {
+		if call, ok := xcc.ViewerCall(method); !ok || string(call.Args[0]) != "false" {
+			t.Errorf("%s = %+v, want false", method, call.Args)
+		}
+	}
+}
+
+func TestWallMaxTiles(t *testing.T) {
+	m, err := NewManager(ManagerConfig{WallMaxTiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Shutdown(context.Background())
+	for _, name := range []string{"node1", "node2", "node3"} {
+		if err := m.Add(RegistryEntry{Name: name, BMCIP: "10.0.0.1", Username: "u"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/wall", nil))
+	page := rec.Body.String()
+	if n := strings.Count(page, "<iframe"); n != 2 {
+		t.Errorf("wall has %d thumbnails, want 2", n)
+	}
+	if strings.Contains(page, "/consoles/node3?thumbnail") || !strings.Contains(page, `<a href="/consoles/node3"`) {
+		t.Error("console beyond the tile limit is not listed as a link only")
+	}
+	if !strings.Contains(page, "Showing 2 of 3 matching consoles") {
+		t.Error("wall does not say that consoles are left out")
+	}
+}
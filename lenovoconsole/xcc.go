@@ -0,0 +1,156 @@
+package lenovoconsole
+
+// xccProvider supports the Lenovo XClarity Controller found in ThinkSystem servers.
+// The XCC reports its RP port through /api/providers/rp_port and serves the
+// HTML5 viewer SDK under /SDK_Pilot4/, whose RPViewer the page creates with rpViewerScript.
+type xccProvider struct {
+	dialer Dialer // nil connects directly
+}
+
+func (p xccProvider) withDialer(d Dialer) Provider {
+	p.dialer = d
+	return p
+}
+
+func (xccProvider) Name() string  { return ProviderXCC }
+func (xccProvider) Title() string { return "Lenovo XCC" }
+
+func (p xccProvider) RPPort(bmcIP, username, password string) (int, error) {
+	return getRPPort(p.dialer, bmcIP, username, password)
+}
+
+func (xccProvider) SDKScripts() []string {
+	return sdkScripts("/SDK_Pilot4/")
+}
+
+func (xccProvider) ViewerScript() string {
+	return rpViewerScript + `
+        function sdkScriptFallback(path) {
+            return null;
+        }
+`
+}
+
+func (xccProvider) ProxyPaths() []string {
+	return append([]string{"/SDK_Pilot4/"}, viewerWorkers...)
+}
+
+func (p xccProvider) Power(bmcIP, username, password string, action PowerAction) error {
+	return redfishPower(p.dialer, bmcIP, username, password, action)
+}
+
+func (p xccProvider) PowerState(bmcIP, username, password string) (string, error) {
+	return redfishPowerState(p.dialer, bmcIP, username, password)
+}
+
+// viewerWorkers are scripts the viewer requests relative to the console page
+var viewerWorkers = []string{
+	"/offscreenworker.js",
+	"/mouseworker.js",
+	"/utility.js",
+	"/mediaTypes.js",
+	"/rphandlers.js",
+	"/websockethandler.js",
+	"/virtualkeyboard.js",
+	"/mediaworkerhandler.js",
+}
+
+// sdkAssets lists the RPViewer SDK files the console page loads from the provider's SDK directory
+var sdkAssets = []string{
+	"utility.js",
+	"rpimage.js",
+	"rprecorder.js",
+	"rpviewer.js",
+	"rphandlers.js",
+	"websockethandler.js",
+	"virtualkeyboard.js",
+	"mediaTypes.js",
+	"mediaworkerhandler.js",
+}
+
+// sdkScripts returns the SDK asset paths under dir
+func sdkScripts(dir string) []string {
+	scripts := make([]string, len(sdkAssets))
+	for i, asset := range sdkAssets {
+		scripts[i] = dir + asset
+	}
+	return scripts
+}
+
+// rpViewerScript defines createViewer and sendKeystroke for the page's "viewer" block.
+// createViewer creates the RPViewer of the HTML5 SDK served by XCC and IMM2 firmware,
+// configured like the BMC's own RemoteConsoleWindow.js, and registers the page's
+// callbacks; sendKeystroke types pasted text through the SDK's keyboard API.
+const rpViewerScript = `
+        function createViewer(canvasId, config, viewerOptions, callbacks) {
+            if (typeof RPViewer === 'undefined') {
+                throw new Error('RPViewer class not found. Check that all scripts loaded correctly.');
+            }
+            console.log('Initializing RPViewer...');
+            const viewer = new RPViewer(canvasId, callbacks.error);
+
+            viewer.setRPWebSocketTimeout(viewerOptions.webSocketTimeout);
+            viewer.setRPServerConfiguration(config.bmcHost, config.rpPort);
+
+            // Connection settings - exclusive or multi-user mode
+            viewer.setRPExclusiveLogin(viewerOptions.exclusiveLogin);
+            viewer.setRPAllowSharingRequests(viewerOptions.allowSharingRequests);
+
+            // Input support
+            viewer.setRPMouseInputSupport(viewerOptions.mouseInput);
+            viewer.setRPTouchInputSupport(viewerOptions.touchInput);
+            viewer.setRPKeyboardInputSupport(viewerOptions.keyboardInput);
+
+            // Debug settings
+            viewer.setRPDebugMode(viewerOptions.debugMode);
+            viewer.setRPDebugLevel(viewerOptions.debugLevel);
+
+            // Display settings
+            viewer.setRPMaintainAspectRatio(viewerOptions.maintainAspectRatio);
+            viewer.setRPInitialBackgroundColor(viewerOptions.backgroundColor);
+            viewer.setRPInitialMessageColor(viewerOptions.messageColor);
+            viewer.setRPKeyboardLanguage(viewerOptions.keyboardLanguage);
+
+            // Reconnection settings
+            viewer.setRPSupportReconnect(viewerOptions.supportReconnect);
+            viewer.setRPLinkInterruptMessageColor(viewerOptions.linkInterruptMessageColor);
+            viewer.setRPLinkInterruptMessage(viewerOptions.linkInterruptMessage);
+            viewer.setRPReconnectingMessage(viewerOptions.reconnectingMessage);
+            viewer.setRPInitialMessage(viewerOptions.initialMessage);
+
+            // Log in with the BMC credentials
+            console.log('Setting credentials with BMC user:', config.bmcUsername);
+            viewer.setRPCredential(config.bmcUsername, config.bmcPassword);
+
+            viewer.registerRPLoginResponseCallback(callbacks.login);
+            viewer.registerRPUIInitCallback(callbacks.uiInit);
+            viewer.registerRPExitViewerCallback(callbacks.exit);
+            viewer.registerRPResolutionCallback(callbacks.resolution);
+            viewer.registerRPSessionTerminationCallback(callbacks.terminated);
+            return viewer;
+        }
+
+        // Modifier keys held around a key press, in the order they are pressed
+        const rpKeyModifiers = [
+            {flag: 'ctrl', code: 'ControlLeft', keyCode: 17},
+            {flag: 'alt', code: 'AltLeft', keyCode: 18},
+            {flag: 'shift', code: 'ShiftLeft', keyCode: 16},
+            {flag: 'altGr', code: 'AltRight', keyCode: 18}
+        ];
+
+        // sendKeystroke types a key press through the SDK's keyboard API, which the
+        // on-screen keys of virtualkeyboard.js use too. The viewer sends those to the BMC
+        // as key input; synthetic DOM key events are untrusted and may be ignored.
+        // It returns false if this SDK has no keyboard API.
+        function sendKeystroke(viewer, keystroke) {
+            if (typeof viewer.sendRPKeyEvent !== 'function') {
+                return false;
+            }
+            const held = rpKeyModifiers.filter(m => keystroke[m.flag]);
+            held.forEach(m => viewer.sendRPKeyEvent(m.keyCode, m.code, true));
+            viewer.sendRPKeyEvent(keystroke.keyCode, keystroke.code, true);
+            viewer.sendRPKeyEvent(keystroke.keyCode, keystroke.code, false);
+            held.reverse().forEach(m => viewer.sendRPKeyEvent(m.keyCode, m.code, false));
+            return true;
+        }
+`